@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"myapp/internal"
+	"myapp/internal/imports"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var importCmd = &cobra.Command{
+	Use:   "import {products|suppliers|inventory} FILE",
+	Short: "Bulk import products, suppliers or opening inventory balances",
+	Long: `Import a CSV or JSON lines file ("-" reads standard input).
+
+Products are matched by SKU, suppliers by name and inventory rows by SKU and
+warehouse; matches are updated and the rest created. Every row is validated
+first and the batch is saved in one transaction, so when any row is invalid
+the errors are listed and nothing is imported.`,
+	Args:         cobra.ExactArgs(2),
+	SilenceUsage: true,
+	PreRunE: func(*cobra.Command, []string) error {
+		return internal.OpenDB(internal.ConnStringFromEnv())
+	},
+	RunE: runImport,
+}
+
+func init() {
+	importCmd.Flags().Bool("dry-run", false, "validate and report without saving anything")
+	importCmd.Flags().String("format", "", "csv or jsonl (default: from the file extension)")
+	rootCmd.AddCommand(importCmd)
+}
+
+func runImport(cmd *cobra.Command, args []string) error {
+	importType, path := args[0], args[1]
+	if !slices.Contains(imports.Types(), importType) {
+		return fmt.Errorf("unknown import type %q; use %s", importType, strings.Join(imports.Types(), ", "))
+	}
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	name, _ := cmd.Flags().GetString("format")
+	if name == "" {
+		name = filepath.Ext(path)
+	}
+	format, ok := imports.ParseFormat(name)
+	if !ok {
+		return fmt.Errorf("cannot tell the format of %s; use --format csv or jsonl", path)
+	}
+
+	var in io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+	records, err := imports.Read(in, format)
+	if err != nil {
+		return fmt.Errorf("invalid import file: %w", err)
+	}
+	if len(records) == 0 {
+		return fmt.Errorf("no rows to import")
+	}
+
+	result, err := imports.Run(importType, records, dryRun, "cli")
+	if err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	for _, e := range result.Errors {
+		fmt.Fprintln(out, e)
+	}
+	if len(result.Errors) > 0 {
+		return fmt.Errorf("%d errors; nothing was imported", len(result.Errors))
+	}
+	fmt.Fprintf(out, "%d %s rows: %d created, %d updated, %d unchanged\n",
+		result.Rows, importType, result.Created, result.Updated, result.Unchanged)
+	if dryRun {
+		fmt.Fprintln(out, "Dry run: nothing was saved")
+	}
+	return nil
+}
@@ -13,14 +13,15 @@ import (
 )
 
 var rootCmd = &cobra.Command{
-	Use:   "main.go",
-	Short: "A brief description of your application",
-	Long: `A longer description that spans multiple lines and likely contains
-examples and usage of using your application. For example:
+	Use:   "myapp",
+	Short: "Inventory management system",
+	Long: `Inventory management system API server and maintenance commands.
 
-Cobra is a CLI library for Go that empowers applications.
-This application is a tool to generate the needed files
-to quickly create a Cobra application.`,
+Run without arguments to start the API server. Commands read their settings
+from .env and exit; import connects to the configured database, for example:
+
+  myapp import products products.csv --dry-run
+  myapp ws-token alice --topics inventory`,
 }
 
 func Execute() {
@@ -29,7 +30,3 @@ func Execute() {
 		os.Exit(1)
 	}
 }
-
-func init() {
-	rootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
-}
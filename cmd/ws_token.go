@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"fmt"
+	"myapp/internal/websocket"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var wsTokenCmd = &cobra.Command{
+	Use:   "ws-token SUBJECT",
+	Short: "Issue an access token for the WebSocket and event stream endpoints",
+	Long: `Print an HS256 token for SUBJECT signed with WS_AUTH_SECRET.
+
+Without --topics the token grants every topic. The subject is recorded as
+the actor for inventory commands sent over the connection.`,
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE:         runWSToken,
+}
+
+func init() {
+	wsTokenCmd.Flags().StringSlice("topics", nil, "comma-separated topics to grant (default: all)")
+	wsTokenCmd.Flags().Duration("ttl", time.Hour, "how long the token is valid")
+	rootCmd.AddCommand(wsTokenCmd)
+}
+
+func runWSToken(cmd *cobra.Command, args []string) error {
+	subject := strings.TrimSpace(args[0])
+	if subject == "" {
+		return fmt.Errorf("subject is required")
+	}
+	topics, _ := cmd.Flags().GetStringSlice("topics")
+	for _, t := range topics {
+		if !slices.Contains(websocket.Topics(), t) {
+			return fmt.Errorf("unknown topic %q; use %s", t, strings.Join(websocket.Topics(), ", "))
+		}
+	}
+	ttl, _ := cmd.Flags().GetDuration("ttl")
+	if ttl <= 0 {
+		return fmt.Errorf("--ttl must be positive")
+	}
+
+	websocket.ConfigureSecurity()
+	token, err := websocket.IssueToken(subject, topics, ttl)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(cmd.OutOrStdout(), token)
+	return nil
+}
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"bytes"
+	"myapp/internal/websocket"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+// runCommand executes the root command and resets the ws-token flags, which
+// cobra otherwise keeps between runs.
+func runCommand(args ...string) (string, error) {
+	defer wsTokenCmd.Flags().VisitAll(func(f *pflag.Flag) {
+		if s, ok := f.Value.(pflag.SliceValue); ok {
+			s.Replace(nil)
+		} else {
+			f.Value.Set(f.DefValue)
+		}
+		f.Changed = false
+	})
+	var out bytes.Buffer
+	rootCmd.SetOut(&out)
+	rootCmd.SetErr(&out)
+	rootCmd.SetArgs(args)
+	err := rootCmd.Execute()
+	return out.String(), err
+}
+
+func TestWSTokenIssuesVerifiableToken(t *testing.T) {
+	t.Setenv("WS_AUTH_SECRET", "s3cret")
+	out, err := runCommand("ws-token", "alice", "--topics", "inventory,orders", "--ttl", "30m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := websocket.VerifyToken(strings.TrimSpace(out))
+	if err != nil {
+		t.Fatalf("issued token does not verify: %v", err)
+	}
+	if claims.Subject != "alice" || !slices.Equal(claims.Topics, []string{"inventory", "orders"}) {
+		t.Errorf("claims = %+v", claims)
+	}
+	if d := time.Until(claims.Expiry()); d < 29*time.Minute || d > 30*time.Minute {
+		t.Errorf("expires in %v, want 30m", d)
+	}
+}
+
+func TestWSTokenRejects(t *testing.T) {
+	tests := []struct {
+		name   string
+		secret string
+		args   []string
+		want   string
+	}{
+		{"unknown topic", "s3cret", []string{"ws-token", "alice", "--topics", "payroll"}, "unknown topic"},
+		{"non-positive ttl", "s3cret", []string{"ws-token", "alice", "--ttl", "0s"}, "--ttl"},
+		{"no secret", "", []string{"ws-token", "alice"}, "WS_AUTH_SECRET"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("WS_AUTH_SECRET", tt.secret)
+			_, err := runCommand(tt.args...)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("err = %v, want it to mention %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,314 @@
+// Package classification ranks products by consumption value (ABC) and
+// demand variability (XYZ) from their OUT stock movements, to set cycle
+// counting frequency and safety stock.
+package classification
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"myapp/internal"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Cumulative shares of consumption value covered by classes A and B;
+// products beyond them are C.
+const (
+	shareA = 0.80
+	shareB = 0.95
+)
+
+// Coefficients of variation of weekly demand up to which demand is steady
+// (X) or fluctuating (Y); anything above, or no demand at all, is Z.
+const (
+	cvX = 0.5
+	cvY = 1.0
+)
+
+const week = 7 * 24 * time.Hour
+
+// Demand is the weekly demand of a product, overall or in one warehouse.
+type Demand struct {
+	ProductID   uint
+	WarehouseID uint      // 0 for all warehouses
+	Weeks       []float64 // units issued per week, including weeks without demand
+	UnitCost    float64
+}
+
+// Classify assigns ABC and XYZ classes to demands that share a scope.
+// Classes are returned highest consumption value first.
+func Classify(demands []Demand) []internal.ProductClass {
+	classes := make([]internal.ProductClass, len(demands))
+	var total float64
+	for i, d := range demands {
+		var units float64
+		for _, u := range d.Weeks {
+			units += u
+		}
+		classes[i] = internal.ProductClass{
+			ProductID:        d.ProductID,
+			WarehouseID:      d.WarehouseID,
+			ConsumptionValue: round(units * d.UnitCost),
+			XYZ:              "Z",
+		}
+		if cv := variation(d.Weeks); cv != nil {
+			classes[i].DemandCV = cv
+			switch {
+			case *cv <= cvX:
+				classes[i].XYZ = "X"
+			case *cv <= cvY:
+				classes[i].XYZ = "Y"
+			}
+		}
+		total += classes[i].ConsumptionValue
+	}
+
+	sort.SliceStable(classes, func(i, j int) bool {
+		if classes[i].ConsumptionValue != classes[j].ConsumptionValue {
+			return classes[i].ConsumptionValue > classes[j].ConsumptionValue
+		}
+		return classes[i].ProductID < classes[j].ProductID
+	})
+	var cumulative float64
+	for i := range classes {
+		// A product is classed by the share before it, so the one that
+		// crosses a threshold stays in the higher class
+		before := 0.0
+		if total > 0 {
+			before = cumulative / total
+		}
+		cumulative += classes[i].ConsumptionValue
+		switch {
+		case classes[i].ConsumptionValue == 0:
+			classes[i].ABC = "C"
+		case before < shareA:
+			classes[i].ABC = "A"
+		case before < shareB:
+			classes[i].ABC = "B"
+		default:
+			classes[i].ABC = "C"
+		}
+		if total > 0 {
+			classes[i].CumulativeShare = round(cumulative / total * 100)
+		}
+	}
+	return classes
+}
+
+// variation is the coefficient of variation of weekly demand, or nil when
+// there was no demand.
+func variation(weeks []float64) *float64 {
+	if len(weeks) == 0 {
+		return nil
+	}
+	var sum float64
+	for _, u := range weeks {
+		sum += u
+	}
+	mean := sum / float64(len(weeks))
+	if mean == 0 {
+		return nil
+	}
+	var squares float64
+	for _, u := range weeks {
+		squares += (u - mean) * (u - mean)
+	}
+	cv := round(math.Sqrt(squares/float64(len(weeks))) / mean)
+	return &cv
+}
+
+func round(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// loadDemand builds the weekly demand of every product over the last days,
+// overall and, with perWarehouse, for every product stocked or issued in
+// each warehouse. Units are costed at Product.Cost, or Price if unset.
+func loadDemand(now time.Time, days int, perWarehouse bool) ([]Demand, error) {
+	weeks := (days + 6) / 7
+	since := now.Add(-time.Duration(weeks) * week)
+
+	var products []internal.Product
+	if err := internal.DB.Select("id, cost, price").Find(&products).Error; err != nil {
+		return nil, err
+	}
+	var rows []struct {
+		ProductID   uint
+		WarehouseID uint
+		Week        int
+		Units       float64
+	}
+	if err := internal.DB.Table("stock_movements").
+		Select(`product_id, warehouse_id,
+			FLOOR(EXTRACT(EPOCH FROM (? - created_at)) / 604800)::int AS week,
+			SUM(-quantity) AS units`, now).
+		Where("type = ? AND created_at > ? AND created_at <= ?", "OUT", since, now).
+		Group("product_id, warehouse_id, week").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	type key struct{ product, warehouse uint }
+	series := make(map[key][]float64)
+	add := func(k key, w int, units float64) {
+		if series[k] == nil {
+			series[k] = make([]float64, weeks)
+		}
+		if w >= 0 && w < weeks {
+			series[k][w] += units
+		}
+	}
+	for _, p := range products {
+		add(key{p.ID, 0}, -1, 0)
+	}
+	if perWarehouse {
+		var stocked []internal.Inventory
+		if err := internal.DB.Select("product_id, warehouse_id").Find(&stocked).Error; err != nil {
+			return nil, err
+		}
+		for _, inv := range stocked {
+			add(key{inv.ProductID, inv.WarehouseID}, -1, 0)
+		}
+	}
+	for _, r := range rows {
+		add(key{r.ProductID, 0}, r.Week, r.Units)
+		if perWarehouse {
+			add(key{r.ProductID, r.WarehouseID}, r.Week, r.Units)
+		}
+	}
+
+	costs := make(map[uint]float64, len(products))
+	for _, p := range products {
+		costs[p.ID] = p.Cost
+		if p.Cost == 0 {
+			costs[p.ID] = p.Price
+		}
+	}
+	demands := make([]Demand, 0, len(series))
+	for k, s := range series {
+		if _, ok := costs[k.product]; !ok {
+			continue // deleted product
+		}
+		demands = append(demands, Demand{ProductID: k.product, WarehouseID: k.warehouse, Weeks: s, UnitCost: costs[k.product]})
+	}
+	return demands, nil
+}
+
+// Run classifies every product on the last days of demand, overall and
+// optionally per warehouse, and stores the classes as a new run. Overall
+// classes are also written to Product.ABCClass and XYZClass.
+func Run(days int, perWarehouse bool) (*internal.ClassificationRun, []internal.ProductClass, error) {
+	demands, err := loadDemand(time.Now(), days, perWarehouse)
+	if err != nil {
+		return nil, nil, err
+	}
+	scopes := make(map[uint][]Demand)
+	for _, d := range demands {
+		scopes[d.WarehouseID] = append(scopes[d.WarehouseID], d)
+	}
+	var classes []internal.ProductClass
+	for _, scope := range scopes {
+		classes = append(classes, Classify(scope)...)
+	}
+
+	run := internal.ClassificationRun{Days: days, PerWarehouse: perWarehouse, Products: len(scopes[0])}
+	err = internal.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&run).Error; err != nil {
+			return err
+		}
+		for i := range classes {
+			classes[i].RunID = run.ID
+		}
+		if len(classes) > 0 {
+			if err := tx.CreateInBatches(classes, 1000).Error; err != nil {
+				return err
+			}
+		}
+		for _, c := range classes {
+			if c.WarehouseID != 0 {
+				continue
+			}
+			if err := tx.Model(&internal.Product{}).Where("id = ?", c.ProductID).
+				UpdateColumns(map[string]interface{}{"abc_class": c.ABC, "xyz_class": c.XYZ}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return &run, classes, nil
+}
+
+// Shift is a product whose class changed between two runs.
+type Shift struct {
+	ProductID   uint   `json:"product_id"`
+	SKU         string `json:"sku,omitempty"`
+	WarehouseID uint   `json:"warehouse_id"`
+	From        string `json:"from"` // e.g. "AX"; empty if not classified before
+	To          string `json:"to"`
+}
+
+// Shifts compares the classes of two runs. Only scopes classified in both
+// runs are compared, so adding per-warehouse classes reports no shifts.
+func Shifts(previous, current []internal.ProductClass) []Shift {
+	type key struct{ product, warehouse uint }
+	before := make(map[key]string, len(previous))
+	scopes := make(map[uint]bool)
+	for _, c := range previous {
+		before[key{c.ProductID, c.WarehouseID}] = c.ABC + c.XYZ
+		scopes[c.WarehouseID] = true
+	}
+	var shifts []Shift
+	for _, c := range current {
+		if !scopes[c.WarehouseID] {
+			continue
+		}
+		from := before[key{c.ProductID, c.WarehouseID}]
+		if to := c.ABC + c.XYZ; from != to {
+			shifts = append(shifts, Shift{ProductID: c.ProductID, WarehouseID: c.WarehouseID, From: from, To: to})
+		}
+	}
+	sort.Slice(shifts, func(i, j int) bool {
+		if shifts[i].WarehouseID != shifts[j].WarehouseID {
+			return shifts[i].WarehouseID < shifts[j].WarehouseID
+		}
+		return shifts[i].ProductID < shifts[j].ProductID
+	})
+	return shifts
+}
+
+// latestRuns returns up to n runs, newest first, with their classes.
+func latestRuns(n int) ([]internal.ClassificationRun, [][]internal.ProductClass, error) {
+	var runs []internal.ClassificationRun
+	if err := internal.DB.Order("id DESC").Limit(n).Find(&runs).Error; err != nil {
+		return nil, nil, err
+	}
+	classes := make([][]internal.ProductClass, len(runs))
+	for i, run := range runs {
+		if err := internal.DB.Where("run_id = ?", run.ID).Find(&classes[i]).Error; err != nil {
+			return nil, nil, err
+		}
+	}
+	return runs, classes, nil
+}
+
+// StartScheduler reclassifies products every interval.
+func StartScheduler(interval time.Duration, days int, perWarehouse bool) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			run, _, err := Run(days, perWarehouse)
+			if err != nil {
+				log.Printf("Scheduled classification failed: %v", err)
+				continue
+			}
+			internal.LogAudit("CLASSIFY", "ClassificationRun", run.ID, "system", fmt.Sprintf("Classified %d products", run.Products))
+		}
+	}()
+}
@@ -0,0 +1,53 @@
+package classification
+
+import (
+	"myapp/internal"
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	demands := []Demand{
+		{ProductID: 1, Weeks: []float64{10, 10, 10, 10}, UnitCost: 20}, // 800, steady
+		{ProductID: 2, Weeks: []float64{0, 5, 0, 5}, UnitCost: 10},     // 100, CV 1
+		{ProductID: 3, Weeks: []float64{0, 0, 0, 8}, UnitCost: 10},     // 80, lumpy
+		{ProductID: 4, Weeks: []float64{1, 1, 0, 0}, UnitCost: 10},     // 20
+		{ProductID: 5, Weeks: []float64{0, 0, 0, 0}, UnitCost: 10},     // no demand
+	}
+	want := map[uint]string{1: "AX", 2: "BY", 3: "BZ", 4: "CY", 5: "CZ"}
+
+	classes := Classify(demands)
+	if len(classes) != len(demands) || classes[0].ProductID != 1 {
+		t.Fatalf("classes = %+v", classes)
+	}
+	for _, c := range classes {
+		if got := c.ABC + c.XYZ; got != want[c.ProductID] {
+			t.Errorf("product %d = %s, want %s", c.ProductID, got, want[c.ProductID])
+		}
+	}
+	if last := classes[len(classes)-1]; last.DemandCV != nil || last.CumulativeShare != 100 {
+		t.Errorf("product without demand = %+v", last)
+	}
+}
+
+func TestShifts(t *testing.T) {
+	previous := []internal.ProductClass{
+		{ProductID: 1, ABC: "A", XYZ: "X"},
+		{ProductID: 2, ABC: "B", XYZ: "Y"},
+	}
+	current := []internal.ProductClass{
+		{ProductID: 1, ABC: "A", XYZ: "X"},
+		{ProductID: 2, ABC: "C", XYZ: "Y"},
+		{ProductID: 3, ABC: "C", XYZ: "Z"},
+		{ProductID: 1, WarehouseID: 4, ABC: "A", XYZ: "Y"}, // scope new in this run
+	}
+	shifts := Shifts(previous, current)
+	if len(shifts) != 2 {
+		t.Fatalf("shifts = %+v", shifts)
+	}
+	if s := shifts[0]; s.ProductID != 2 || s.From != "BY" || s.To != "CY" {
+		t.Errorf("shift = %+v", s)
+	}
+	if s := shifts[1]; s.ProductID != 3 || s.From != "" || s.To != "CZ" {
+		t.Errorf("new product shift = %+v", s)
+	}
+}
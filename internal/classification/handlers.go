@@ -0,0 +1,152 @@
+package classification
+
+import (
+	"encoding/json"
+	"fmt"
+	"myapp/internal"
+	"net/http"
+	"strconv"
+)
+
+const defaultDays = 365
+
+// summarize counts products per class combination, e.g. "AX": 12.
+func summarize(classes []internal.ProductClass, warehouseID uint) map[string]int {
+	counts := make(map[string]int)
+	for _, c := range classes {
+		if c.WarehouseID == warehouseID {
+			counts[c.ABC+c.XYZ]++
+		}
+	}
+	return counts
+}
+
+// withSKUs fills in the SKU of each shift.
+func withSKUs(shifts []Shift) []Shift {
+	if len(shifts) == 0 {
+		return []Shift{}
+	}
+	var products []internal.Product
+	internal.DB.Select("id, sku").Find(&products)
+	skus := make(map[uint]string, len(products))
+	for _, p := range products {
+		skus[p.ID] = p.SKU
+	}
+	for i := range shifts {
+		shifts[i].SKU = skus[shifts[i].ProductID]
+	}
+	return shifts
+}
+
+// RunClassification classifies products on the last ?days= (default 365)
+// of demand, per warehouse too with ?per_warehouse=true, and reports the
+// shifts since the previous run.
+func RunClassification(w http.ResponseWriter, r *http.Request) {
+	days := defaultDays
+	if v := r.URL.Query().Get("days"); v != "" {
+		var err error
+		if days, err = strconv.Atoi(v); err != nil || days < 7 {
+			http.Error(w, "days must be at least 7", http.StatusBadRequest)
+			return
+		}
+	}
+	perWarehouse := r.URL.Query().Get("per_warehouse") == "true"
+
+	previous, previousClasses, err := latestRuns(1)
+	if err != nil {
+		http.Error(w, "Failed to load previous classification", http.StatusInternalServerError)
+		return
+	}
+	run, classes, err := Run(days, perWarehouse)
+	if err != nil {
+		http.Error(w, "Failed to classify products", http.StatusInternalServerError)
+		return
+	}
+	shifts := []Shift{}
+	if len(previous) > 0 {
+		shifts = withSKUs(Shifts(previousClasses[0], classes))
+	}
+
+	internal.LogAudit("CLASSIFY", "ClassificationRun", run.ID, "system", fmt.Sprintf("Classified %d products", run.Products))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data": map[string]interface{}{
+			"run":     run,
+			"summary": summarize(classes, 0),
+			"shifts":  shifts,
+		},
+	})
+}
+
+// GetClassification lists the classes of the latest run, overall or for
+// ?warehouse_id=, optionally limited to ?abc= and ?xyz=.
+func GetClassification(w http.ResponseWriter, r *http.Request) {
+	var run internal.ClassificationRun
+	if err := internal.DB.Order("id DESC").First(&run).Error; err != nil {
+		http.Error(w, "No classification run yet", http.StatusNotFound)
+		return
+	}
+
+	warehouseID, _ := strconv.Atoi(r.URL.Query().Get("warehouse_id"))
+	var classes []struct {
+		internal.ProductClass
+		SKU         string `json:"sku"`
+		ProductName string `json:"product_name"`
+	}
+	query := internal.DB.Table("product_classes pc").
+		Select("pc.*, p.sku, p.name as product_name").
+		Joins("JOIN products p ON pc.product_id = p.id").
+		Where("pc.run_id = ? AND pc.warehouse_id = ?", run.ID, warehouseID)
+	if abc := r.URL.Query().Get("abc"); abc != "" {
+		query = query.Where("pc.abc = ?", abc)
+	}
+	if xyz := r.URL.Query().Get("xyz"); xyz != "" {
+		query = query.Where("pc.xyz = ?", xyz)
+	}
+	if err := query.Order("pc.consumption_value DESC, pc.product_id").Scan(&classes).Error; err != nil {
+		http.Error(w, "Failed to fetch classification", http.StatusInternalServerError)
+		return
+	}
+
+	counts := make(map[string]int)
+	for _, c := range classes {
+		counts[c.ABC+c.XYZ]++
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data": map[string]interface{}{
+			"run":     run,
+			"summary": counts,
+			"items":   classes,
+		},
+	})
+}
+
+// GetClassShifts reports products whose class changed between the two
+// latest runs.
+func GetClassShifts(w http.ResponseWriter, r *http.Request) {
+	runs, classes, err := latestRuns(2)
+	if err != nil {
+		http.Error(w, "Failed to fetch classification", http.StatusInternalServerError)
+		return
+	}
+	if len(runs) < 2 {
+		http.Error(w, "Shifts need two classification runs", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data": map[string]interface{}{
+			"run":          runs[0],
+			"previous_run": runs[1],
+			"shifts":       withSKUs(Shifts(classes[1], classes[0])),
+		},
+	})
+}
@@ -0,0 +1,14 @@
+package internal
+
+import "time"
+
+// ParseAsOf reads a point in time from a query parameter. A plain date
+// (2006-01-02) means the end of that day in local time; otherwise the value
+// must be an RFC 3339 timestamp.
+func ParseAsOf(v string) (time.Time, error) {
+	if d, err := time.ParseInLocation("2006-01-02", v, time.Local); err == nil {
+		// Postgres keeps microseconds, so a nanosecond earlier would round up
+		return d.AddDate(0, 0, 1).Add(-time.Microsecond), nil
+	}
+	return time.Parse(time.RFC3339, v)
+}
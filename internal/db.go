@@ -1,7 +1,9 @@
 package internal
 
 import (
+	"fmt"
 	"log"
+	"os"
 	"time"
 
 	"gorm.io/driver/postgres"
@@ -10,11 +12,26 @@ import (
 
 var DB *gorm.DB
 
+// ConnStringFromEnv builds the Postgres connection string from the DB_*
+// environment variables.
+func ConnStringFromEnv() string {
+	return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
+		os.Getenv("DB_HOST"), os.Getenv("DB_PORT"), os.Getenv("DB_USER"), os.Getenv("DB_PASSWORD"), os.Getenv("DB_NAME"))
+}
+
 func InitDB(connStr string) {
+	if err := OpenDB(connStr); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// OpenDB connects to Postgres and migrates the schema, returning any error
+// instead of exiting. Commands that need the database call it themselves.
+func OpenDB(connStr string) error {
 	var err error
 	DB, err = gorm.Open(postgres.Open(connStr), &gorm.Config{})
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		return fmt.Errorf("failed to connect to database: %w", err)
 	}
 	log.Println("Connected to PostgreSQL database with GORM.")
 	if err := DB.AutoMigrate(
@@ -23,15 +40,28 @@ func InitDB(connStr string) {
 		&Inventory{},
 		&StockMovement{},
 		&Supplier{},
+		&SupplierContact{},
+		&SupplierProduct{},
+		&SupplierPriceBreak{},
 		&PurchaseOrder{},
 		&POItem{},
 		&Order{},
 		&OrderItem{},
 		&AuditLog{},
+		&AlertAcknowledgement{},
+		&StockSnapshotRun{},
+		&StockSnapshot{},
+		&ClassificationRun{},
+		&ProductClass{},
+		&OutboxEvent{},
+		&WebhookSubscription{},
+		&WebhookDelivery{},
+		&WSEvent{},
 	); err != nil {
-		log.Fatalf("Auto-migration failed: %v", err)
+		return fmt.Errorf("auto-migration failed: %w", err)
 	}
 	log.Println("Database migration completed successfully.")
+	return nil
 }
 func LogAudit(action, entity string, entityID uint, userID, details string) {
 	log := AuditLog{
@@ -0,0 +1,223 @@
+// Package documents renders purchase orders, invoices and packing slips as
+// PDF files with the company's letterhead.
+package documents
+
+import (
+	"fmt"
+	"myapp/internal"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Company is the letterhead printed on every document.
+type Company struct {
+	Name     string
+	Address  []string
+	Phone    string
+	Email    string
+	TaxID    string
+	LogoPath string // JPEG or PNG
+}
+
+// CompanyFromEnv reads the letterhead from COMPANY_NAME, COMPANY_ADDRESS
+// (lines separated by "|"), COMPANY_PHONE, COMPANY_EMAIL, COMPANY_TAX_ID and
+// COMPANY_LOGO.
+func CompanyFromEnv() Company {
+	c := Company{
+		Name:     os.Getenv("COMPANY_NAME"),
+		Phone:    os.Getenv("COMPANY_PHONE"),
+		Email:    os.Getenv("COMPANY_EMAIL"),
+		TaxID:    os.Getenv("COMPANY_TAX_ID"),
+		LogoPath: os.Getenv("COMPANY_LOGO"),
+	}
+	for _, line := range strings.Split(os.Getenv("COMPANY_ADDRESS"), "|") {
+		if line = strings.TrimSpace(line); line != "" {
+			c.Address = append(c.Address, line)
+		}
+	}
+	return c
+}
+
+// invoiceTerms reads the tax rate in percent from INVOICE_TAX_RATE (default
+// 0) and the payment terms from INVOICE_PAYMENT_TERMS_DAYS (default 30).
+func invoiceTerms() (taxRate float64, termsDays int) {
+	termsDays = 30
+	if v, err := strconv.ParseFloat(os.Getenv("INVOICE_TAX_RATE"), 64); err == nil && v > 0 {
+		taxRate = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("INVOICE_PAYMENT_TERMS_DAYS")); err == nil && v >= 0 {
+		termsDays = v
+	}
+	return taxRate, termsDays
+}
+
+const dateFormat = "2006-01-02"
+
+func formatDate(t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return "-"
+	}
+	return t.Format(dateFormat)
+}
+
+// nonEmpty drops empty lines from an address block.
+func nonEmpty(lines ...string) []string {
+	out := make([]string, 0, len(lines))
+	for _, line := range lines {
+		if line = strings.TrimSpace(line); line != "" {
+			out = append(out, line)
+		}
+	}
+	return out
+}
+
+// renderPurchaseOrder renders a PO for its supplier. po needs Supplier and
+// Items.Product loaded; warehouse is the delivery address, nil when the PO
+// has none yet. supplierSKUs maps product IDs to the supplier's own codes.
+func renderPurchaseOrder(c Company, po internal.PurchaseOrder, warehouse *internal.Warehouse, supplierSKUs map[uint]string) *pdf {
+	d := newDocument(c, "Purchase Order", po.PONumber)
+	d.blocks(
+		block{"PO number", []string{po.PONumber}},
+		block{"Order date", []string{formatDate(&po.OrderDate)}},
+		block{"Expected delivery", []string{formatDate(po.ExpectedAt)}},
+		block{"Status", []string{po.Status}},
+	)
+
+	supplier := po.Supplier
+	deliverTo := []string{"To be confirmed"}
+	if warehouse != nil {
+		deliverTo = nonEmpty(warehouse.Name, warehouse.Location)
+	}
+	d.blocks(
+		block{"Supplier", nonEmpty(append(append([]string{supplier.Name, supplier.ContactName},
+			strings.Split(supplier.Address, "\n")...), supplier.Phone, supplier.Email)...)},
+		block{"Deliver to", deliverTo},
+	)
+
+	d.startTable(
+		column{title: "#", width: 24, right: true},
+		column{title: "SKU", width: 80},
+		column{title: "Supplier SKU", width: 80},
+		column{title: "Description"},
+		column{title: "Qty", width: 45, right: true},
+		column{title: "Unit price", width: 65, right: true},
+		column{title: "Amount", width: 70, right: true},
+	)
+	var sum float64
+	for i, item := range po.Items {
+		amount := float64(item.Quantity) * item.UnitPrice
+		sum += amount
+		d.row(strconv.Itoa(i+1), item.Product.SKU, supplierSKUs[item.ProductID], item.Product.Name,
+			strconv.Itoa(item.Quantity), money(item.UnitPrice), money(amount))
+	}
+	d.totals(total{"Total", money(sum), true})
+	d.paragraph("Please quote the PO number " + po.PONumber + " on all delivery notes and invoices.")
+	return d.finish()
+}
+
+// renderInvoice renders the invoice for an order with Items.Product loaded.
+// The invoice is dated when the order shipped, or when it was placed if it
+// has not shipped yet.
+func renderInvoice(c Company, order internal.Order, taxRate float64, termsDays int) *pdf {
+	number := "INV-" + order.OrderNumber
+	date := order.OrderDate
+	if order.ShippedAt != nil {
+		date = *order.ShippedAt
+	}
+	due := date.AddDate(0, 0, termsDays)
+
+	d := newDocument(c, "Invoice", number)
+	d.blocks(
+		block{"Invoice number", []string{number}},
+		block{"Invoice date", []string{formatDate(&date)}},
+		block{"Due date", []string{formatDate(&due)}},
+		block{"Order number", []string{order.OrderNumber}},
+	)
+	d.blocks(block{"Bill to", nonEmpty(order.CustomerName, order.CustomerEmail)})
+
+	d.startTable(
+		column{title: "SKU", width: 90},
+		column{title: "Description"},
+		column{title: "Qty", width: 50, right: true},
+		column{title: "Unit price", width: 70, right: true},
+		column{title: "Amount", width: 80, right: true},
+	)
+	var subtotal float64
+	for _, item := range order.Items {
+		amount := float64(item.Quantity) * item.UnitPrice
+		subtotal += amount
+		d.row(item.Product.SKU, item.Product.Name, strconv.Itoa(item.Quantity), money(item.UnitPrice), money(amount))
+	}
+
+	tax := subtotal * taxRate / 100
+	lines := []total{{"Subtotal", money(subtotal), false}}
+	if taxRate > 0 {
+		lines = append(lines, total{fmt.Sprintf("Tax (%s%%)", strconv.FormatFloat(taxRate, 'f', -1, 64)), money(tax), false})
+	}
+	lines = append(lines, total{"Total due", money(subtotal + tax), true})
+	d.totals(lines...)
+
+	if termsDays > 0 {
+		d.paragraph(fmt.Sprintf("Payment is due within %d days, by %s. Please reference %s with your payment.",
+			termsDays, formatDate(&due), number))
+	}
+	return d.finish()
+}
+
+// renderPackingSlip renders one packing slip per warehouse the order ships
+// from, each starting on a new page. Prices are left out.
+func renderPackingSlip(c Company, order internal.Order, warehouses map[uint]internal.Warehouse) *pdf {
+	byWarehouse := make(map[uint][]internal.OrderItem)
+	var ids []uint
+	for _, item := range order.Items {
+		if _, ok := byWarehouse[item.WarehouseID]; !ok {
+			ids = append(ids, item.WarehouseID)
+		}
+		byWarehouse[item.WarehouseID] = append(byWarehouse[item.WarehouseID], item)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	d := newDocument(c, "Packing Slip", order.OrderNumber)
+	for i, id := range ids {
+		if i > 0 {
+			d.addPage()
+			d.header()
+		}
+		warehouse := warehouses[id]
+		d.blocks(
+			block{"Order number", []string{order.OrderNumber}},
+			block{"Order date", []string{formatDate(&order.OrderDate)}},
+			block{"Ship date", []string{formatDate(order.ShippedAt)}},
+			block{"Package", []string{fmt.Sprintf("%d of %d", i+1, len(ids))}},
+		)
+		d.blocks(
+			block{"Ship from", nonEmpty(warehouse.Name, warehouse.Location)},
+			block{"Ship to", nonEmpty(order.CustomerName, order.CustomerEmail)},
+		)
+
+		d.startTable(
+			column{title: "SKU", width: 90},
+			column{title: "Description"},
+			column{title: "Unit", width: 50},
+			column{title: "Ordered", width: 55, right: true},
+			column{title: "Backordered", width: 70, right: true},
+			column{title: "Shipped", width: 55, right: true},
+		)
+		units, backordered := 0, 0
+		for _, item := range byWarehouse[id] {
+			shipped := item.Quantity - item.BackorderedQuantity
+			units += shipped
+			backordered += item.BackorderedQuantity
+			d.row(item.Product.SKU, item.Product.Name, item.Product.Unit, strconv.Itoa(item.Quantity),
+				strconv.Itoa(item.BackorderedQuantity), strconv.Itoa(shipped))
+		}
+		d.totals(total{"Units shipped", strconv.Itoa(units), true})
+		if backordered > 0 {
+			d.paragraph(fmt.Sprintf("%d backordered units will be sent separately when they are back in stock.", backordered))
+		}
+	}
+	return d.finish()
+}
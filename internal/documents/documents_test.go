@@ -0,0 +1,232 @@
+package documents
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"myapp/internal"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteString(t *testing.T) {
+	var buf bytes.Buffer
+	writeString(&buf, "a(b)\\ é€\t✓")
+	if want := "(a\\(b\\)\\\\ \xe9\x80 ?)"; buf.String() != want {
+		t.Errorf("writeString = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestTextWidth(t *testing.T) {
+	if got := textWidth(regular, 10, "Hello"); math.Abs(got-22.78) > 1e-9 {
+		t.Errorf("regular width = %v, want 22.78", got)
+	}
+	if got := textWidth(bold, 10, "Hello"); math.Abs(got-24.45) > 1e-9 {
+		t.Errorf("bold width = %v, want 24.45", got)
+	}
+	if s := fit(regular, 9, strings.Repeat("W", 50), 60); textWidth(regular, 9, s) > 60 || !strings.HasSuffix(s, "...") {
+		t.Errorf("fit = %q", s)
+	}
+	if lines := wrap(regular, 9, "one two three four", textWidth(regular, 9, "one two three")); len(lines) != 2 || lines[0] != "one two three" {
+		t.Errorf("wrap = %q", lines)
+	}
+}
+
+func TestMoney(t *testing.T) {
+	tests := map[float64]string{
+		0:           "0.00",
+		0.004:       "0.00",
+		-5:          "-5.00",
+		999.999:     "1,000.00",
+		1234567.891: "1,234,567.89",
+		-98765.4:    "-98,765.40",
+	}
+	for v, want := range tests {
+		if got := money(v); got != want {
+			t.Errorf("money(%v) = %q, want %q", v, got, want)
+		}
+	}
+}
+
+// checkStructure verifies the cross-reference table points at every object
+// and returns the number of pages.
+func checkStructure(t *testing.T, out []byte) int {
+	t.Helper()
+	if !bytes.HasPrefix(out, []byte("%PDF-1.4\n")) || !bytes.HasSuffix(out, []byte("%%EOF\n")) {
+		t.Fatal("missing PDF header or trailer")
+	}
+	m := regexp.MustCompile(`startxref\n(\d+)\n`).FindSubmatch(out)
+	if m == nil {
+		t.Fatal("missing startxref")
+	}
+	xref, _ := strconv.Atoi(string(m[1]))
+	if !bytes.HasPrefix(out[xref:], []byte("xref\n")) {
+		t.Fatalf("startxref %d does not point at the xref table", xref)
+	}
+	lines := strings.Split(string(out[xref:]), "\n")
+	var count int
+	fmt.Sscanf(lines[1], "0 %d", &count)
+	for n := 1; n < count; n++ {
+		entry := lines[2+n]
+		if len(entry)+1 != 20 {
+			t.Fatalf("xref entry %q is not 20 bytes", entry)
+		}
+		offset, _ := strconv.Atoi(entry[:10])
+		if want := fmt.Sprintf("%d 0 obj\n", n); !bytes.HasPrefix(out[offset:], []byte(want)) {
+			t.Fatalf("object %d not at offset %d", n, offset)
+		}
+	}
+	return bytes.Count(out, []byte("/Type /Page "))
+}
+
+func render(t *testing.T, doc *pdf) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if _, err := doc.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func contains(doc *pdf, page int, text string) bool {
+	var buf bytes.Buffer
+	writeString(&buf, text)
+	return bytes.Contains(doc.pages[page].Bytes(), buf.Bytes())
+}
+
+func testOrder(lines int) internal.Order {
+	shipped := time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)
+	order := internal.Order{
+		OrderNumber:  "SO-1001",
+		CustomerName: "ABC Corp (Retail)",
+		OrderDate:    time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+		ShippedAt:    &shipped,
+	}
+	for i := 0; i < lines; i++ {
+		order.Items = append(order.Items, internal.OrderItem{
+			ProductID:   uint(i + 1),
+			WarehouseID: uint(i%2 + 1),
+			Quantity:    2,
+			UnitPrice:   25,
+			Product:     internal.Product{SKU: fmt.Sprintf("WGT-%03d", i+1), Name: "Widget", Unit: "piece"},
+		})
+	}
+	return order
+}
+
+func TestInvoice(t *testing.T) {
+	c := Company{Name: "Acme Ltd", Address: []string{"1 Main St", "Springfield"}, TaxID: "GB123"}
+	doc := renderInvoice(c, testOrder(2), 20, 30)
+	if pages := checkStructure(t, render(t, doc)); pages != 1 {
+		t.Fatalf("pages = %d, want 1", pages)
+	}
+	for _, text := range []string{"INV-SO-1001", "ABC Corp (Retail)", "2026-03-10", "2026-04-09", "Tax (20%)", "100.00", "120.00", "Tax ID: GB123", "Page 1 of 1"} {
+		if !contains(doc, 0, text) {
+			t.Errorf("invoice is missing %q", text)
+		}
+	}
+}
+
+func TestLongTableBreaksPages(t *testing.T) {
+	doc := renderInvoice(Company{Name: "Acme Ltd"}, testOrder(120), 0, 30)
+	pages := checkStructure(t, render(t, doc))
+	if pages < 3 {
+		t.Fatalf("pages = %d, want at least 3", pages)
+	}
+	if !contains(doc, pages-1, fmt.Sprintf("Page %d of %d", pages, pages)) {
+		t.Error("last page is missing its page number")
+	}
+	totalPage := 0
+	for i := range doc.pages {
+		if contains(doc, i, "Total due") {
+			totalPage = i
+		}
+	}
+	if totalPage == 0 {
+		t.Fatal("total not after the first page")
+	}
+	// The table header is repeated after every page break within the table
+	for i := 1; i <= totalPage; i++ {
+		if !contains(doc, i, "Invoice INV-SO-1001 (continued)") || !contains(doc, i, "Unit price") {
+			t.Errorf("page %d is missing the continued header", i+1)
+		}
+	}
+	if contains(doc, 0, "Tax (") {
+		t.Error("tax line shown with a zero tax rate")
+	}
+}
+
+func TestPackingSlip(t *testing.T) {
+	order := testOrder(3)
+	order.Items[0].BackorderedQuantity = 1
+	warehouses := map[uint]internal.Warehouse{
+		1: {ID: 1, Name: "Main", Location: "Leeds"},
+		2: {ID: 2, Name: "North", Location: "York"},
+	}
+	doc := renderPackingSlip(Company{Name: "Acme Ltd"}, order, warehouses)
+	if pages := checkStructure(t, render(t, doc)); pages != 2 {
+		t.Fatalf("pages = %d, want one per warehouse", pages)
+	}
+	if !contains(doc, 0, "Leeds") || !contains(doc, 0, "1 of 2") || !contains(doc, 0, "3") {
+		t.Error("first slip is missing its warehouse, package number or units")
+	}
+	if !contains(doc, 0, "1 backordered units will be sent separately when they are back in stock.") {
+		t.Error("first slip is missing the backorder note")
+	}
+	if !contains(doc, 1, "York") || contains(doc, 1, "backordered units") {
+		t.Error("second slip has the wrong warehouse or a backorder note")
+	}
+	if contains(doc, 0, "25.00") {
+		t.Error("packing slip shows prices")
+	}
+}
+
+func TestPurchaseOrderLogo(t *testing.T) {
+	dir := t.TempDir()
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 2))
+	img.Set(0, 0, color.NRGBA{255, 0, 0, 255})
+	var pngData, jpegData bytes.Buffer
+	png.Encode(&pngData, img)
+	jpeg.Encode(&jpegData, img, nil)
+	os.WriteFile(filepath.Join(dir, "logo.png"), pngData.Bytes(), 0o644)
+	os.WriteFile(filepath.Join(dir, "logo.jpg"), jpegData.Bytes(), 0o644)
+
+	po := internal.PurchaseOrder{
+		PONumber:  "PO-7",
+		Status:    "pending",
+		OrderDate: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+		Supplier:  internal.Supplier{Name: "Parts Inc", Address: "2 Dock Rd\nHull"},
+		Items: []internal.POItem{
+			{ProductID: 1, Quantity: 500, UnitPrice: 15, Product: internal.Product{SKU: "WGT-001", Name: "Widget"}},
+		},
+	}
+	for file, filter := range map[string]string{"logo.png": "/ColorSpace /DeviceRGB /BitsPerComponent 8 /Filter /FlateDecode", "logo.jpg": "/Filter /DCTDecode"} {
+		c := Company{Name: "Acme Ltd", LogoPath: filepath.Join(dir, file)}
+		doc := renderPurchaseOrder(c, po, nil, map[uint]string{1: "P-99"})
+		out := render(t, doc)
+		checkStructure(t, out)
+		if len(doc.images) != 1 || !bytes.Contains(out, []byte("/Subtype /Image /Width 4 /Height 2")) || !bytes.Contains(out, []byte(filter)) {
+			t.Errorf("%s: logo not embedded with %s", file, filter)
+		}
+		for _, text := range []string{"PO-7", "Hull", "To be confirmed", "P-99", "7,500.00"} {
+			if !contains(doc, 0, text) {
+				t.Errorf("purchase order is missing %q", text)
+			}
+		}
+	}
+
+	// A missing logo is skipped rather than failing the document
+	doc := renderPurchaseOrder(Company{LogoPath: filepath.Join(dir, "missing.png")}, po, nil, nil)
+	if len(doc.images) != 0 {
+		t.Error("missing logo embedded")
+	}
+}
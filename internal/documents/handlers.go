@@ -0,0 +1,144 @@
+package documents
+
+import (
+	"bytes"
+	"fmt"
+	"myapp/internal"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// GetPurchaseOrderPDF renders a purchase order: GET /purchase-orders/{id}/pdf
+func GetPurchaseOrderPDF(w http.ResponseWriter, r *http.Request) {
+	id := extractID(r.URL.Path, "/purchase-orders/")
+	if id == 0 {
+		http.Error(w, "Invalid purchase order ID", http.StatusBadRequest)
+		return
+	}
+	var po internal.PurchaseOrder
+	if err := internal.DB.Preload("Supplier").Preload("Items.Product").First(&po, id).Error; err != nil {
+		http.Error(w, "Purchase order not found", http.StatusNotFound)
+		return
+	}
+
+	var warehouse *internal.Warehouse
+	if po.WarehouseID != 0 {
+		warehouse = &internal.Warehouse{}
+		if err := internal.DB.First(warehouse, po.WarehouseID).Error; err != nil {
+			http.Error(w, "Failed to fetch warehouse", http.StatusInternalServerError)
+			return
+		}
+	}
+	productIDs := make([]uint, len(po.Items))
+	for i, item := range po.Items {
+		productIDs[i] = item.ProductID
+	}
+	var catalogue []internal.SupplierProduct
+	if err := internal.DB.Where("supplier_id = ? AND product_id IN ?", po.SupplierID, productIDs).
+		Find(&catalogue).Error; err != nil {
+		http.Error(w, "Failed to fetch supplier catalogue", http.StatusInternalServerError)
+		return
+	}
+	supplierSKUs := make(map[uint]string, len(catalogue))
+	for _, entry := range catalogue {
+		supplierSKUs[entry.ProductID] = entry.SupplierSKU
+	}
+
+	writePDF(w, po.PONumber, renderPurchaseOrder(CompanyFromEnv(), po, warehouse, supplierSKUs))
+}
+
+// GetInvoicePDF renders the invoice for an order: GET /orders/{id}/invoice.pdf
+func GetInvoicePDF(w http.ResponseWriter, r *http.Request) {
+	order, ok := loadOrder(w, r)
+	if !ok {
+		return
+	}
+	taxRate, termsDays := invoiceTerms()
+	writePDF(w, "INV-"+order.OrderNumber, renderInvoice(CompanyFromEnv(), order, taxRate, termsDays))
+}
+
+// GetPackingSlipPDF renders the packing slips for an order, one per shipping
+// warehouse: GET /orders/{id}/packing-slip.pdf?warehouse_id=
+func GetPackingSlipPDF(w http.ResponseWriter, r *http.Request) {
+	order, ok := loadOrder(w, r)
+	if !ok {
+		return
+	}
+	if v := r.URL.Query().Get("warehouse_id"); v != "" {
+		warehouseID, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid warehouse_id", http.StatusBadRequest)
+			return
+		}
+		var items []internal.OrderItem
+		for _, item := range order.Items {
+			if item.WarehouseID == uint(warehouseID) {
+				items = append(items, item)
+			}
+		}
+		if len(items) == 0 {
+			http.Error(w, "Order has no items shipping from this warehouse", http.StatusNotFound)
+			return
+		}
+		order.Items = items
+	}
+
+	var ids []uint
+	for _, item := range order.Items {
+		ids = append(ids, item.WarehouseID)
+	}
+	var list []internal.Warehouse
+	if err := internal.DB.Where("id IN ?", ids).Find(&list).Error; err != nil {
+		http.Error(w, "Failed to fetch warehouses", http.StatusInternalServerError)
+		return
+	}
+	warehouses := make(map[uint]internal.Warehouse, len(list))
+	for _, wh := range list {
+		warehouses[wh.ID] = wh
+	}
+
+	writePDF(w, "packing-slip-"+order.OrderNumber, renderPackingSlip(CompanyFromEnv(), order, warehouses))
+}
+
+// loadOrder fetches the order in the path with its items. Cancelled orders
+// get no documents.
+func loadOrder(w http.ResponseWriter, r *http.Request) (internal.Order, bool) {
+	var order internal.Order
+	id := extractID(r.URL.Path, "/orders/")
+	if id == 0 {
+		http.Error(w, "Invalid order ID", http.StatusBadRequest)
+		return order, false
+	}
+	if err := internal.DB.Preload("Items.Product").First(&order, id).Error; err != nil {
+		http.Error(w, "Order not found", http.StatusNotFound)
+		return order, false
+	}
+	if order.Status == "cancelled" {
+		http.Error(w, "Order is cancelled", http.StatusConflict)
+		return order, false
+	}
+	return order, true
+}
+
+// writePDF sends the document inline so browsers preview it.
+func writePDF(w http.ResponseWriter, name string, doc *pdf) {
+	var buf bytes.Buffer
+	if _, err := doc.WriteTo(&buf); err != nil {
+		http.Error(w, "Failed to render document", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", name+".pdf"))
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	buf.WriteTo(w)
+}
+
+func extractID(path, prefix string) int {
+	idStr := strings.TrimPrefix(path, prefix)
+	if idx := strings.Index(idStr, "/"); idx != -1 {
+		idStr = idStr[:idx]
+	}
+	id, _ := strconv.Atoi(idStr)
+	return id
+}
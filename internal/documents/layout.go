@@ -0,0 +1,273 @@
+package documents
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	margin       = 40.0
+	contentWidth = pageWidth - 2*margin
+	footerTop    = pageHeight - 50 // content stops above the footer
+	rowHeight    = 16.0
+)
+
+// document lays out a business document top to bottom, starting a new page
+// when the next element does not fit.
+type document struct {
+	*pdf
+	company   Company
+	logo      *pdfImage
+	title     string
+	reference string   // shown in the footer, e.g. the PO number
+	y         float64  // top of the free space on the current page
+	columns   []column // header of the table being drawn, repeated on new pages
+}
+
+// block is a titled group of lines, such as an address.
+type block struct {
+	title string
+	lines []string
+}
+
+type column struct {
+	title string
+	width float64 // 0 takes the space the other columns leave
+	right bool
+}
+
+type total struct {
+	label, value string
+	bold         bool
+}
+
+func newDocument(c Company, title, reference string) *document {
+	d := &document{pdf: newPDF(), company: c, title: title, reference: reference}
+	if c.LogoPath != "" {
+		logo, err := loadImage(c.LogoPath)
+		if err != nil {
+			log.Printf("documents: failed to load logo %s: %v", c.LogoPath, err)
+		}
+		d.logo = logo
+	}
+	d.header()
+	return d
+}
+
+// header draws the logo and company details and the document title.
+func (d *document) header() {
+	right := pageWidth - margin
+	logoHeight := 0.0
+	if d.logo != nil {
+		// Fit the logo into 160x60 keeping its aspect ratio
+		scale := math.Min(160/float64(d.logo.width), 60/float64(d.logo.height))
+		w, h := float64(d.logo.width)*scale, float64(d.logo.height)*scale
+		d.drawImage(d.logo, margin, margin, w, h)
+		logoHeight = h
+	}
+
+	y := margin + 12
+	d.textRight(right, y, bold, 12, d.company.Name)
+	details := append([]string{}, d.company.Address...)
+	for _, line := range []string{d.company.Phone, d.company.Email} {
+		if line != "" {
+			details = append(details, line)
+		}
+	}
+	if d.company.TaxID != "" {
+		details = append(details, "Tax ID: "+d.company.TaxID)
+	}
+	for _, line := range details {
+		y += 11
+		d.textRight(right, y, regular, 9, line)
+	}
+
+	d.y = math.Max(y, margin+logoHeight) + 36
+	d.text(margin, d.y, bold, 20, d.title)
+	d.y += 14
+	d.line(margin, d.y, right, d.y, 1)
+	d.y += 16
+}
+
+// newPage continues the document on a new page.
+func (d *document) newPage() {
+	d.addPage()
+	d.y = margin + 10
+	d.text(margin, d.y, bold, 10, d.title+" "+d.reference+" (continued)")
+	d.y += 20
+}
+
+// ensure starts a new page unless height points fit above the footer.
+func (d *document) ensure(height float64) bool {
+	if d.y+height <= footerTop {
+		return false
+	}
+	d.newPage()
+	return true
+}
+
+// blocks draws blocks side by side in equal columns.
+func (d *document) blocks(blocks ...block) {
+	height := 0
+	for _, b := range blocks {
+		if len(b.lines) > height {
+			height = len(b.lines)
+		}
+	}
+	d.ensure(float64(height)*12 + 30)
+
+	width := contentWidth / float64(len(blocks))
+	for i, b := range blocks {
+		x := margin + float64(i)*width
+		d.text(x, d.y, bold, 8, strings.ToUpper(b.title))
+		for j, line := range b.lines {
+			d.text(x, d.y+14+float64(j)*12, regular, 10, fit(regular, 10, line, width-10))
+		}
+	}
+	d.y += float64(height)*12 + 22
+}
+
+// startTable sets the table columns and draws its header row.
+func (d *document) startTable(columns ...column) {
+	fixed := 0.0
+	for _, c := range columns {
+		fixed += c.width
+	}
+	d.columns = make([]column, len(columns))
+	for i, c := range columns {
+		if c.width == 0 {
+			c.width = contentWidth - fixed
+		}
+		d.columns[i] = c
+	}
+	d.ensure(2 * rowHeight)
+	d.tableHeader()
+}
+
+func (d *document) tableHeader() {
+	d.fillRect(margin, d.y, contentWidth, rowHeight+2, 0.9)
+	d.cells(bold, d.y+12, columnTitles(d.columns))
+	d.y += rowHeight + 2
+}
+
+func columnTitles(columns []column) []string {
+	titles := make([]string, len(columns))
+	for i, c := range columns {
+		titles[i] = c.title
+	}
+	return titles
+}
+
+// row draws a table row, repeating the header on a new page if needed.
+func (d *document) row(values ...string) {
+	if d.ensure(rowHeight) {
+		d.tableHeader()
+	}
+	d.cells(regular, d.y+11, values)
+	d.y += rowHeight
+	d.line(margin, d.y, pageWidth-margin, d.y, 0.25)
+}
+
+func (d *document) cells(f font, baseline float64, values []string) {
+	x := margin
+	for i, c := range d.columns {
+		if i < len(values) {
+			s := fit(f, 9, values[i], c.width-8)
+			if c.right {
+				d.textRight(x+c.width-4, baseline, f, 9, s)
+			} else {
+				d.text(x+4, baseline, f, 9, s)
+			}
+		}
+		x += c.width
+	}
+}
+
+// totals draws labelled amounts aligned with the right edge of the table.
+func (d *document) totals(lines ...total) {
+	d.ensure(float64(len(lines))*rowHeight + 8)
+	right := pageWidth - margin
+	d.y += 8
+	for _, t := range lines {
+		f := regular
+		if t.bold {
+			f = bold
+			d.line(right-200, d.y, right, d.y, 0.5)
+		}
+		d.textRight(right-100, d.y+11, f, 10, t.label)
+		d.textRight(right-4, d.y+11, f, 10, t.value)
+		d.y += rowHeight
+	}
+	d.y += 10
+}
+
+// paragraph draws text wrapped to the content width.
+func (d *document) paragraph(text string) {
+	for _, line := range wrap(regular, 9, text, contentWidth) {
+		d.ensure(12)
+		d.text(margin, d.y+9, regular, 9, line)
+		d.y += 12
+	}
+	d.y += 8
+}
+
+// finish adds the footer with page numbers to every page.
+func (d *document) finish() *pdf {
+	for i := range d.pages {
+		d.current = i
+		d.line(margin, pageHeight-42, pageWidth-margin, pageHeight-42, 0.5)
+		d.text(margin, pageHeight-30, regular, 8, strings.TrimSpace(d.company.Name+"  "+d.title+" "+d.reference))
+		d.textRight(pageWidth-margin, pageHeight-30, regular, 8, fmt.Sprintf("Page %d of %d", i+1, len(d.pages)))
+	}
+	return d.pdf
+}
+
+// fit shortens s with an ellipsis until it is at most width points wide.
+func fit(f font, size float64, s string, width float64) string {
+	if textWidth(f, size, s) <= width {
+		return s
+	}
+	runes := []rune(s)
+	for len(runes) > 0 && textWidth(f, size, string(runes)+"...") > width {
+		runes = runes[:len(runes)-1]
+	}
+	return strings.TrimSpace(string(runes)) + "..."
+}
+
+// wrap breaks text into lines at most width points wide. Words longer than
+// a line are shortened with fit.
+func wrap(f font, size float64, text string, width float64) []string {
+	var lines []string
+	for _, paragraph := range strings.Split(text, "\n") {
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			if line != "" && textWidth(f, size, line+" "+word) > width {
+				lines = append(lines, line)
+				line = ""
+			}
+			if line == "" {
+				line = fit(f, size, word, width)
+			} else {
+				line += " " + word
+			}
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// money formats an amount with two decimals and thousands separators.
+func money(v float64) string {
+	s := strconv.FormatFloat(math.Abs(v), 'f', 2, 64)
+	whole, cents := s[:len(s)-3], s[len(s)-3:]
+	for i := len(whole) - 3; i > 0; i -= 3 {
+		whole = whole[:i] + "," + whole[i:]
+	}
+	if v < -0.005 {
+		return "-" + whole + cents
+	}
+	return whole + cents
+}
@@ -0,0 +1,249 @@
+package documents
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"os"
+)
+
+// A4 in points. Coordinates passed to the drawing methods are measured from
+// the top-left corner, text y is the baseline.
+const (
+	pageWidth  = 595.28
+	pageHeight = 841.89
+)
+
+type font int
+
+const (
+	regular font = iota
+	bold
+)
+
+// pdf is a minimal PDF 1.4 writer: Helvetica text, lines, filled rectangles
+// and images on any number of pages. Content streams are kept in memory
+// until WriteTo, so earlier pages can still be drawn on by setting current.
+type pdf struct {
+	pages   []*bytes.Buffer
+	current int // index of the page being drawn on
+	images  []*pdfImage
+}
+
+// pdfImage is an image XObject, either a JPEG passed through as is or
+// pixels compressed as RGB.
+type pdfImage struct {
+	width, height int
+	colorSpace    string
+	filter        string
+	data          []byte
+}
+
+func newPDF() *pdf {
+	p := &pdf{}
+	p.addPage()
+	return p
+}
+
+func (p *pdf) addPage() {
+	p.pages = append(p.pages, &bytes.Buffer{})
+	p.current = len(p.pages) - 1
+}
+
+func (p *pdf) page() *bytes.Buffer {
+	return p.pages[p.current]
+}
+
+func (p *pdf) text(x, y float64, f font, size float64, s string) {
+	fmt.Fprintf(p.page(), "BT /F%d %.2f Tf %.2f %.2f Td ", f+1, size, x, pageHeight-y)
+	writeString(p.page(), s)
+	p.page().WriteString(" Tj ET\n")
+}
+
+// textRight draws s ending at x.
+func (p *pdf) textRight(x, y float64, f font, size float64, s string) {
+	p.text(x-textWidth(f, size, s), y, f, size, s)
+}
+
+func (p *pdf) line(x1, y1, x2, y2, width float64) {
+	fmt.Fprintf(p.page(), "%.2f w %.2f %.2f m %.2f %.2f l S\n", width, x1, pageHeight-y1, x2, pageHeight-y2)
+}
+
+// fillRect fills a rectangle in a shade of gray from 0 (black) to 1 (white).
+func (p *pdf) fillRect(x, y, w, h, gray float64) {
+	fmt.Fprintf(p.page(), "q %.2f g %.2f %.2f %.2f %.2f re f Q\n", gray, x, pageHeight-y-h, w, h)
+}
+
+// drawImage draws img scaled into a w by h box.
+func (p *pdf) drawImage(img *pdfImage, x, y, w, h float64) {
+	n := 0
+	for n < len(p.images) && p.images[n] != img {
+		n++
+	}
+	if n == len(p.images) {
+		p.images = append(p.images, img)
+	}
+	fmt.Fprintf(p.page(), "q %.2f 0 0 %.2f %.2f %.2f cm /Im%d Do Q\n", w, h, x, pageHeight-y-h, n+1)
+}
+
+// WriteTo writes the document. Objects 1 to 4 are the catalog, the page
+// tree and the two fonts, followed by the images and a page and content
+// stream for every page.
+func (p *pdf) WriteTo(w io.Writer) (int64, error) {
+	var out bytes.Buffer
+	var offsets []int
+	object := func(body string, stream []byte) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\n", len(offsets), body)
+		if stream != nil {
+			out.WriteString("stream\n")
+			out.Write(stream)
+			out.WriteString("\nendstream\n")
+		}
+		out.WriteString("endobj\n")
+	}
+
+	firstImage := 5
+	firstPage := firstImage + len(p.images)
+	var kids, xobjects bytes.Buffer
+	for i := range p.pages {
+		fmt.Fprintf(&kids, "%d 0 R ", firstPage+2*i)
+	}
+	for i := range p.images {
+		fmt.Fprintf(&xobjects, "/Im%d %d 0 R ", i+1, firstImage+i)
+	}
+
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	object("<< /Type /Catalog /Pages 2 0 R >>", nil)
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", kids.String(), len(p.pages)), nil)
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>", nil)
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>", nil)
+	for _, img := range p.images {
+		object(fmt.Sprintf("<< /Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /%s /BitsPerComponent 8 /Filter /%s /Length %d >>",
+			img.width, img.height, img.colorSpace, img.filter, len(img.data)), img.data)
+	}
+	resources := fmt.Sprintf("<< /Font << /F1 3 0 R /F2 4 0 R >> /XObject << %s>> >>", xobjects.String())
+	for i, content := range p.pages {
+		var compressed bytes.Buffer
+		zw := zlib.NewWriter(&compressed)
+		zw.Write(content.Bytes())
+		zw.Close()
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] /Resources %s /Contents %d 0 R >>",
+			pageWidth, pageHeight, resources, firstPage+2*i+1), nil)
+		object(fmt.Sprintf("<< /Filter /FlateDecode /Length %d >>", compressed.Len()), compressed.Bytes())
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return out.WriteTo(w)
+}
+
+// writeString writes s as a PDF string literal in WinAnsi encoding.
+func writeString(buf *bytes.Buffer, s string) {
+	buf.WriteByte('(')
+	for _, b := range winAnsi(s) {
+		if b == '(' || b == ')' || b == '\\' {
+			buf.WriteByte('\\')
+		}
+		buf.WriteByte(b)
+	}
+	buf.WriteByte(')')
+}
+
+// winAnsiExtra maps the characters of WinAnsiEncoding outside Latin-1 that
+// are likely to appear in names and addresses.
+var winAnsiExtra = map[rune]byte{
+	'€': 0x80, '‚': 0x82, '„': 0x84, '…': 0x85, '‘': 0x91, '’': 0x92,
+	'“': 0x93, '”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97, '™': 0x99,
+	'Š': 0x8a, 'Œ': 0x8c, 'Ž': 0x8e, 'š': 0x9a, 'œ': 0x9c, 'ž': 0x9e, 'Ÿ': 0x9f,
+}
+
+// winAnsi encodes s for the standard fonts. Characters they cannot show
+// become '?', control characters a space.
+func winAnsi(s string) []byte {
+	out := make([]byte, 0, len(s))
+	for _, r := range s {
+		switch {
+		case r < 0x20:
+			out = append(out, ' ')
+		case r < 0x7f || (r >= 0xa0 && r <= 0xff):
+			out = append(out, byte(r))
+		default:
+			if b, ok := winAnsiExtra[r]; ok {
+				out = append(out, b)
+			} else {
+				out = append(out, '?')
+			}
+		}
+	}
+	return out
+}
+
+// textWidth is the width of s in points.
+func textWidth(f font, size float64, s string) float64 {
+	widths := helveticaWidths
+	if f == bold {
+		widths = helveticaBoldWidths
+	}
+	var total int
+	for _, b := range winAnsi(s) {
+		if b >= 32 && b < 127 {
+			total += widths[b-32]
+		} else {
+			total += 556
+		}
+	}
+	return float64(total) * size / 1000
+}
+
+// loadImage reads a JPEG or PNG file. JPEGs are embedded unchanged; other
+// images are flattened onto white and stored as compressed RGB.
+func loadImage(path string) (*pdfImage, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if format == "jpeg" {
+		switch config.ColorModel {
+		case color.GrayModel:
+			return &pdfImage{width: config.Width, height: config.Height, colorSpace: "DeviceGray", filter: "DCTDecode", data: data}, nil
+		case color.YCbCrModel:
+			return &pdfImage{width: config.Width, height: config.Height, colorSpace: "DeviceRGB", filter: "DCTDecode", data: data}, nil
+		}
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	bounds := src.Bounds()
+	rgba := image.NewRGBA(bounds)
+	draw.Draw(rgba, bounds, image.White, image.Point{}, draw.Src)
+	draw.Draw(rgba, bounds, src, bounds.Min, draw.Over)
+
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	rgb := make([]byte, 0, len(rgba.Pix)/4*3)
+	for i := 0; i < len(rgba.Pix); i += 4 {
+		rgb = append(rgb, rgba.Pix[i:i+3]...)
+	}
+	zw.Write(rgb)
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return &pdfImage{width: bounds.Dx(), height: bounds.Dy(), colorSpace: "DeviceRGB", filter: "FlateDecode", data: compressed.Bytes()}, nil
+}
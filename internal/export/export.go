@@ -0,0 +1,208 @@
+// Package export streams tabular responses as CSV or Excel (XLSX) files for
+// clients that ask for them with ?format=csv|xlsx or an Accept header.
+package export
+
+import (
+	"encoding/csv"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Format is the representation a client asked for.
+type Format string
+
+const (
+	JSON Format = "json"
+	CSV  Format = "csv"
+	XLSX Format = "xlsx"
+)
+
+const xlsxType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+
+// flushEvery is how many rows are buffered before they are sent.
+const flushEvery = 500
+
+// Negotiate picks the format from ?format=, which wins, or the Accept
+// header. Accept entries are ranked by their q-value, earlier entries
+// winning ties, and q=0 rules a type out. Anything else is JSON.
+func Negotiate(r *http.Request) Format {
+	switch strings.ToLower(r.URL.Query().Get("format")) {
+	case "csv":
+		return CSV
+	case "xlsx", "excel":
+		return XLSX
+	case "json":
+		return JSON
+	}
+	best, bestQ := JSON, 0.0
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		var format Format
+		switch mediaType {
+		case "text/csv":
+			format = CSV
+		case xlsxType:
+			format = XLSX
+		case "application/json":
+			format = JSON
+		default:
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if q > bestQ {
+			best, bestQ = format, q
+		}
+	}
+	return best
+}
+
+// Writer writes rows of a table to a response. Values are formatted with
+// Cell.
+type Writer interface {
+	Write(values ...interface{}) error
+	// Close finishes the file. It must be called once all rows are written.
+	Close() error
+}
+
+// NewWriter starts a CSV or XLSX download named name plus the extension and
+// writes the header row.
+func NewWriter(w http.ResponseWriter, format Format, name string, headers []string) (Writer, error) {
+	filename := fmt.Sprintf("%s-%s.%s", name, time.Now().Format("20060102-150405"), format)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	flusher, _ := w.(http.Flusher)
+
+	switch format {
+	case CSV:
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		cw := &csvWriter{csv: csv.NewWriter(w), flusher: flusher}
+		return cw, cw.csv.Write(headers)
+	case XLSX:
+		w.Header().Set("Content-Type", xlsxType)
+		xw, err := newXLSXWriter(w, flusher)
+		if err != nil {
+			return nil, err
+		}
+		row := make([]interface{}, len(headers))
+		for i, h := range headers {
+			row[i] = h
+		}
+		return xw, xw.Write(row...)
+	}
+	return nil, fmt.Errorf("unsupported export format %q", format)
+}
+
+// Cell formats a value the same way in every locale: numbers use a dot
+// decimal separator and no grouping, times are RFC 3339, nil is empty.
+func Cell(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32)
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case uint:
+		return strconv.FormatUint(uint64(v), 10)
+	case uint64:
+		return strconv.FormatUint(v, 10)
+	case bool:
+		return strconv.FormatBool(v)
+	case time.Time:
+		if v.IsZero() {
+			return ""
+		}
+		return v.Format(time.RFC3339)
+	case *time.Time:
+		if v == nil {
+			return ""
+		}
+		return Cell(*v)
+	case *uint:
+		if v == nil {
+			return ""
+		}
+		return Cell(*v)
+	case *float64:
+		if v == nil {
+			return ""
+		}
+		return Cell(*v)
+	}
+	return fmt.Sprint(v)
+}
+
+// isNumber reports whether v is written as a number.
+func isNumber(v interface{}) bool {
+	switch v := v.(type) {
+	case float64, float32, int, int64, uint, uint64:
+		return true
+	case *float64:
+		return v != nil
+	case *uint:
+		return v != nil
+	}
+	return false
+}
+
+// escapeFormula stops spreadsheet apps from evaluating text cells that look
+// like formulas.
+func escapeFormula(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+type csvWriter struct {
+	csv     *csv.Writer
+	flusher http.Flusher
+	rows    int
+}
+
+func (c *csvWriter) Write(values ...interface{}) error {
+	record := make([]string, len(values))
+	for i, v := range values {
+		record[i] = Cell(v)
+		if !isNumber(v) {
+			record[i] = escapeFormula(record[i])
+		}
+	}
+	if err := c.csv.Write(record); err != nil {
+		return err
+	}
+	c.rows++
+	if c.rows%flushEvery == 0 {
+		c.flush()
+	}
+	return c.csv.Error()
+}
+
+func (c *csvWriter) flush() {
+	c.csv.Flush()
+	if c.flusher != nil {
+		c.flusher.Flush()
+	}
+}
+
+func (c *csvWriter) Close() error {
+	c.flush()
+	return c.csv.Error()
+}
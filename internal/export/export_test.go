@@ -0,0 +1,124 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		url, accept string
+		want        Format
+	}{
+		{"/orders", "", JSON},
+		{"/orders", "text/csv", CSV},
+		{"/orders", "application/json, text/csv;q=0.5", JSON},
+		{"/orders", "text/csv;q=0, application/json", JSON},
+		{"/orders", "application/json;q=0.5, text/csv", CSV},
+		{"/orders", "text/csv;q=0.8, " + xlsxType + ";q=0.9", XLSX},
+		{"/orders", "text/csv, " + xlsxType, CSV},
+		{"/orders", "text/csv;q=0", JSON},
+		{"/orders", "*/*, text/csv;q=0.1", CSV},
+		{"/orders", xlsxType, XLSX},
+		{"/orders?format=xlsx", "text/csv", XLSX},
+		{"/orders?format=CSV", "", CSV},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", tt.url, nil)
+		r.Header.Set("Accept", tt.accept)
+		if got := Negotiate(r); got != tt.want {
+			t.Errorf("Negotiate(%s, %q) = %s, want %s", tt.url, tt.accept, got, tt.want)
+		}
+	}
+}
+
+func TestCSV(t *testing.T) {
+	rec := httptest.NewRecorder()
+	w, err := NewWriter(rec, CSV, "orders", []string{"sku", "quantity", "value", "created_at", "note"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := time.Date(2026, 3, 1, 9, 30, 0, 0, time.UTC)
+	w.Write("WGT-1", -3, 1234567.5, at, "=HYPERLINK(\"x\")")
+	w.Write("WGT-2", 0, 0.1, (*time.Time)(nil), "a, b")
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "sku,quantity,value,created_at,note\n" +
+		"WGT-1,-3,1234567.5,2026-03-01T09:30:00Z,\"'=HYPERLINK(\"\"x\"\")\"\n" +
+		"WGT-2,0,0.1,,\"a, b\"\n"
+	if got := rec.Body.String(); got != want {
+		t.Errorf("body =\n%s\nwant\n%s", got, want)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "text/csv; charset=utf-8" {
+		t.Errorf("content type = %q", ct)
+	}
+	if cd := rec.Header().Get("Content-Disposition"); !strings.Contains(cd, `filename="orders-`) || !strings.HasSuffix(cd, `.csv"`) {
+		t.Errorf("content disposition = %q", cd)
+	}
+}
+
+func TestXLSX(t *testing.T) {
+	rec := httptest.NewRecorder()
+	w, err := NewWriter(rec, XLSX, "stock", []string{"sku", "quantity"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write("A&B <1>", 12.5)
+	w.Write("bell\x07 and\ttab", time.Date(2026, 3, 1, 18, 0, 0, 0, time.FixedZone("", -5*3600)))
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	body := rec.Body.Bytes()
+	zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sheet []byte
+	for _, f := range zr.File {
+		if f.Name == "xl/worksheets/sheet1.xml" {
+			rc, _ := f.Open()
+			sheet, _ = io.ReadAll(rc)
+			rc.Close()
+		}
+	}
+	var doc struct {
+		Rows []struct {
+			Cells []struct {
+				Type   string `xml:"t,attr"`
+				Style  string `xml:"s,attr"`
+				Value  string `xml:"v"`
+				Inline string `xml:"is>t"`
+			} `xml:"c"`
+		} `xml:"sheetData>row"`
+	}
+	if err := xml.Unmarshal(sheet, &doc); err != nil {
+		t.Fatalf("sheet is not valid XML: %v", err)
+	}
+	if len(doc.Rows) != 3 {
+		t.Fatalf("rows = %d, want 3", len(doc.Rows))
+	}
+	cells := doc.Rows[1].Cells
+	if cells[0].Type != "inlineStr" || cells[0].Inline != "A&B <1>" {
+		t.Errorf("text cell = %+v", cells[0])
+	}
+	if cells[1].Type != "" || cells[1].Value != "12.5" {
+		t.Errorf("number cell = %+v", cells[1])
+	}
+	cells = doc.Rows[2].Cells
+	if cells[0].Inline != "bell and\ttab" {
+		t.Errorf("control characters kept: %q", cells[0].Inline)
+	}
+	// 2026-03-01 is day 46082; 18:00 local wall time is 0.75 of a day
+	if cells[1].Type != "" || cells[1].Style != "2" || cells[1].Value != "46082.75" {
+		t.Errorf("date cell = %+v", cells[1])
+	}
+}
@@ -0,0 +1,45 @@
+package export
+
+import (
+	"log"
+	"net/http"
+
+	"gorm.io/gorm"
+)
+
+// Stream runs query and writes one row per result, scanning each into a T
+// so large exports are never held in memory. Preloads are not applied, so
+// query should join what it needs. Once the header row is sent errors can
+// only end the download early; they are logged.
+func Stream[T any](w http.ResponseWriter, format Format, name string, headers []string, query *gorm.DB, row func(*T) []interface{}) {
+	rows, err := query.Rows()
+	if err != nil {
+		http.Error(w, "Failed to export "+name, http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	ew, err := NewWriter(w, format, name, headers)
+	if err != nil {
+		log.Printf("Export %s failed: %v", name, err)
+		return
+	}
+	for rows.Next() {
+		var item T
+		if err := query.ScanRows(rows, &item); err != nil {
+			log.Printf("Export %s failed: %v", name, err)
+			return
+		}
+		if err := ew.Write(row(&item)...); err != nil {
+			log.Printf("Export %s failed: %v", name, err)
+			return
+		}
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("Export %s failed: %v", name, err)
+		return
+	}
+	if err := ew.Close(); err != nil {
+		log.Printf("Export %s failed: %v", name, err)
+	}
+}
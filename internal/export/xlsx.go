@@ -0,0 +1,177 @@
+package export
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The fixed parts of a single-sheet workbook. Text is written as inline
+// strings so rows can be streamed without a shared string table.
+var xlsxParts = []struct{ name, body string }{
+	{"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>
+<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>
+<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>
+</Types>`},
+	{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>
+</Relationships>`},
+	{"xl/workbook.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets><sheet name="Sheet1" sheetId="1" r:id="rId1"/></sheets>
+</workbook>`},
+	{"xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>
+<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>
+</Relationships>`},
+	{"xl/styles.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<numFmts count="1"><numFmt numFmtId="164" formatCode="yyyy-mm-dd hh:mm:ss"/></numFmts>
+<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>
+<fills count="1"><fill><patternFill patternType="none"/></fill></fills>
+<borders count="1"><border/></borders>
+<cellStyleXfs count="1"><xf/></cellStyleXfs>
+<cellXfs count="3"><xf/><xf fontId="1" applyFont="1"/><xf numFmtId="164" applyNumberFormat="1"/></cellXfs>
+</styleSheet>`},
+}
+
+type xlsxWriter struct {
+	zip     *zip.Writer
+	sheet   *bufio.Writer
+	flusher http.Flusher
+	rows    int
+}
+
+func newXLSXWriter(w io.Writer, flusher http.Flusher) (*xlsxWriter, error) {
+	zw := zip.NewWriter(w)
+	for _, part := range xlsxParts {
+		f, err := zw.Create(part.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(f, part.body); err != nil {
+			return nil, err
+		}
+	}
+	// The sheet is the last entry, so it can be written as rows arrive
+	f, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	sheet := bufio.NewWriter(f)
+	sheet.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`)
+	// Keep the header row visible while scrolling
+	sheet.WriteString(`<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>`)
+	sheet.WriteString(`<sheetData>`)
+	return &xlsxWriter{zip: zw, sheet: sheet, flusher: flusher}, nil
+}
+
+func (x *xlsxWriter) Write(values ...interface{}) error {
+	x.sheet.WriteString(`<row>`)
+	for _, v := range values {
+		if t, ok := timeValue(v); ok && x.rows > 0 {
+			x.sheet.WriteString(`<c s="2"><v>`)
+			x.sheet.WriteString(strconv.FormatFloat(serialDate(t), 'f', -1, 64))
+			x.sheet.WriteString(`</v></c>`)
+			continue
+		}
+		switch {
+		case isNumber(v):
+			x.sheet.WriteString(`<c><v>`)
+			x.sheet.WriteString(Cell(v))
+			x.sheet.WriteString(`</v></c>`)
+		case x.rows == 0:
+			// Header cells are bold
+			x.sheet.WriteString(`<c t="inlineStr" s="1"><is><t xml:space="preserve">`)
+			xml.EscapeText(x.sheet, []byte(xmlText(Cell(v))))
+			x.sheet.WriteString(`</t></is></c>`)
+		default:
+			x.sheet.WriteString(`<c t="inlineStr"><is><t xml:space="preserve">`)
+			xml.EscapeText(x.sheet, []byte(xmlText(Cell(v))))
+			x.sheet.WriteString(`</t></is></c>`)
+		}
+	}
+	if _, err := x.sheet.WriteString(`</row>`); err != nil {
+		return err
+	}
+	x.rows++
+	if x.rows%flushEvery == 0 {
+		return x.flush()
+	}
+	return nil
+}
+
+// timeValue returns the time in v when it is a non-zero time.Time or a
+// non-nil *time.Time.
+func timeValue(v interface{}) (time.Time, bool) {
+	switch v := v.(type) {
+	case time.Time:
+		return v, !v.IsZero()
+	case *time.Time:
+		if v != nil && !v.IsZero() {
+			return *v, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// excelEpoch is day zero of Excel's 1900 date system, as used from March
+// 1900 on.
+var excelEpoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+
+// serialDate converts t to an Excel date serial: days since excelEpoch,
+// with the time of day as the fraction. Excel dates have no zone, so the
+// wall clock time in t's own location is used, rounded to the millisecond.
+func serialDate(t time.Time) float64 {
+	wall := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+	ms := wall.Sub(excelEpoch).Round(time.Millisecond).Milliseconds()
+	return float64(ms) / float64(24*time.Hour/time.Millisecond)
+}
+
+// xmlText drops characters XML 1.0 does not allow, such as most control
+// characters, which would make the sheet unreadable.
+func xmlText(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '\t' || r == '\n' || r == '\r',
+			r >= 0x20 && r <= 0xD7FF,
+			r >= 0xE000 && r <= 0xFFFD,
+			r >= 0x10000 && r <= 0x10FFFF:
+			return r
+		}
+		return -1
+	}, s)
+}
+
+func (x *xlsxWriter) flush() error {
+	if err := x.sheet.Flush(); err != nil {
+		return err
+	}
+	if err := x.zip.Flush(); err != nil {
+		return err
+	}
+	if x.flusher != nil {
+		x.flusher.Flush()
+	}
+	return nil
+}
+
+func (x *xlsxWriter) Close() error {
+	x.sheet.WriteString(`</sheetData></worksheet>`)
+	if err := x.sheet.Flush(); err != nil {
+		return err
+	}
+	return x.zip.Close()
+}
@@ -0,0 +1,264 @@
+// Package forecast predicts product demand from the OUT stock movements
+// history and derives reorder points from the forecast.
+package forecast
+
+import (
+	"math"
+	"sort"
+)
+
+// Method names a forecasting model.
+type Method string
+
+const (
+	// MovingAverage forecasts the mean of the last window periods.
+	MovingAverage Method = "moving_average"
+	// Holt is exponential smoothing with a linear trend.
+	Holt Method = "holt"
+	// HoltWinters adds additive seasonality to Holt.
+	HoltWinters Method = "holt_winters"
+)
+
+// Metrics are the one-step-ahead errors of a model over the history.
+type Metrics struct {
+	MAE  float64  `json:"mae"`
+	RMSE float64  `json:"rmse"`
+	MAPE *float64 `json:"mape"` // percent, over periods with demand; nil if none
+	Bias float64  `json:"bias"` // mean of forecast minus actual
+}
+
+// Model is a fitted forecasting model.
+type Model struct {
+	Method   Method             `json:"method"`
+	Params   map[string]float64 `json:"params"`
+	Forecast []float64          `json:"forecast"`
+	Metrics  Metrics            `json:"metrics"`
+	// fitted holds the one-step-ahead prediction of every period, NaN
+	// until the model is warmed up.
+	fitted []float64
+}
+
+// Fit fits every model the series is long enough for and returns them with
+// their forecasts for horizon periods. Models are compared over the same
+// periods, those after the longest warm-up, and sorted best first by MAE.
+func Fit(series []float64, horizon, window, season int) []Model {
+	var models []Model
+	if len(series) > window && window > 0 {
+		models = append(models, movingAverage(series, horizon, window))
+	}
+	if len(series) >= 3 {
+		models = append(models, holt(series, horizon))
+	}
+	if season > 1 && len(series) >= 2*season+1 {
+		models = append(models, holtWinters(series, horizon, season))
+	}
+
+	start := 0
+	for _, m := range models {
+		for start < len(series) && math.IsNaN(m.fitted[start]) {
+			start++
+		}
+	}
+	for i := range models {
+		models[i].Metrics = metrics(series[start:], models[i].fitted[start:])
+		for h := range models[i].Forecast {
+			models[i].Forecast[h] = round(math.Max(models[i].Forecast[h], 0))
+		}
+	}
+	sort.SliceStable(models, func(i, j int) bool { return models[i].Metrics.MAE < models[j].Metrics.MAE })
+	return models
+}
+
+func nanSlice(n int) []float64 {
+	s := make([]float64, n)
+	for i := range s {
+		s[i] = math.NaN()
+	}
+	return s
+}
+
+func movingAverage(y []float64, horizon, window int) Model {
+	fitted := nanSlice(len(y))
+	var sum float64
+	for t := range y {
+		if t >= window {
+			fitted[t] = sum / float64(window)
+			sum -= y[t-window]
+		}
+		sum += y[t]
+	}
+	forecast := make([]float64, horizon)
+	for h := range forecast {
+		forecast[h] = sum / float64(window)
+	}
+	return Model{
+		Method:   MovingAverage,
+		Params:   map[string]float64{"window": float64(window)},
+		Forecast: forecast,
+		fitted:   fitted,
+	}
+}
+
+// grid is the set of smoothing parameters tried when fitting.
+var grid = []float64{0.1, 0.2, 0.3, 0.4, 0.5, 0.6, 0.7, 0.8, 0.9}
+
+func holt(y []float64, horizon int) Model {
+	run := func(alpha, beta float64) (fitted []float64, level, trend float64) {
+		fitted = nanSlice(len(y))
+		level, trend = y[0], y[1]-y[0]
+		for t := 1; t < len(y); t++ {
+			fitted[t] = level + trend
+			prev := level
+			level = alpha*y[t] + (1-alpha)*(level+trend)
+			trend = beta*(level-prev) + (1-beta)*trend
+		}
+		return fitted, level, trend
+	}
+
+	best := math.Inf(1)
+	var alpha, beta float64
+	for _, a := range grid {
+		for _, b := range grid {
+			fitted, _, _ := run(a, b)
+			if sse := squaredErrors(y, fitted); sse < best {
+				best, alpha, beta = sse, a, b
+			}
+		}
+	}
+	fitted, level, trend := run(alpha, beta)
+	forecast := make([]float64, horizon)
+	for h := range forecast {
+		forecast[h] = level + float64(h+1)*trend
+	}
+	return Model{
+		Method:   Holt,
+		Params:   map[string]float64{"alpha": alpha, "beta": beta},
+		Forecast: forecast,
+		fitted:   fitted,
+	}
+}
+
+func holtWinters(y []float64, horizon, m int) Model {
+	run := func(alpha, beta, gamma float64) (fitted []float64, level, trend float64, seasonal []float64) {
+		fitted = nanSlice(len(y))
+		// Start from the first two seasons: level is the first season's
+		// mean, trend the per-period change between the seasons' means
+		var first, second float64
+		for i := 0; i < m; i++ {
+			first += y[i]
+			second += y[m+i]
+		}
+		level = first / float64(m)
+		trend = (second - first) / float64(m*m)
+		seasonal = make([]float64, len(y))
+		for i := 0; i < m; i++ {
+			seasonal[i] = y[i] - level
+		}
+		for t := m; t < len(y); t++ {
+			fitted[t] = level + trend + seasonal[t-m]
+			prev := level
+			level = alpha*(y[t]-seasonal[t-m]) + (1-alpha)*(level+trend)
+			trend = beta*(level-prev) + (1-beta)*trend
+			seasonal[t] = gamma*(y[t]-level) + (1-gamma)*seasonal[t-m]
+		}
+		return fitted, level, trend, seasonal
+	}
+
+	best := math.Inf(1)
+	var alpha, beta, gamma float64
+	for _, a := range grid {
+		for _, b := range grid {
+			for _, g := range grid {
+				fitted, _, _, _ := run(a, b, g)
+				if sse := squaredErrors(y, fitted); sse < best {
+					best, alpha, beta, gamma = sse, a, b, g
+				}
+			}
+		}
+	}
+	fitted, level, trend, seasonal := run(alpha, beta, gamma)
+	n := len(y)
+	forecast := make([]float64, horizon)
+	for h := range forecast {
+		forecast[h] = level + float64(h+1)*trend + seasonal[n-m+h%m]
+	}
+	return Model{
+		Method:   HoltWinters,
+		Params:   map[string]float64{"alpha": alpha, "beta": beta, "gamma": gamma, "season": float64(m)},
+		Forecast: forecast,
+		fitted:   fitted,
+	}
+}
+
+func squaredErrors(y, fitted []float64) float64 {
+	var sse float64
+	for t := range y {
+		if !math.IsNaN(fitted[t]) {
+			sse += (fitted[t] - y[t]) * (fitted[t] - y[t])
+		}
+	}
+	return sse
+}
+
+func metrics(y, fitted []float64) Metrics {
+	var m Metrics
+	var n, withDemand int
+	var abs, squares, pct, bias float64
+	for t := range y {
+		if math.IsNaN(fitted[t]) {
+			continue
+		}
+		e := fitted[t] - y[t]
+		n++
+		abs += math.Abs(e)
+		squares += e * e
+		bias += e
+		if y[t] != 0 {
+			withDemand++
+			pct += math.Abs(e) / y[t]
+		}
+	}
+	if n == 0 {
+		return m
+	}
+	m.MAE = round(abs / float64(n))
+	m.RMSE = round(math.Sqrt(squares / float64(n)))
+	m.Bias = round(bias / float64(n))
+	if withDemand > 0 {
+		mape := round(pct / float64(withDemand) * 100)
+		m.MAPE = &mape
+	}
+	return m
+}
+
+func round(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// DemandOver is the forecast demand over the next periods, which may be
+// fractional. Beyond the horizon the last forecast period repeats.
+func (m Model) DemandOver(periods float64) float64 {
+	var total float64
+	for h := 0; h < len(m.Forecast) && periods > 0; h++ {
+		total += m.Forecast[h] * math.Min(periods, 1)
+		periods--
+	}
+	if periods > 0 && len(m.Forecast) > 0 {
+		total += m.Forecast[len(m.Forecast)-1] * periods
+	}
+	return total
+}
+
+// StockLevels suggests MinStock and MaxStock from a forecast, with lead
+// time and review period in forecast periods: the reorder point covers
+// demand over the lead time plus safety stock against forecast error at
+// the service level, and the maximum adds the demand expected between
+// reviews.
+func StockLevels(m Model, leadTime, review, serviceLevel float64) (minStock, maxStock, safetyStock int) {
+	// Normal quantile of the service level
+	z := math.Sqrt2 * math.Erfinv(2*serviceLevel-1)
+	safety := z * m.Metrics.RMSE * math.Sqrt(leadTime)
+	minStock = int(math.Ceil(m.DemandOver(leadTime) + safety))
+	maxStock = int(math.Ceil(m.DemandOver(leadTime+review) + safety))
+	return minStock, maxStock, int(math.Ceil(safety))
+}
@@ -0,0 +1,76 @@
+package forecast
+
+import (
+	"math"
+	"testing"
+)
+
+func TestHoltFollowsTrend(t *testing.T) {
+	series := make([]float64, 30)
+	for i := range series {
+		series[i] = 10 + 2*float64(i)
+	}
+	m := holt(series, 3)
+	for h, want := range []float64{70, 72, 74} {
+		if math.Abs(m.Forecast[h]-want) > 0.01 {
+			t.Errorf("forecast[%d] = %v, want %v", h, m.Forecast[h], want)
+		}
+	}
+}
+
+func TestFitPrefersSeasonalModelForSeasonalDemand(t *testing.T) {
+	week := []float64{5, 5, 5, 5, 20, 30, 2}
+	var series []float64
+	for i := 0; i < 8; i++ {
+		series = append(series, week...)
+	}
+	models := Fit(series, 7, 7, 7)
+	if len(models) != 3 {
+		t.Fatalf("fitted %d models, want 3", len(models))
+	}
+	best := models[0]
+	if best.Method != HoltWinters {
+		t.Fatalf("best = %s (MAE %v), want holt_winters", best.Method, best.Metrics.MAE)
+	}
+	for h, want := range week {
+		if math.Abs(best.Forecast[h]-want) > 0.5 {
+			t.Errorf("forecast[%d] = %v, want about %v", h, best.Forecast[h], want)
+		}
+	}
+	if ma := pick(models, MovingAverage); ma.Forecast[0] != 10.29 {
+		t.Errorf("moving average forecast = %v", ma.Forecast[0])
+	}
+}
+
+func TestFitSkipsModelsWithoutEnoughHistory(t *testing.T) {
+	models := Fit([]float64{1, 2, 3, 4, 5}, 2, 7, 7)
+	if len(models) != 1 || models[0].Method != Holt {
+		t.Errorf("models = %+v", models)
+	}
+}
+
+func TestStockLevels(t *testing.T) {
+	m := Model{Forecast: []float64{10, 10, 10, 10}, Metrics: Metrics{RMSE: 2}}
+	// 4 periods of lead time, 2 of review: demand 40 and 60, safety 1.645*2*2
+	minStock, maxStock, safety := StockLevels(m, 4, 2, 0.95)
+	if minStock != 47 || maxStock != 67 || safety != 7 {
+		t.Errorf("levels = %d, %d, %d; want 47, 67, 7", minStock, maxStock, safety)
+	}
+	if got := m.DemandOver(1.5); got != 15 {
+		t.Errorf("demand over 1.5 periods = %v", got)
+	}
+}
+
+func TestDefaultHistoryFitsEveryModel(t *testing.T) {
+	for name, iv := range intervals {
+		if iv.history < 2*iv.season+1 {
+			t.Errorf("%s: default history %d is too short for a season of %d", name, iv.history, iv.season)
+		}
+		if iv.history > iv.maxHistory || iv.horizon > iv.maxHorizon {
+			t.Errorf("%s: defaults exceed the caps", name)
+		}
+		if got := len(Fit(make([]float64, iv.history), iv.horizon, iv.window, iv.season)); got != 3 {
+			t.Errorf("%s: fitted %d models, want 3", name, got)
+		}
+	}
+}
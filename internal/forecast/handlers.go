@@ -0,0 +1,316 @@
+package forecast
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"myapp/internal"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// interval is the length of one period of a demand series.
+type interval struct {
+	name       string // date_trunc unit
+	days       int
+	history    int // default periods of history
+	horizon    int // default periods forecast
+	window     int // moving average window
+	season     int // periods per season
+	maxHistory int
+	maxHorizon int
+}
+
+// The default history covers two seasons plus a period, so Holt-Winters can
+// always be fitted.
+var intervals = map[string]interval{
+	"day":  {name: "day", days: 1, history: 180, horizon: 30, window: 7, season: 7, maxHistory: 730, maxHorizon: 365},
+	"week": {name: "week", days: 7, history: 156, horizon: 12, window: 4, season: 52, maxHistory: 260, maxHorizon: 104},
+}
+
+// periodStart truncates t to the start of its day or ISO week.
+func (iv interval) periodStart(t time.Time) time.Time {
+	d := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	if iv.name == "week" {
+		d = d.AddDate(0, 0, -(int(d.Weekday())+6)%7)
+	}
+	return d
+}
+
+// demandSeries sums OUT movements of a product per period, for up to the
+// given number of complete periods before now. The series starts at the
+// period of the first OUT movement in that range, so a recently launched
+// product is not padded with periods it could not have sold in; it is
+// empty when there was no demand at all. A zero warehouse ID means all.
+func demandSeries(iv interval, productID, warehouseID uint, periods int, now time.Time) ([]float64, time.Time, error) {
+	end := iv.periodStart(now)
+	start := end.AddDate(0, 0, -periods*iv.days)
+
+	outbound := func() *gorm.DB {
+		query := internal.DB.Table("stock_movements").
+			Where("product_id = ? AND type = ? AND created_at >= ? AND created_at < ?", productID, "OUT", start, end)
+		if warehouseID != 0 {
+			query = query.Where("warehouse_id = ?", warehouseID)
+		}
+		return query
+	}
+
+	var first *time.Time
+	if err := outbound().Select("MIN(created_at)").Scan(&first).Error; err != nil {
+		return nil, start, err
+	}
+	if first == nil {
+		return nil, end, nil
+	}
+	if launched := iv.periodStart(first.In(now.Location())); launched.After(start) {
+		periods = int(end.Sub(launched).Hours()+12) / 24 / iv.days
+		start = end.AddDate(0, 0, -periods*iv.days)
+	}
+
+	var rows []struct {
+		Period string
+		Units  float64
+	}
+	if err := outbound().
+		Select("to_char(date_trunc(?, created_at), 'YYYY-MM-DD') AS period, SUM(-quantity) AS units", iv.name).
+		Group("period").Scan(&rows).Error; err != nil {
+		return nil, start, err
+	}
+
+	series := make([]float64, periods)
+	for _, r := range rows {
+		d, err := time.ParseInLocation("2006-01-02", r.Period, now.Location())
+		if err != nil {
+			continue
+		}
+		i := int(d.Sub(start).Hours()+12) / 24 / iv.days
+		if i >= 0 && i < periods {
+			series[i] += r.Units
+		}
+	}
+	return series, start, nil
+}
+
+// leadTimeDays is the preferred supplier's catalogue lead time, or def.
+func leadTimeDays(product internal.Product, def int) int {
+	if product.PreferredSupplierID == nil {
+		return def
+	}
+	var entry internal.SupplierProduct
+	if err := internal.DB.Where("supplier_id = ? AND product_id = ?", *product.PreferredSupplierID, product.ID).
+		First(&entry).Error; err != nil || entry.LeadTimeDays <= 0 {
+		return def
+	}
+	return entry.LeadTimeDays
+}
+
+func queryInt(r *http.Request, name string, def int) int {
+	n, err := strconv.Atoi(r.URL.Query().Get(name))
+	if err != nil || n <= 0 {
+		return def
+	}
+	return n
+}
+
+func pick(models []Model, method Method) *Model {
+	for i := range models {
+		if method == "" || models[i].Method == method {
+			return &models[i]
+		}
+	}
+	return nil
+}
+
+// GetProductForecast forecasts a product's demand:
+// GET /products/{id}/forecast?interval=week&horizon=12&method=holt.
+// Every model the history allows is fitted and the one with the lowest
+// one-step-ahead MAE is recommended unless ?method= is given. Suggested
+// MinStock and MaxStock are computed for each of the product's inventory
+// rows from that warehouse's own demand.
+func GetProductForecast(w http.ResponseWriter, r *http.Request) {
+	productForecast(w, r, false)
+}
+
+// ApplyProductForecast computes the same forecast and writes the suggested
+// MinStock and MaxStock to the product's inventory rows.
+func ApplyProductForecast(w http.ResponseWriter, r *http.Request) {
+	productForecast(w, r, true)
+}
+
+func productForecast(w http.ResponseWriter, r *http.Request, apply bool) {
+	id := extractID(r.URL.Path, "/products/")
+	if id == 0 {
+		http.Error(w, "Invalid product ID", http.StatusBadRequest)
+		return
+	}
+	var product internal.Product
+	if err := internal.DB.First(&product, id).Error; err != nil {
+		http.Error(w, "Product not found", http.StatusNotFound)
+		return
+	}
+
+	name := r.URL.Query().Get("interval")
+	if name == "" {
+		name = "day"
+	}
+	iv, ok := intervals[name]
+	if !ok {
+		http.Error(w, "interval must be day or week", http.StatusBadRequest)
+		return
+	}
+	method := Method(r.URL.Query().Get("method"))
+	if method != "" && method != MovingAverage && method != Holt && method != HoltWinters {
+		http.Error(w, "method must be moving_average, holt or holt_winters", http.StatusBadRequest)
+		return
+	}
+	history := queryInt(r, "history", iv.history)
+	if history > iv.maxHistory {
+		http.Error(w, fmt.Sprintf("history cannot exceed %d periods", iv.maxHistory), http.StatusBadRequest)
+		return
+	}
+	horizon := queryInt(r, "horizon", iv.horizon)
+	if horizon > iv.maxHorizon {
+		http.Error(w, fmt.Sprintf("horizon cannot exceed %d periods", iv.maxHorizon), http.StatusBadRequest)
+		return
+	}
+	window := queryInt(r, "window", iv.window)
+	leadTime := queryInt(r, "lead_time_days", leadTimeDays(product, 7))
+	review := queryInt(r, "review_days", 14)
+	serviceLevel := 0.95
+	if v := r.URL.Query().Get("service_level"); v != "" {
+		var err error
+		if serviceLevel, err = strconv.ParseFloat(v, 64); err != nil || serviceLevel <= 0.5 || serviceLevel >= 1 {
+			http.Error(w, "service_level must be between 0.5 and 1", http.StatusBadRequest)
+			return
+		}
+	}
+
+	now := time.Now()
+	series, start, err := demandSeries(iv, product.ID, 0, history, now)
+	if err != nil {
+		http.Error(w, "Failed to load demand history", http.StatusInternalServerError)
+		return
+	}
+	models := Fit(series, horizon, window, iv.season)
+	chosen := pick(models, method)
+	if chosen == nil {
+		http.Error(w, "Not enough history for this method", http.StatusUnprocessableEntity)
+		return
+	}
+
+	type point struct {
+		Period   string  `json:"period"`
+		Quantity float64 `json:"quantity"`
+	}
+	actual := make([]point, len(series))
+	for i, q := range series {
+		actual[i] = point{start.AddDate(0, 0, i*iv.days).Format("2006-01-02"), q}
+	}
+	end := iv.periodStart(now)
+	forecast := make([]point, len(chosen.Forecast))
+	for h, q := range chosen.Forecast {
+		forecast[h] = point{end.AddDate(0, 0, h*iv.days).Format("2006-01-02"), q}
+	}
+
+	type suggestion struct {
+		InventoryID      uint   `json:"inventory_id"`
+		WarehouseID      uint   `json:"warehouse_id"`
+		WarehouseName    string `json:"warehouse_name"`
+		Method           Method `json:"method"`
+		MinStock         int    `json:"min_stock"`
+		MaxStock         int    `json:"max_stock"`
+		SafetyStock      int    `json:"safety_stock"`
+		CurrentMinStock  int    `json:"current_min_stock"`
+		CurrentMaxStock  int    `json:"current_max_stock"`
+		CurrentOnHand    int    `json:"current_on_hand"`
+		ForecastOverLead int    `json:"forecast_over_lead_time"`
+	}
+	var inventories []internal.Inventory
+	if err := internal.DB.Preload("Warehouse").Where("product_id = ?", product.ID).Find(&inventories).Error; err != nil {
+		http.Error(w, "Failed to fetch inventory", http.StatusInternalServerError)
+		return
+	}
+	leadPeriods := float64(leadTime) / float64(iv.days)
+	reviewPeriods := float64(review) / float64(iv.days)
+	suggestions := make([]suggestion, 0, len(inventories))
+	for _, inv := range inventories {
+		whSeries, _, err := demandSeries(iv, product.ID, inv.WarehouseID, history, now)
+		if err != nil {
+			http.Error(w, "Failed to load demand history", http.StatusInternalServerError)
+			return
+		}
+		// Without demand in the window there is nothing to size the row
+		// from; its current levels are left alone rather than zeroed.
+		if len(whSeries) == 0 {
+			continue
+		}
+		m := pick(Fit(whSeries, horizon, window, iv.season), chosen.Method)
+		if m == nil {
+			continue
+		}
+		minStock, maxStock, safety := StockLevels(*m, leadPeriods, reviewPeriods, serviceLevel)
+		suggestions = append(suggestions, suggestion{
+			InventoryID:      inv.ID,
+			WarehouseID:      inv.WarehouseID,
+			WarehouseName:    inv.Warehouse.Name,
+			Method:           m.Method,
+			MinStock:         minStock,
+			MaxStock:         maxStock,
+			SafetyStock:      safety,
+			CurrentMinStock:  inv.MinStock,
+			CurrentMaxStock:  inv.MaxStock,
+			CurrentOnHand:    inv.Quantity,
+			ForecastOverLead: int(math.Ceil(m.DemandOver(leadPeriods))),
+		})
+	}
+
+	if apply {
+		err := internal.DB.Transaction(func(tx *gorm.DB) error {
+			for _, s := range suggestions {
+				if err := tx.Model(&internal.Inventory{}).Where("id = ?", s.InventoryID).
+					Updates(map[string]interface{}{"min_stock": s.MinStock, "max_stock": s.MaxStock}).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			http.Error(w, "Failed to update stock levels", http.StatusInternalServerError)
+			return
+		}
+		internal.LogAudit("UPDATE", "Inventory", product.ID, "system",
+			fmt.Sprintf("Applied %s forecast stock levels to %d inventory rows", chosen.Method, len(suggestions)))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data": map[string]interface{}{
+			"applied":        apply,
+			"product_id":     product.ID,
+			"sku":            product.SKU,
+			"interval":       iv.name,
+			"method":         chosen.Method,
+			"history":        actual,
+			"forecast":       forecast,
+			"models":         models,
+			"lead_time_days": leadTime,
+			"review_days":    review,
+			"service_level":  serviceLevel,
+			"suggestions":    suggestions,
+		},
+	})
+}
+
+func extractID(path, prefix string) int {
+	idStr := strings.TrimPrefix(path, prefix)
+	if idx := strings.Index(idStr, "/"); idx != -1 {
+		idStr = idStr[:idx]
+	}
+	id, _ := strconv.Atoi(idStr)
+	return id
+}
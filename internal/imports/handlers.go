@@ -0,0 +1,73 @@
+package imports
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// maxImportSize caps the request body of an import.
+const maxImportSize = 32 << 20
+
+// Import bulk loads a file:
+// POST /import/{products|suppliers|inventory}?dry_run=true&format=csv|jsonl.
+// Without ?format= the Content-Type decides: application/x-ndjson or
+// application/jsonl for JSON lines, CSV otherwise. Invalid rows are reported
+// with 422 and nothing is saved.
+func Import(w http.ResponseWriter, r *http.Request) {
+	importType := strings.Trim(strings.TrimPrefix(r.URL.Path, "/import/"), "/")
+	if _, ok := importers[importType]; !ok {
+		http.Error(w, "Unknown import type", http.StatusNotFound)
+		return
+	}
+	format, ok := requestFormat(r)
+	if !ok {
+		http.Error(w, "format must be csv or jsonl", http.StatusBadRequest)
+		return
+	}
+
+	records, err := Read(http.MaxBytesReader(w, r.Body, maxImportSize), format)
+	if err != nil {
+		http.Error(w, "Invalid import file: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(records) == 0 {
+		http.Error(w, "No rows to import", http.StatusBadRequest)
+		return
+	}
+
+	result, err := Run(importType, records, r.URL.Query().Get("dry_run") == "true", "system")
+	if err != nil {
+		http.Error(w, "Failed to import "+importType, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if len(result.Errors) > 0 {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":  "error",
+			"message": fmt.Sprintf("%d errors; nothing was imported", len(result.Errors)),
+			"data":    result,
+		})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   result,
+	})
+}
+
+func requestFormat(r *http.Request) (Format, bool) {
+	if name := r.URL.Query().Get("format"); name != "" {
+		return ParseFormat(name)
+	}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "application/x-ndjson", "application/jsonl", "application/json":
+		return JSONLines, true
+	}
+	return CSV, true
+}
@@ -0,0 +1,274 @@
+// Package imports bulk loads products, suppliers and opening inventory
+// balances from CSV or JSON lines files. Every row is validated before
+// anything is written, and a batch is saved in a single transaction: either
+// all rows are imported or none are.
+package imports
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"myapp/internal"
+	"sort"
+	"strconv"
+
+	"gorm.io/gorm"
+)
+
+// Result summarises an import. Created, Updated and Unchanged count the
+// valid rows, including on a dry run.
+type Result struct {
+	Type      string     `json:"type"`
+	DryRun    bool       `json:"dry_run"`
+	Applied   bool       `json:"applied"`
+	Rows      int        `json:"rows"`
+	Created   int        `json:"created"`
+	Updated   int        `json:"updated"`
+	Unchanged int        `json:"unchanged"`
+	Errors    []RowError `json:"errors"`
+}
+
+// RowError is a problem with one row of the file.
+type RowError struct {
+	Row     int    `json:"row"`
+	Field   string `json:"field,omitempty"`
+	Value   string `json:"value,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e RowError) String() string {
+	if e.Field == "" {
+		return fmt.Sprintf("row %d: %s", e.Row, e.Message)
+	}
+	return fmt.Sprintf("row %d: %s: %s", e.Row, e.Field, e.Message)
+}
+
+// op is the change a valid row makes.
+type op struct {
+	action string // created, updated or unchanged
+	apply  func(tx *gorm.DB) error
+	// after runs once the batch is committed
+	after func()
+}
+
+type importer struct {
+	entity  string // audit log entity
+	columns []string
+	plan    func(tx *gorm.DB, records []Record, actor string) ([]op, []RowError, error)
+}
+
+var importers = map[string]importer{
+	"products":  {"Product", productColumns, planProducts},
+	"suppliers": {"Supplier", supplierColumns, planSuppliers},
+	"inventory": {"Inventory", inventoryColumns, planInventory},
+}
+
+// Types lists what can be imported.
+func Types() []string {
+	types := make([]string, 0, len(importers))
+	for t := range importers {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// errRollback undoes a dry run or a batch with invalid rows.
+var errRollback = errors.New("rollback")
+
+// Run validates and imports records of the given type on behalf of actor.
+// Nothing is saved when any row is invalid. A dry run makes every change
+// and rolls it back, so it catches the same problems as a real import.
+func Run(importType string, records []Record, dryRun bool, actor string) (*Result, error) {
+	imp, ok := importers[importType]
+	if !ok {
+		return nil, fmt.Errorf("unknown import type %q", importType)
+	}
+	result := &Result{Type: importType, DryRun: dryRun, Rows: len(records), Errors: unknownColumns(records, imp.columns)}
+
+	var ops []op
+	err := internal.DB.Transaction(func(tx *gorm.DB) error {
+		var rowErrors []RowError
+		var err error
+		ops, rowErrors, err = imp.plan(tx, records, actor)
+		if err != nil {
+			return err
+		}
+		result.Errors = append(result.Errors, rowErrors...)
+		if len(result.Errors) > 0 {
+			return errRollback
+		}
+		for _, o := range ops {
+			if err := o.apply(tx); err != nil {
+				return err
+			}
+		}
+		if dryRun {
+			return errRollback
+		}
+		return nil
+	})
+	if err != nil && err != errRollback {
+		return nil, err
+	}
+	sort.SliceStable(result.Errors, func(i, j int) bool { return result.Errors[i].Row < result.Errors[j].Row })
+	if len(result.Errors) > 0 {
+		return result, nil
+	}
+
+	for _, o := range ops {
+		switch o.action {
+		case "created":
+			result.Created++
+		case "updated":
+			result.Updated++
+		default:
+			result.Unchanged++
+		}
+	}
+	if dryRun {
+		return result, nil
+	}
+	result.Applied = true
+	for _, o := range ops {
+		if o.after != nil {
+			o.after()
+		}
+	}
+	internal.LogAudit("IMPORT", imp.entity, 0, actor, fmt.Sprintf("Imported %d %s: %d created, %d updated, %d unchanged",
+		result.Rows, importType, result.Created, result.Updated, result.Unchanged))
+	log.Printf("Imported %d %s (%d created, %d updated)", result.Rows, importType, result.Created, result.Updated)
+	return result, nil
+}
+
+// unknownColumns reports each column the import type does not have, once,
+// on the first row that uses it.
+func unknownColumns(records []Record, columns []string) []RowError {
+	known := make(map[string]bool, len(columns))
+	for _, c := range columns {
+		known[c] = true
+	}
+	var errs []RowError
+	for _, rec := range records {
+		names := make([]string, 0, len(rec.Fields))
+		for name := range rec.Fields {
+			if !known[name] {
+				names = append(names, name)
+				known[name] = true
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			errs = append(errs, RowError{Row: rec.Row, Field: name, Message: "unknown column"})
+		}
+	}
+	return errs
+}
+
+// checker validates the fields of one record and copies them onto a model,
+// recording the columns that change.
+type checker struct {
+	rec     Record
+	errs    []RowError
+	changes map[string]interface{}
+}
+
+func newChecker(rec Record) *checker {
+	return &checker{rec: rec, changes: map[string]interface{}{}}
+}
+
+func (c *checker) fail(field, message string) {
+	c.errs = append(c.errs, RowError{Row: c.rec.Row, Field: field, Value: c.rec.Fields[field], Message: message})
+}
+
+func (c *checker) has(field string) bool {
+	_, ok := c.rec.Fields[field]
+	return ok
+}
+
+// require fails unless the record has one of the fields.
+func (c *checker) require(fields ...string) bool {
+	for _, f := range fields {
+		if c.has(f) {
+			return true
+		}
+	}
+	c.fail(fields[0], "is required")
+	return false
+}
+
+func (c *checker) text(field string, dst *string) {
+	if v, ok := c.rec.Fields[field]; ok && v != *dst {
+		*dst = v
+		c.changes[field] = v
+	}
+}
+
+func (c *checker) number(field string, dst *float64) {
+	v, ok := c.rec.Fields[field]
+	if !ok {
+		return
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || f < 0 || math.IsInf(f, 0) || math.IsNaN(f) {
+		c.fail(field, "must be a number of at least 0")
+		return
+	}
+	if f != *dst {
+		*dst = f
+		c.changes[field] = f
+	}
+}
+
+func (c *checker) integer(field string, dst *int) {
+	v, ok := c.rec.Fields[field]
+	if !ok {
+		return
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		c.fail(field, "must be a whole number of at least 0")
+		return
+	}
+	if n != *dst {
+		*dst = n
+		c.changes[field] = n
+	}
+}
+
+// id parses an ID column, returning 0 when it is absent or invalid.
+func (c *checker) id(field string) uint {
+	v, ok := c.rec.Fields[field]
+	if !ok {
+		return 0
+	}
+	n, err := strconv.ParseUint(v, 10, 64)
+	if err != nil || n == 0 {
+		c.fail(field, "must be a positive whole number")
+		return 0
+	}
+	return uint(n)
+}
+
+// action names what a row does to its model.
+func (c *checker) action(exists bool) string {
+	switch {
+	case !exists:
+		return "created"
+	case len(c.changes) > 0:
+		return "updated"
+	}
+	return "unchanged"
+}
+
+// duplicates finds rows that repeat an earlier row's key.
+type duplicates map[string]int
+
+func (d duplicates) check(c *checker, key, field, what string) {
+	if first, ok := d[key]; ok {
+		c.fail(field, fmt.Sprintf("duplicate %s, first seen on row %d", what, first))
+		return
+	}
+	d[key] = c.rec.Row
+}
@@ -0,0 +1,200 @@
+package imports
+
+import (
+	"myapp/internal"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadCSV(t *testing.T) {
+	file := "\ufeffSKU, Name ,Min Stock,price\n" +
+		"WGT-1,Widget,5,9.99\n" +
+		"\n" +
+		",,,\n" +
+		"WGT-2,\"Gadget, large\",,\n"
+	records, err := Read(strings.NewReader(file), CSV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Record{
+		{Row: 2, Fields: map[string]string{"sku": "WGT-1", "name": "Widget", "min_stock": "5", "price": "9.99"}},
+		{Row: 5, Fields: map[string]string{"sku": "WGT-2", "name": "Gadget, large"}},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("records = %+v, want %+v", records, want)
+	}
+
+	if _, err := Read(strings.NewReader("sku,SKU\nA,B\n"), CSV); err == nil {
+		t.Error("duplicate column accepted")
+	}
+	if _, err := Read(strings.NewReader("sku,name\nA\n"), CSV); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("short row error = %v, want one naming line 2", err)
+	}
+}
+
+func TestReadJSONLines(t *testing.T) {
+	file := `{"sku": "WGT-1", "price": 9.50, "active": true, "note": null}` + "\n\n" +
+		`{"sku": " WGT-2 ", "quantity": 12}` + "\n"
+	records, err := Read(strings.NewReader(file), JSONLines)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Record{
+		{Row: 1, Fields: map[string]string{"sku": "WGT-1", "price": "9.50", "active": "true"}},
+		{Row: 3, Fields: map[string]string{"sku": "WGT-2", "quantity": "12"}},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("records = %+v, want %+v", records, want)
+	}
+
+	if _, err := Read(strings.NewReader(`{"sku": "A"}`+"\n"+`{"sku": ["B"]}`), JSONLines); err == nil || !strings.HasPrefix(err.Error(), "line 2") {
+		t.Errorf("array value error = %v, want one naming line 2", err)
+	}
+}
+
+func rec(row int, fields ...string) Record {
+	r := Record{Row: row, Fields: map[string]string{}}
+	for i := 0; i+1 < len(fields); i += 2 {
+		r.Fields[fields[i]] = fields[i+1]
+	}
+	return r
+}
+
+func actions(ops []op) []string {
+	out := make([]string, len(ops))
+	for i, o := range ops {
+		out[i] = o.action
+	}
+	return out
+}
+
+func messages(errs []RowError) []string {
+	out := make([]string, len(errs))
+	for i, e := range errs {
+		out[i] = e.String()
+	}
+	return out
+}
+
+func TestProductOps(t *testing.T) {
+	supplierID := uint(3)
+	existing := map[string]internal.Product{
+		"WGT-1": {ID: 1, SKU: "WGT-1", Name: "Widget", Price: 10, PreferredSupplierID: &supplierID},
+		"WGT-2": {ID: 2, SKU: "WGT-2", Name: "Gadget", Price: 20},
+	}
+	suppliers := supplierIndex{
+		ids:   map[uint]bool{3: true, 4: true, 5: true},
+		names: map[string][]uint{"acme": {3}, "parts inc": {4, 5}},
+	}
+	records := []Record{
+		rec(2, "sku", "WGT-1", "name", "Widget", "price", "10", "preferred_supplier", "ACME"),
+		rec(3, "sku", "WGT-2", "price", "22.5"),
+		rec(4, "sku", "WGT-3", "name", "Sprocket", "price", "4"),
+		rec(5, "sku", "WGT-3", "name", "Sprocket copy", "price", "4"),
+		rec(6, "sku", "WGT-4", "name", "No price"),
+		rec(7, "sku", "WGT-5", "name", "Bad", "price", "-1", "cost", "abc"),
+		rec(8, "sku", "WGT-6", "name", "Unknown", "price", "1", "preferred_supplier_id", "9"),
+		rec(9, "sku", "WGT-7", "name", "Ambiguous", "price", "1", "preferred_supplier", "Parts Inc"),
+		rec(10, "name", "No SKU"),
+	}
+	ops, errs := productOps(records, existing, suppliers, "test")
+
+	if got, want := actions(ops), []string{"unchanged", "updated", "created"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("actions = %v, want %v", got, want)
+	}
+	want := []string{
+		"row 5: sku: duplicate SKU, first seen on row 4",
+		"row 6: price: is required",
+		"row 7: price: must be a number of at least 0",
+		"row 7: cost: must be a number of at least 0",
+		"row 8: preferred_supplier_id: unknown supplier",
+		"row 9: preferred_supplier: matches more than one supplier; use preferred_supplier_id",
+		"row 10: sku: is required",
+	}
+	if got := messages(errs); !reflect.DeepEqual(got, want) {
+		t.Errorf("errors =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestSupplierOps(t *testing.T) {
+	archivedAt := time.Now()
+	existing := map[string][]internal.Supplier{
+		"acme":     {{ID: 1, Name: "Acme", Email: "old@acme.test", Status: "active"}},
+		"twin co":  {{ID: 2, Name: "Twin Co"}, {ID: 3, Name: "twin co"}},
+		"archived": {{ID: 4, Name: "Archived", Status: "inactive", ArchivedAt: &archivedAt}},
+	}
+	records := []Record{
+		rec(2, "name", "ACME", "email", "orders@acme.test"),
+		rec(3, "name", "New Parts", "status", "suspended"),
+		rec(4, "name", "new parts"),
+		rec(5, "name", "Twin Co"),
+		rec(6, "name", "Other", "status", "closed"),
+		rec(7, "name", "Archived", "status", "active"),
+	}
+	ops, errs := supplierOps(records, existing, "test")
+
+	if got, want := actions(ops), []string{"updated", "created"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("actions = %v, want %v", got, want)
+	}
+	want := []string{
+		"row 4: name: duplicate supplier name, first seen on row 3",
+		"row 5: name: matches 2 existing suppliers",
+		"row 6: status: must be active, inactive or suspended",
+		"row 7: status: supplier is archived",
+	}
+	if got := messages(errs); !reflect.DeepEqual(got, want) {
+		t.Errorf("errors =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestInventoryOps(t *testing.T) {
+	state := inventoryState{
+		products:   map[string]internal.Product{"WGT-1": {ID: 1, SKU: "WGT-1"}, "WGT-2": {ID: 2, SKU: "WGT-2"}},
+		warehouses: map[uint]bool{1: true, 2: true, 3: true},
+		names:      map[string][]uint{"main": {1}, "north": {2, 3}},
+		rows: map[stockKey]internal.Inventory{
+			{1, 1}: {ID: 7, ProductID: 1, WarehouseID: 1, Quantity: 40, MinStock: 10, MaxStock: 100},
+		},
+	}
+	records := []Record{
+		rec(2, "sku", "WGT-1", "warehouse", "Main", "quantity", "40"),
+		rec(3, "sku", "WGT-2", "warehouse_id", "2", "quantity", "15", "min_stock", "0"),
+		rec(4, "sku", "WGT-1", "warehouse_id", "1", "quantity", "55"),
+		rec(5, "sku", "WGT-9", "warehouse", "Main", "quantity", "1"),
+		rec(6, "sku", "WGT-2", "warehouse", "South", "quantity", "1"),
+		rec(7, "sku", "WGT-2", "warehouse_id", "3"),
+		rec(8, "sku", "WGT-1", "warehouse", "North", "quantity", "1"),
+		rec(9, "sku", "WGT-2", "warehouse_id", "1", "quantity", "5", "min_stock", "2000"),
+		rec(10, "sku", "WGT-2", "quantity", "5"),
+	}
+	ops, errs := inventoryOps(records, state, "test")
+
+	if got, want := actions(ops), []string{"unchanged", "created"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("actions = %v, want %v", got, want)
+	}
+	want := []string{
+		"row 4: sku: duplicate SKU and warehouse, first seen on row 2",
+		"row 5: sku: unknown SKU",
+		"row 6: warehouse: unknown warehouse",
+		"row 7: quantity: is required",
+		"row 8: warehouse: matches more than one warehouse; use warehouse_id",
+		"row 9: min_stock: must not exceed max_stock (1000)",
+		"row 10: warehouse_id: is required",
+	}
+	if got := messages(errs); !reflect.DeepEqual(got, want) {
+		t.Errorf("errors =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestUnknownColumns(t *testing.T) {
+	records := []Record{
+		rec(2, "sku", "A", "prcie", "1"),
+		rec(3, "sku", "B", "prcie", "2", "colour", "red"),
+	}
+	want := []string{"row 2: prcie: unknown column", "row 3: colour: unknown column"}
+	if got := messages(unknownColumns(records, productColumns)); !reflect.DeepEqual(got, want) {
+		t.Errorf("errors = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,207 @@
+package imports
+
+import (
+	"fmt"
+	"log"
+	"myapp/internal"
+	"myapp/internal/orders"
+	"myapp/internal/outbox"
+	"myapp/internal/websocket"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var inventoryColumns = []string{"sku", "warehouse_id", "warehouse", "quantity", "min_stock", "max_stock"}
+
+type stockKey struct{ productID, warehouseID uint }
+
+// inventoryState is what inventory rows are checked against.
+type inventoryState struct {
+	products   map[string]internal.Product
+	warehouses map[uint]bool
+	names      map[string][]uint // lower-case warehouse name to IDs
+	rows       map[stockKey]internal.Inventory
+}
+
+// planInventory sets opening balances by SKU and warehouse. quantity is the
+// absolute stock level; the difference from the current level is recorded
+// as an ADJUST movement. Existing rows are locked until the batch commits.
+func planInventory(tx *gorm.DB, records []Record, actor string) ([]op, []RowError, error) {
+	var skus []string
+	for _, rec := range records {
+		if sku, ok := rec.Fields["sku"]; ok {
+			skus = append(skus, sku)
+		}
+	}
+	state := inventoryState{
+		products:   map[string]internal.Product{},
+		warehouses: map[uint]bool{},
+		names:      map[string][]uint{},
+		rows:       map[stockKey]internal.Inventory{},
+	}
+
+	var products []internal.Product
+	if err := tx.Where("sku IN ?", skus).Find(&products).Error; err != nil {
+		return nil, nil, err
+	}
+	productIDs := make([]uint, len(products))
+	for i, p := range products {
+		state.products[p.SKU] = p
+		productIDs[i] = p.ID
+	}
+	var warehouses []internal.Warehouse
+	if err := tx.Select("id", "name").Find(&warehouses).Error; err != nil {
+		return nil, nil, err
+	}
+	for _, w := range warehouses {
+		state.warehouses[w.ID] = true
+		key := strings.ToLower(strings.TrimSpace(w.Name))
+		state.names[key] = append(state.names[key], w.ID)
+	}
+	var rows []internal.Inventory
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("product_id IN ?", productIDs).Find(&rows).Error; err != nil {
+		return nil, nil, err
+	}
+	for _, inv := range rows {
+		state.rows[stockKey{inv.ProductID, inv.WarehouseID}] = inv
+	}
+
+	ops, errs := inventoryOps(records, state, actor)
+	return ops, errs, nil
+}
+
+func inventoryOps(records []Record, state inventoryState, actor string) ([]op, []RowError) {
+	var ops []op
+	var errs []RowError
+	seen := duplicates{}
+	for _, rec := range records {
+		c := newChecker(rec)
+		product, found := state.products[rec.Fields["sku"]]
+		if c.require("sku") && !found {
+			c.fail("sku", "unknown SKU")
+		}
+
+		var warehouseID uint
+		if c.require("warehouse_id", "warehouse") {
+			if c.has("warehouse_id") {
+				if warehouseID = c.id("warehouse_id"); warehouseID != 0 && !state.warehouses[warehouseID] {
+					c.fail("warehouse_id", "unknown warehouse")
+				}
+			} else {
+				switch ids := state.names[strings.ToLower(rec.Fields["warehouse"])]; len(ids) {
+				case 0:
+					c.fail("warehouse", "unknown warehouse")
+				case 1:
+					warehouseID = ids[0]
+				default:
+					c.fail("warehouse", "matches more than one warehouse; use warehouse_id")
+				}
+			}
+		}
+		if len(c.errs) > 0 {
+			errs = append(errs, c.errs...)
+			continue
+		}
+		key := stockKey{product.ID, warehouseID}
+		seen.check(c, fmt.Sprint(key), "sku", "SKU and warehouse")
+
+		inv, exists := state.rows[key]
+		if !exists {
+			// The column defaults, so rows left out keep the usual limits
+			inv = internal.Inventory{ProductID: product.ID, WarehouseID: warehouseID, MinStock: 10, MaxStock: 1000}
+			c.require("quantity")
+		}
+		before := inv.Quantity
+		c.integer("quantity", &inv.Quantity)
+		c.integer("min_stock", &inv.MinStock)
+		c.integer("max_stock", &inv.MaxStock)
+		if inv.MinStock > inv.MaxStock {
+			c.fail("min_stock", fmt.Sprintf("must not exceed max_stock (%d)", inv.MaxStock))
+		}
+
+		if len(c.errs) > 0 {
+			errs = append(errs, c.errs...)
+			continue
+		}
+		ops = append(ops, inventoryOp(inv, product.Name, inv.Quantity-before, c.action(exists), c.changes, actor))
+	}
+	return ops, errs
+}
+
+func inventoryOp(inv internal.Inventory, productName string, delta int, action string, changes map[string]interface{}, actor string) op {
+	o := op{action: action}
+	o.apply = func(tx *gorm.DB) error {
+		switch action {
+		case "created":
+			minStock, maxStock := inv.MinStock, inv.MaxStock
+			if err := tx.Create(&inv).Error; err != nil {
+				return err
+			}
+			// Zero limits are not inserted, the column defaults apply instead
+			if inv.MinStock != minStock || inv.MaxStock != maxStock {
+				if err := tx.Model(&inv).Updates(map[string]interface{}{"min_stock": minStock, "max_stock": maxStock}).Error; err != nil {
+					return err
+				}
+				inv.MinStock, inv.MaxStock = minStock, maxStock
+			}
+		case "updated":
+			if err := tx.Model(&internal.Inventory{}).Where("id = ?", inv.ID).Updates(changes).Error; err != nil {
+				return err
+			}
+		default:
+			return nil
+		}
+
+		if delta != 0 {
+			movement := internal.StockMovement{
+				ProductID:   inv.ProductID,
+				WarehouseID: inv.WarehouseID,
+				Type:        "ADJUST",
+				Quantity:    delta,
+				Reference:   "import",
+				Reason:      "Opening balance",
+				CreatedBy:   actor,
+				CreatedAt:   time.Now(),
+			}
+			if err := tx.Create(&movement).Error; err != nil {
+				return err
+			}
+		}
+
+		eventAction := action
+		if action == "updated" && delta != 0 {
+			eventAction = "adjusted"
+		}
+		if err := outbox.Enqueue(tx, websocket.Event{Actor: actor, Payload: websocket.InventoryUpdated{
+			InventoryID: inv.ID,
+			ProductID:   inv.ProductID,
+			WarehouseID: inv.WarehouseID,
+			Quantity:    inv.Quantity,
+			Action:      eventAction,
+		}}); err != nil {
+			return err
+		}
+		if action != "created" && inv.Quantity <= inv.MinStock {
+			return outbox.Enqueue(tx, websocket.Event{Actor: actor, Payload: websocket.LowStockAlert{
+				ProductID:       inv.ProductID,
+				ProductName:     productName,
+				WarehouseID:     inv.WarehouseID,
+				CurrentQuantity: inv.Quantity,
+				MinStock:        inv.MinStock,
+			}})
+		}
+		return nil
+	}
+	if delta > 0 {
+		o.after = func() {
+			if _, err := orders.AllocateBackorders(inv.ProductID, inv.WarehouseID); err != nil {
+				log.Printf("Backorder allocation failed for product %d in warehouse %d: %v", inv.ProductID, inv.WarehouseID, err)
+			}
+		}
+	}
+	return o
+}
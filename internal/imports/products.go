@@ -0,0 +1,137 @@
+package imports
+
+import (
+	"myapp/internal"
+	"myapp/internal/outbox"
+	"myapp/internal/websocket"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+var productColumns = []string{"sku", "name", "description", "category", "price", "cost", "unit",
+	"preferred_supplier_id", "preferred_supplier"}
+
+// supplierIndex looks suppliers up by ID or case-insensitive name.
+type supplierIndex struct {
+	ids   map[uint]bool
+	names map[string][]uint
+}
+
+func loadSupplierIndex(tx *gorm.DB) (supplierIndex, error) {
+	var suppliers []internal.Supplier
+	if err := tx.Select("id", "name").Find(&suppliers).Error; err != nil {
+		return supplierIndex{}, err
+	}
+	index := supplierIndex{ids: map[uint]bool{}, names: map[string][]uint{}}
+	for _, s := range suppliers {
+		index.ids[s.ID] = true
+		key := strings.ToLower(strings.TrimSpace(s.Name))
+		index.names[key] = append(index.names[key], s.ID)
+	}
+	return index, nil
+}
+
+// planProducts upserts products by SKU.
+func planProducts(tx *gorm.DB, records []Record, actor string) ([]op, []RowError, error) {
+	var skus []string
+	for _, rec := range records {
+		if sku, ok := rec.Fields["sku"]; ok {
+			skus = append(skus, sku)
+		}
+	}
+	var list []internal.Product
+	if err := tx.Where("sku IN ?", skus).Find(&list).Error; err != nil {
+		return nil, nil, err
+	}
+	existing := make(map[string]internal.Product, len(list))
+	for _, p := range list {
+		existing[p.SKU] = p
+	}
+	suppliers, err := loadSupplierIndex(tx)
+	if err != nil {
+		return nil, nil, err
+	}
+	ops, errs := productOps(records, existing, suppliers, actor)
+	return ops, errs, nil
+}
+
+func productOps(records []Record, existing map[string]internal.Product, suppliers supplierIndex, actor string) ([]op, []RowError) {
+	var ops []op
+	var errs []RowError
+	seen := duplicates{}
+	for _, rec := range records {
+		c := newChecker(rec)
+		if !c.require("sku") {
+			errs = append(errs, c.errs...)
+			continue
+		}
+		sku := rec.Fields["sku"]
+		seen.check(c, sku, "sku", "SKU")
+
+		product, exists := existing[sku]
+		if !exists {
+			product = internal.Product{SKU: sku}
+			c.require("name")
+			c.require("price")
+		}
+		c.text("name", &product.Name)
+		c.text("description", &product.Description)
+		c.text("category", &product.Category)
+		c.text("unit", &product.Unit)
+		c.number("price", &product.Price)
+		c.number("cost", &product.Cost)
+
+		var supplierID uint
+		if c.has("preferred_supplier_id") {
+			if supplierID = c.id("preferred_supplier_id"); supplierID != 0 && !suppliers.ids[supplierID] {
+				c.fail("preferred_supplier_id", "unknown supplier")
+			}
+		} else if name, ok := rec.Fields["preferred_supplier"]; ok {
+			switch ids := suppliers.names[strings.ToLower(name)]; len(ids) {
+			case 0:
+				c.fail("preferred_supplier", "unknown supplier")
+			case 1:
+				supplierID = ids[0]
+			default:
+				c.fail("preferred_supplier", "matches more than one supplier; use preferred_supplier_id")
+			}
+		}
+		if supplierID != 0 && (product.PreferredSupplierID == nil || *product.PreferredSupplierID != supplierID) {
+			product.PreferredSupplierID = &supplierID
+			c.changes["preferred_supplier_id"] = supplierID
+		}
+
+		if len(c.errs) > 0 {
+			errs = append(errs, c.errs...)
+			continue
+		}
+		ops = append(ops, productOp(product, c.action(exists), c.changes, actor))
+	}
+	return ops, errs
+}
+
+func productOp(product internal.Product, action string, changes map[string]interface{}, actor string) op {
+	return op{action: action, apply: func(tx *gorm.DB) error {
+		switch action {
+		case "created":
+			if err := tx.Create(&product).Error; err != nil {
+				return err
+			}
+		case "updated":
+			if err := tx.Model(&internal.Product{}).Where("id = ?", product.ID).Updates(changes).Error; err != nil {
+				return err
+			}
+		default:
+			return nil
+		}
+		return outbox.Enqueue(tx, websocket.Event{Actor: actor, Payload: websocket.ProductUpdated{
+			ProductID:   product.ID,
+			ProductName: product.Name,
+			SKU:         product.SKU,
+			Category:    product.Category,
+			Price:       product.Price,
+			Action:      action,
+		}})
+	}}
+}
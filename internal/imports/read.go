@@ -0,0 +1,133 @@
+package imports
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Format is the encoding of an import file.
+type Format string
+
+const (
+	CSV       Format = "csv"
+	JSONLines Format = "jsonl"
+)
+
+// ParseFormat accepts a format name or file extension: csv, or jsonl,
+// ndjson or json for one JSON object per line.
+func ParseFormat(name string) (Format, bool) {
+	switch strings.ToLower(strings.TrimPrefix(name, ".")) {
+	case "csv":
+		return CSV, true
+	case "jsonl", "ndjson", "json":
+		return JSONLines, true
+	}
+	return "", false
+}
+
+// Record is one row of an import file. Fields maps column names to trimmed
+// values; empty cells and JSON nulls are left out, so they never overwrite
+// existing data.
+type Record struct {
+	Row    int // line in the file, counting the CSV header as line 1
+	Fields map[string]string
+}
+
+// Read parses an import file.
+func Read(r io.Reader, format Format) ([]Record, error) {
+	if format == JSONLines {
+		return readJSONLines(r)
+	}
+	return readCSV(r)
+}
+
+// column normalises a column name: "Min Stock" becomes min_stock.
+func column(name string) string {
+	name = strings.TrimPrefix(name, "\ufeff")
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(name)), " ", "_")
+}
+
+func readCSV(r io.Reader) ([]Record, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool, len(header))
+	for i, h := range header {
+		header[i] = column(h)
+		if seen[header[i]] {
+			return nil, fmt.Errorf("column %q appears twice", header[i])
+		}
+		seen[header[i]] = true
+	}
+
+	var records []Record
+	for {
+		values, err := cr.Read()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := cr.FieldPos(0)
+		fields := make(map[string]string, len(values))
+		for i, v := range values {
+			if v = strings.TrimSpace(v); v != "" {
+				fields[header[i]] = v
+			}
+		}
+		if len(fields) > 0 {
+			records = append(records, Record{Row: line, Fields: fields})
+		}
+	}
+}
+
+func readJSONLines(r io.Reader) ([]Record, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	var records []Record
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\ufeff"))
+		if text == "" {
+			continue
+		}
+		decoder := json.NewDecoder(strings.NewReader(text))
+		decoder.UseNumber()
+		var object map[string]interface{}
+		if err := decoder.Decode(&object); err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+
+		fields := make(map[string]string, len(object))
+		for key, value := range object {
+			switch v := value.(type) {
+			case nil:
+			case string:
+				if v = strings.TrimSpace(v); v != "" {
+					fields[column(key)] = v
+				}
+			case json.Number:
+				fields[column(key)] = v.String()
+			case bool:
+				fields[column(key)] = strconv.FormatBool(v)
+			default:
+				return nil, fmt.Errorf("line %d: %s must be a string, number or boolean", line, key)
+			}
+		}
+		if len(fields) > 0 {
+			records = append(records, Record{Row: line, Fields: fields})
+		}
+	}
+	return records, scanner.Err()
+}
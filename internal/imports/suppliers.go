@@ -0,0 +1,113 @@
+package imports
+
+import (
+	"fmt"
+	"myapp/internal"
+	"myapp/internal/outbox"
+	"myapp/internal/websocket"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+var supplierColumns = []string{"name", "contact_name", "email", "phone", "address", "status"}
+
+// planSuppliers upserts suppliers by case-insensitive name.
+func planSuppliers(tx *gorm.DB, records []Record, actor string) ([]op, []RowError, error) {
+	var list []internal.Supplier
+	if err := tx.Find(&list).Error; err != nil {
+		return nil, nil, err
+	}
+	existing := make(map[string][]internal.Supplier, len(list))
+	for _, s := range list {
+		key := strings.ToLower(strings.TrimSpace(s.Name))
+		existing[key] = append(existing[key], s)
+	}
+	ops, errs := supplierOps(records, existing, actor)
+	return ops, errs, nil
+}
+
+func supplierOps(records []Record, existing map[string][]internal.Supplier, actor string) ([]op, []RowError) {
+	var ops []op
+	var errs []RowError
+	seen := duplicates{}
+	for _, rec := range records {
+		c := newChecker(rec)
+		if !c.require("name") {
+			errs = append(errs, c.errs...)
+			continue
+		}
+		key := strings.ToLower(rec.Fields["name"])
+		seen.check(c, key, "name", "supplier name")
+
+		var supplier internal.Supplier
+		matches := existing[key]
+		exists := len(matches) > 0
+		switch {
+		case len(matches) > 1:
+			c.fail("name", fmt.Sprintf("matches %d existing suppliers", len(matches)))
+		case exists:
+			supplier = matches[0]
+		default:
+			supplier.Status = "active"
+		}
+		previousStatus := supplier.Status
+		c.text("name", &supplier.Name)
+		c.text("contact_name", &supplier.ContactName)
+		c.text("email", &supplier.Email)
+		c.text("phone", &supplier.Phone)
+		c.text("address", &supplier.Address)
+		c.text("status", &supplier.Status)
+		if _, changed := c.changes["status"]; changed {
+			switch {
+			case supplier.Status != "active" && supplier.Status != "inactive" && supplier.Status != "suspended":
+				c.fail("status", "must be active, inactive or suspended")
+			case exists && supplier.ArchivedAt != nil:
+				c.fail("status", "supplier is archived")
+			}
+		}
+
+		if len(c.errs) > 0 {
+			errs = append(errs, c.errs...)
+			continue
+		}
+		statusChanged := exists && supplier.Status != previousStatus
+		ops = append(ops, supplierOp(supplier, c.action(exists), c.changes, statusChanged, actor))
+	}
+	return ops, errs
+}
+
+func supplierOp(supplier internal.Supplier, action string, changes map[string]interface{}, statusChanged bool, actor string) op {
+	return op{action: action, apply: func(tx *gorm.DB) error {
+		switch action {
+		case "created":
+			if err := tx.Create(&supplier).Error; err != nil {
+				return err
+			}
+		case "updated":
+			if err := tx.Model(&internal.Supplier{}).Where("id = ?", supplier.ID).Updates(changes).Error; err != nil {
+				return err
+			}
+		default:
+			return nil
+		}
+		if statusChanged {
+			if err := outbox.Enqueue(tx, websocket.Event{Actor: actor, Payload: websocket.SupplierStatusAlert{
+				SupplierID:   supplier.ID,
+				SupplierName: supplier.Name,
+				Status:       supplier.Status,
+				Message:      "Supplier status changed to " + supplier.Status,
+			}}); err != nil {
+				return err
+			}
+		}
+		return outbox.Enqueue(tx, websocket.Event{Actor: actor, Payload: websocket.SupplierUpdated{
+			SupplierID:   supplier.ID,
+			SupplierName: supplier.Name,
+			Email:        supplier.Email,
+			Phone:        supplier.Phone,
+			Address:      supplier.Address,
+			Action:       action,
+		}})
+	}}
+}
@@ -0,0 +1,112 @@
+package inventory
+
+import (
+	"encoding/json"
+	"myapp/internal"
+	"myapp/internal/outbox"
+	"myapp/internal/websocket"
+	"net/http"
+
+	"gorm.io/gorm"
+)
+
+// RegisterCommands exposes inventory operations to WebSocket dashboards.
+func RegisterCommands() {
+	websocket.RegisterCommand("inventory_snapshot", websocket.Command{
+		Topic: websocket.TopicInventory, Handler: snapshotCommand,
+	})
+	websocket.RegisterCommand("acknowledge_alert", websocket.Command{
+		Topic: websocket.TopicInventory, Handler: acknowledgeAlertCommand,
+	})
+	websocket.RegisterCommand("adjust_inventory", websocket.Command{
+		Topic: websocket.TopicInventory, Handler: adjustCommand,
+	})
+}
+
+// snapshotCommand returns a warehouse's current inventory, as
+// GET /inventory?warehouse_id= does.
+func snapshotCommand(actor string, params json.RawMessage) (interface{}, error) {
+	var req struct {
+		WarehouseID uint `json:"warehouse_id"`
+	}
+	if err := json.Unmarshal(params, &req); err != nil || req.WarehouseID == 0 {
+		return nil, &websocket.CommandError{Status: http.StatusBadRequest, Message: "warehouse_id required"}
+	}
+
+	var warehouse internal.Warehouse
+	if err := internal.DB.First(&warehouse, req.WarehouseID).Error; err != nil {
+		return nil, &websocket.CommandError{Status: http.StatusNotFound, Message: "Warehouse not found"}
+	}
+
+	var inventory []internal.Inventory
+	if err := internal.DB.Preload("Product").Where("warehouse_id = ?", req.WarehouseID).
+		Find(&inventory).Error; err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"warehouse": warehouse,
+		"inventory": inventory,
+	}, nil
+}
+
+// acknowledgeAlertCommand records that actor has seen a low stock alert and
+// tells other dashboards so they can clear it.
+func acknowledgeAlertCommand(actor string, params json.RawMessage) (interface{}, error) {
+	var req struct {
+		ProductID   uint   `json:"product_id"`
+		WarehouseID uint   `json:"warehouse_id"`
+		Note        string `json:"note"`
+	}
+	if err := json.Unmarshal(params, &req); err != nil || req.ProductID == 0 || req.WarehouseID == 0 {
+		return nil, &websocket.CommandError{Status: http.StatusBadRequest, Message: "product_id and warehouse_id required"}
+	}
+
+	var inv internal.Inventory
+	if err := internal.DB.Where("product_id = ? AND warehouse_id = ?", req.ProductID, req.WarehouseID).
+		First(&inv).Error; err != nil {
+		return nil, &websocket.CommandError{Status: http.StatusNotFound, Message: "Inventory not found"}
+	}
+	if inv.Quantity > inv.MinStock {
+		return nil, &websocket.CommandError{Status: http.StatusConflict, Message: "Inventory is not low on stock"}
+	}
+
+	ack := internal.AlertAcknowledgement{
+		AlertType:      "low_stock_alert",
+		ProductID:      req.ProductID,
+		WarehouseID:    req.WarehouseID,
+		Quantity:       inv.Quantity,
+		Note:           req.Note,
+		AcknowledgedBy: actor,
+	}
+	err := internal.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&ack).Error; err != nil {
+			return err
+		}
+		return outbox.Enqueue(tx, websocket.Event{Actor: actor, Payload: websocket.AlertAcknowledged{
+			AlertType:      ack.AlertType,
+			ProductID:      ack.ProductID,
+			WarehouseID:    ack.WarehouseID,
+			AcknowledgedBy: actor,
+			Note:           ack.Note,
+		}})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	internal.LogAudit("ACKNOWLEDGE", "Inventory", inv.ID, actor, "Acknowledged low stock alert")
+	return ack, nil
+}
+
+// adjustCommand performs the same adjustment as POST /inventory/adjust.
+func adjustCommand(actor string, params json.RawMessage) (interface{}, error) {
+	var req AdjustRequest
+	if err := json.Unmarshal(params, &req); err != nil {
+		return nil, &websocket.CommandError{Status: http.StatusBadRequest, Message: "Invalid request payload"}
+	}
+	inv, err := Adjust(req, actor)
+	if re, ok := err.(*requestError); ok {
+		return nil, &websocket.CommandError{Status: re.status, Message: re.message}
+	}
+	return inv, err
+}
@@ -2,12 +2,20 @@ package inventory
 
 import (
 	"encoding/json"
+	"errors"
+	"log"
 	"myapp/internal"
+	"myapp/internal/export"
+	"myapp/internal/orders"
+	"myapp/internal/outbox"
 	"myapp/internal/websocket"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 func GetInventory(w http.ResponseWriter, r *http.Request) {
@@ -49,57 +57,20 @@ func GetProductInventory(w http.ResponseWriter, r *http.Request) {
 	})
 }
 func AdjustInventory(w http.ResponseWriter, r *http.Request) {
-	var req struct {
-		ProductID   uint   `json:"product_id"`
-		WarehouseID uint   `json:"warehouse_id"`
-		Quantity    int    `json:"quantity"`
-		Reason      string `json:"reason"`
-	}
-
+	var req AdjustRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request payload", http.StatusBadRequest)
 		return
 	}
 
-	var inv internal.Inventory
-	err := internal.DB.Where("product_id = ? AND warehouse_id = ?", req.ProductID, req.WarehouseID).First(&inv).Error
-
-	isNew := err != nil
-	action := "updated"
-
+	inv, err := Adjust(req, "system")
 	if err != nil {
-		inv = internal.Inventory{
-			ProductID:   req.ProductID,
-			WarehouseID: req.WarehouseID,
-			Quantity:    req.Quantity,
-		}
-		internal.DB.Create(&inv)
-		action = "created"
-	} else {
-		inv.Quantity += req.Quantity
-		internal.DB.Save(&inv)
-		action = "adjusted"
-	}
-	movement := internal.StockMovement{
-		ProductID:   req.ProductID,
-		WarehouseID: req.WarehouseID,
-		Type:        "ADJUST",
-		Quantity:    req.Quantity,
-		Reason:      req.Reason,
-		CreatedBy:   "system",
-		CreatedAt:   time.Now(),
-	}
-	internal.DB.Create(&movement)
-
-	internal.LogAudit("ADJUST", "Inventory", inv.ID, "system", req.Reason)
-	hub := websocket.GetHub()
-	if hub != nil {
-		hub.BroadcastInventoryUpdate(inv.ID, inv.ProductID, inv.WarehouseID, inv.Quantity, action)
-		if !isNew && inv.Quantity <= inv.MinStock {
-			var product internal.Product
-			internal.DB.First(&product, inv.ProductID)
-			hub.BroadcastLowStockAlert(inv.ProductID, inv.WarehouseID, inv.Quantity, inv.MinStock, product.Name)
+		if re, ok := err.(*requestError); ok {
+			http.Error(w, re.message, re.status)
+			return
 		}
+		http.Error(w, "Failed to adjust inventory", http.StatusInternalServerError)
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -108,6 +79,119 @@ func AdjustInventory(w http.ResponseWriter, r *http.Request) {
 		"data":   inv,
 	})
 }
+
+// AdjustRequest is a manual stock adjustment, sent to POST /inventory/adjust
+// or as the adjust_inventory WebSocket command.
+type AdjustRequest struct {
+	ProductID   uint   `json:"product_id"`
+	WarehouseID uint   `json:"warehouse_id"`
+	Quantity    int    `json:"quantity"`
+	Reason      string `json:"reason"`
+}
+
+// requestError is a validation failure and the HTTP status it maps to.
+type requestError struct {
+	status  int
+	message string
+}
+
+func (e *requestError) Error() string {
+	return e.message
+}
+
+// Adjust applies req on behalf of actor. The stock change, its movement
+// and the resulting events are written in one transaction.
+func Adjust(req AdjustRequest, actor string) (*internal.Inventory, error) {
+	if req.ProductID == 0 || req.WarehouseID == 0 {
+		return nil, &requestError{http.StatusBadRequest, "Product and warehouse IDs required"}
+	}
+	if req.Quantity == 0 {
+		return nil, &requestError{http.StatusBadRequest, "Quantity must not be zero"}
+	}
+	var product internal.Product
+	if err := internal.DB.First(&product, req.ProductID).Error; err != nil {
+		return nil, &requestError{http.StatusNotFound, "Product not found"}
+	}
+	var warehouse internal.Warehouse
+	if err := internal.DB.First(&warehouse, req.WarehouseID).Error; err != nil {
+		return nil, &requestError{http.StatusNotFound, "Warehouse not found"}
+	}
+
+	var inv internal.Inventory
+	err := internal.DB.Transaction(func(tx *gorm.DB) error {
+		action := "adjusted"
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("product_id = ? AND warehouse_id = ?", req.ProductID, req.WarehouseID).
+			First(&inv).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			inv = internal.Inventory{
+				ProductID:   req.ProductID,
+				WarehouseID: req.WarehouseID,
+				Quantity:    req.Quantity,
+			}
+			if err := tx.Create(&inv).Error; err != nil {
+				return err
+			}
+			action = "created"
+		case err != nil:
+			return err
+		default:
+			inv.Quantity += req.Quantity
+			if err := tx.Save(&inv).Error; err != nil {
+				return err
+			}
+		}
+
+		movement := internal.StockMovement{
+			ProductID:   req.ProductID,
+			WarehouseID: req.WarehouseID,
+			Type:        "ADJUST",
+			Quantity:    req.Quantity,
+			Reason:      req.Reason,
+			CreatedBy:   actor,
+			CreatedAt:   time.Now(),
+		}
+		if err := tx.Create(&movement).Error; err != nil {
+			return err
+		}
+
+		if err := outbox.Enqueue(tx, websocket.Event{Actor: actor, Payload: websocket.InventoryUpdated{
+			InventoryID: inv.ID,
+			ProductID:   inv.ProductID,
+			WarehouseID: inv.WarehouseID,
+			Quantity:    inv.Quantity,
+			Action:      action,
+		}}); err != nil {
+			return err
+		}
+		if action != "created" && inv.Quantity <= inv.MinStock {
+			return outbox.Enqueue(tx, websocket.Event{Actor: actor, Payload: websocket.LowStockAlert{
+				ProductID:       inv.ProductID,
+				ProductName:     product.Name,
+				WarehouseID:     inv.WarehouseID,
+				CurrentQuantity: inv.Quantity,
+				MinStock:        inv.MinStock,
+			}})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Quantity > 0 {
+		allocated, err := orders.AllocateBackorders(req.ProductID, req.WarehouseID)
+		if err != nil {
+			log.Printf("Backorder allocation failed for product %d in warehouse %d: %v", req.ProductID, req.WarehouseID, err)
+		} else if allocated > 0 {
+			internal.DB.First(&inv, inv.ID)
+		}
+	}
+
+	internal.LogAudit("ADJUST", "Inventory", inv.ID, actor, req.Reason)
+	return &inv, nil
+}
 func GetLowStock(w http.ResponseWriter, r *http.Request) {
 	var inventory []internal.Inventory
 	if err := internal.DB.Preload("Product").Preload("Warehouse").
@@ -124,6 +208,11 @@ func GetLowStock(w http.ResponseWriter, r *http.Request) {
 	})
 }
 func GetStockMovements(w http.ResponseWriter, r *http.Request) {
+	if format := export.Negotiate(r); format != export.JSON {
+		exportStockMovements(w, r, format)
+		return
+	}
+
 	var movements []internal.StockMovement
 	query := internal.DB.Preload("Product").Order("created_at DESC").Limit(100)
 	productID := r.URL.Query().Get("product_id")
@@ -147,6 +236,43 @@ func GetStockMovements(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// exportStockMovements streams every movement matching the filters, not
+// just the latest 100, with product and warehouse names.
+func exportStockMovements(w http.ResponseWriter, r *http.Request, format export.Format) {
+	type row struct {
+		ID            uint
+		CreatedAt     time.Time
+		Type          string
+		ProductID     uint
+		SKU           string
+		ProductName   string
+		WarehouseID   uint
+		WarehouseName string
+		Quantity      int
+		Reference     string
+		Reason        string
+		CreatedBy     string
+	}
+	query := internal.DB.Table("stock_movements sm").
+		Select(`sm.id, sm.created_at, sm.type, sm.product_id, p.sku, p.name as product_name,
+			sm.warehouse_id, w.name as warehouse_name, sm.quantity, sm.reference, sm.reason, sm.created_by`).
+		Joins("LEFT JOIN products p ON sm.product_id = p.id").
+		Joins("LEFT JOIN warehouses w ON sm.warehouse_id = w.id").
+		Order("sm.created_at DESC, sm.id DESC")
+	if productID := r.URL.Query().Get("product_id"); productID != "" {
+		query = query.Where("sm.product_id = ?", productID)
+	}
+	if movementType := r.URL.Query().Get("type"); movementType != "" {
+		query = query.Where("sm.type = ?", movementType)
+	}
+	export.Stream(w, format, "stock-movements",
+		[]string{"id", "created_at", "type", "product_id", "sku", "product_name", "warehouse_id", "warehouse_name", "quantity", "reference", "reason", "created_by"},
+		query,
+		func(m *row) []interface{} {
+			return []interface{}{m.ID, m.CreatedAt, m.Type, m.ProductID, m.SKU, m.ProductName, m.WarehouseID, m.WarehouseName, m.Quantity, m.Reference, m.Reason, m.CreatedBy}
+		})
+}
+
 func extractID(path, prefix string) int {
 	idStr := strings.TrimPrefix(path, prefix)
 	if idx := strings.Index(idStr, "/"); idx != -1 {
@@ -0,0 +1,287 @@
+package inventory
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"log"
+	"myapp/internal"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// StockLevel is the quantity of a product in a warehouse at some time.
+type StockLevel struct {
+	ProductID   uint `json:"product_id"`
+	WarehouseID uint `json:"warehouse_id"`
+	Quantity    int  `json:"quantity"`
+}
+
+// stockAsOf reconstructs stock levels at t from the latest snapshot taken
+// at or before t plus the movements since. Zero product or warehouse IDs
+// match all. It returns the snapshot used, if any.
+func stockAsOf(db *gorm.DB, t time.Time, productID, warehouseID uint) ([]StockLevel, *internal.StockSnapshotRun, error) {
+	var run *internal.StockSnapshotRun
+	var latest internal.StockSnapshotRun
+	err := db.Where("taken_at <= ?", t).Order("taken_at DESC").Limit(1).Find(&latest).Error
+	if err != nil {
+		return nil, nil, err
+	}
+	since := time.Time{}
+	if latest.ID != 0 {
+		run = &latest
+		since = latest.TakenAt
+	}
+
+	snapshots := db.Table("stock_snapshots").
+		Select("product_id, warehouse_id, quantity").
+		Where("taken_at = ?", since)
+	movements := db.Table("stock_movements").
+		Select("product_id, warehouse_id, SUM(quantity) AS quantity").
+		Where("created_at > ? AND created_at <= ?", since, t)
+	if productID != 0 {
+		snapshots = snapshots.Where("product_id = ?", productID)
+		movements = movements.Where("product_id = ?", productID)
+	}
+	if warehouseID != 0 {
+		snapshots = snapshots.Where("warehouse_id = ?", warehouseID)
+		movements = movements.Where("warehouse_id = ?", warehouseID)
+	}
+
+	var base, changes []StockLevel
+	if err := snapshots.Scan(&base).Error; err != nil {
+		return nil, nil, err
+	}
+	if err := movements.Group("product_id, warehouse_id").Scan(&changes).Error; err != nil {
+		return nil, nil, err
+	}
+	return addLevels(base, changes), run, nil
+}
+
+// addLevels applies net changes to snapshot levels. Levels that come to
+// zero are dropped, as in snapshots; the rest are sorted by product then
+// warehouse.
+func addLevels(base, changes []StockLevel) []StockLevel {
+	type key struct{ product, warehouse uint }
+	totals := make(map[key]int, len(base)+len(changes))
+	for _, l := range slices.Concat(base, changes) {
+		totals[key{l.ProductID, l.WarehouseID}] += l.Quantity
+	}
+	levels := make([]StockLevel, 0, len(totals))
+	for k, qty := range totals {
+		if qty != 0 {
+			levels = append(levels, StockLevel{ProductID: k.product, WarehouseID: k.warehouse, Quantity: qty})
+		}
+	}
+	slices.SortFunc(levels, func(a, b StockLevel) int {
+		if c := cmp.Compare(a.ProductID, b.ProductID); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.WarehouseID, b.WarehouseID)
+	})
+	return levels
+}
+
+// snapshotGrace is how long a cutoff must be in the past before it is
+// snapshotted. Movements are stamped before their transaction commits, so
+// a snapshot taken right at the cutoff could miss ones still in flight.
+const snapshotGrace = 5 * time.Minute
+
+var errSnapshotTooRecent = errors.New("snapshot cutoff is within the grace period")
+
+// TakeSnapshot stores stock levels as of t, built incrementally from the
+// previous snapshot. If a snapshot at t already exists it is returned
+// unchanged, so several instances can run it. t must be at least
+// snapshotGrace in the past.
+func TakeSnapshot(t time.Time) (*internal.StockSnapshotRun, error) {
+	if t.After(time.Now().Add(-snapshotGrace)) {
+		return nil, errSnapshotTooRecent
+	}
+	t = t.Truncate(time.Microsecond)
+	run := internal.StockSnapshotRun{TakenAt: t}
+	err := internal.DB.Transaction(func(tx *gorm.DB) error {
+		levels, _, err := stockAsOf(tx, t, 0, 0)
+		if err != nil {
+			return err
+		}
+		run.Rows = len(levels)
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&run)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+
+		rows := make([]internal.StockSnapshot, len(levels))
+		for i, l := range levels {
+			rows[i] = internal.StockSnapshot{
+				TakenAt:     t,
+				ProductID:   l.ProductID,
+				WarehouseID: l.WarehouseID,
+				Quantity:    l.Quantity,
+			}
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(rows, 1000).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	if run.ID == 0 {
+		if err := internal.DB.Where("taken_at = ?", t).First(&run).Error; err != nil {
+			return nil, err
+		}
+	}
+	return &run, nil
+}
+
+// StartSnapshots takes a snapshot at every multiple of period (UTC
+// midnight for 24h) once the grace period has passed, checking hourly so
+// restarts do not miss one.
+func StartSnapshots(period time.Duration) {
+	take := func() {
+		cutoff := time.Now().Add(-snapshotGrace).Truncate(period)
+		if _, err := TakeSnapshot(cutoff); err != nil {
+			log.Printf("Stock snapshot at %s failed: %v", cutoff.Format(time.RFC3339), err)
+		}
+	}
+	go func() {
+		take()
+		ticker := time.NewTicker(min(period, time.Hour))
+		defer ticker.Stop()
+		for range ticker.C {
+			take()
+		}
+	}()
+}
+
+// GetInventoryAsOf answers "how many units were where" at a past date:
+// GET /inventory/as-of?date=2026-03-31&product_id=&sku=&warehouse_id=.
+func GetInventoryAsOf(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("date") == "" {
+		http.Error(w, "Date required", http.StatusBadRequest)
+		return
+	}
+	asOf, err := internal.ParseAsOf(q.Get("date"))
+	if err != nil {
+		http.Error(w, "Invalid date", http.StatusBadRequest)
+		return
+	}
+	productID, _ := strconv.Atoi(q.Get("product_id"))
+	warehouseID, _ := strconv.Atoi(q.Get("warehouse_id"))
+	if sku := q.Get("sku"); sku != "" {
+		var product internal.Product
+		if err := internal.DB.Where("sku = ?", sku).First(&product).Error; err != nil {
+			http.Error(w, "Product not found", http.StatusNotFound)
+			return
+		}
+		productID = int(product.ID)
+	}
+
+	levels, run, err := stockAsOf(internal.DB, asOf, uint(productID), uint(warehouseID))
+	if err != nil {
+		http.Error(w, "Failed to reconstruct inventory", http.StatusInternalServerError)
+		return
+	}
+
+	productIDs := make([]uint, len(levels))
+	warehouseIDs := make([]uint, len(levels))
+	for i, l := range levels {
+		productIDs[i], warehouseIDs[i] = l.ProductID, l.WarehouseID
+	}
+	productByID, err := internal.ProductsByID(productIDs)
+	if err != nil {
+		http.Error(w, "Failed to fetch products", http.StatusInternalServerError)
+		return
+	}
+	warehouseNames, err := internal.WarehouseNames(warehouseIDs)
+	if err != nil {
+		http.Error(w, "Failed to fetch warehouses", http.StatusInternalServerError)
+		return
+	}
+
+	type item struct {
+		StockLevel
+		ProductName   string `json:"product_name"`
+		SKU           string `json:"sku"`
+		WarehouseName string `json:"warehouse_name"`
+	}
+	items := make([]item, len(levels))
+	var totalUnits int
+	for i, l := range levels {
+		items[i] = item{
+			StockLevel:    l,
+			ProductName:   productByID[l.ProductID].Name,
+			SKU:           productByID[l.ProductID].SKU,
+			WarehouseName: warehouseNames[l.WarehouseID],
+		}
+		totalUnits += l.Quantity
+	}
+
+	var snapshot interface{}
+	if run != nil {
+		snapshot = run.TakenAt.Format(time.RFC3339)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data": map[string]interface{}{
+			"as_of":       asOf.Format(time.RFC3339),
+			"snapshot":    snapshot,
+			"items":       items,
+			"total_units": totalUnits,
+		},
+	})
+}
+
+// CreateSnapshot takes a snapshot as of the end of the grace period, or at
+// ?as_of= if given, e.g. to backfill before a large audit.
+func CreateSnapshot(w http.ResponseWriter, r *http.Request) {
+	t := time.Now().Add(-snapshotGrace)
+	if v := r.URL.Query().Get("as_of"); v != "" {
+		var err error
+		if t, err = internal.ParseAsOf(v); err != nil {
+			http.Error(w, "Invalid as_of", http.StatusBadRequest)
+			return
+		}
+	}
+
+	run, err := TakeSnapshot(t)
+	if err == errSnapshotTooRecent {
+		http.Error(w, "as_of must be at least 5 minutes in the past", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to take snapshot", http.StatusInternalServerError)
+		return
+	}
+
+	internal.LogAudit("SNAPSHOT", "Inventory", run.ID, "system", "Took stock snapshot at "+t.Format(time.RFC3339))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   run,
+	})
+}
+
+func ListSnapshots(w http.ResponseWriter, r *http.Request) {
+	var runs []internal.StockSnapshotRun
+	if err := internal.DB.Order("taken_at DESC").Limit(100).Find(&runs).Error; err != nil {
+		http.Error(w, "Failed to fetch snapshots", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   runs,
+	})
+}
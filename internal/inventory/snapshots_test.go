@@ -0,0 +1,69 @@
+package inventory
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestAddLevels(t *testing.T) {
+	tests := []struct {
+		name    string
+		base    []StockLevel
+		changes []StockLevel
+		want    []StockLevel
+	}{
+		{
+			name: "no snapshot replays movements",
+			changes: []StockLevel{
+				{ProductID: 1, WarehouseID: 1, Quantity: 10},
+			},
+			want: []StockLevel{{ProductID: 1, WarehouseID: 1, Quantity: 10}},
+		},
+		{
+			name: "movements add to the snapshot",
+			base: []StockLevel{{ProductID: 1, WarehouseID: 1, Quantity: 10}},
+			changes: []StockLevel{
+				{ProductID: 1, WarehouseID: 1, Quantity: -4},
+			},
+			want: []StockLevel{{ProductID: 1, WarehouseID: 1, Quantity: 6}},
+		},
+		{
+			name: "positions without movements keep the snapshot level",
+			base: []StockLevel{
+				{ProductID: 1, WarehouseID: 1, Quantity: 10},
+				{ProductID: 1, WarehouseID: 2, Quantity: 3},
+			},
+			changes: []StockLevel{{ProductID: 1, WarehouseID: 1, Quantity: 5}},
+			want: []StockLevel{
+				{ProductID: 1, WarehouseID: 1, Quantity: 15},
+				{ProductID: 1, WarehouseID: 2, Quantity: 3},
+			},
+		},
+		{
+			name:    "positions emptied since the snapshot are dropped",
+			base:    []StockLevel{{ProductID: 2, WarehouseID: 1, Quantity: 7}},
+			changes: []StockLevel{{ProductID: 2, WarehouseID: 1, Quantity: -7}},
+			want:    []StockLevel{},
+		},
+		{
+			name: "new positions are sorted by product then warehouse",
+			base: []StockLevel{{ProductID: 2, WarehouseID: 1, Quantity: 1}},
+			changes: []StockLevel{
+				{ProductID: 1, WarehouseID: 3, Quantity: 2},
+				{ProductID: 1, WarehouseID: 2, Quantity: -1},
+			},
+			want: []StockLevel{
+				{ProductID: 1, WarehouseID: 2, Quantity: -1},
+				{ProductID: 1, WarehouseID: 3, Quantity: 2},
+				{ProductID: 2, WarehouseID: 1, Quantity: 1},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := addLevels(tt.base, tt.changes); !slices.Equal(got, tt.want) {
+				t.Errorf("levels = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
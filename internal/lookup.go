@@ -0,0 +1,34 @@
+package internal
+
+// ProductsByID loads the given products keyed by ID. Missing IDs are left
+// out of the map.
+func ProductsByID(ids []uint) (map[uint]Product, error) {
+	products := make(map[uint]Product, len(ids))
+	if len(ids) == 0 {
+		return products, nil
+	}
+	var rows []Product
+	if err := DB.Where("id IN ?", ids).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, p := range rows {
+		products[p.ID] = p
+	}
+	return products, nil
+}
+
+// WarehouseNames loads the names of the given warehouses keyed by ID.
+func WarehouseNames(ids []uint) (map[uint]string, error) {
+	names := make(map[uint]string, len(ids))
+	if len(ids) == 0 {
+		return names, nil
+	}
+	var rows []Warehouse
+	if err := DB.Select("id, name").Where("id IN ?", ids).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, wh := range rows {
+		names[wh.ID] = wh.Name
+	}
+	return names, nil
+}
@@ -5,21 +5,27 @@ import (
 )
 
 type Product struct {
-	ID          uint      `gorm:"primaryKey" json:"id"`
-	Name        string    `gorm:"not null" json:"name"`
-	SKU         string    `gorm:"unique;not null" json:"sku"`
-	Description string    `json:"description"`
-	Category    string    `json:"category"`
-	Price       float64   `gorm:"not null" json:"price"`
-	Cost        float64   `json:"cost"`
-	Unit        string    `json:"unit"` // e.g., "piece", "kg", "liter"
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID                  uint      `gorm:"primaryKey" json:"id"`
+	Name                string    `gorm:"not null" json:"name"`
+	SKU                 string    `gorm:"unique;not null" json:"sku"`
+	Description         string    `json:"description"`
+	Category            string    `json:"category"`
+	Price               float64   `gorm:"not null" json:"price"`
+	Cost                float64   `json:"cost"`
+	Unit                string    `json:"unit"`                                         // e.g., "piece", "kg", "liter"
+	PreferredSupplierID *uint     `gorm:"index" json:"preferred_supplier_id,omitempty"` // used when drafting replenishment POs
+	ABCClass            string    `gorm:"index" json:"abc_class,omitempty"`             // A, B or C by consumption value, from the latest classification run
+	XYZClass            string    `gorm:"index" json:"xyz_class,omitempty"`             // X, Y or Z by demand variability
+	CreatedAt           time.Time `json:"created_at"`
+	UpdatedAt           time.Time `json:"updated_at"`
 }
 type Warehouse struct {
 	ID        uint      `gorm:"primaryKey" json:"id"`
 	Name      string    `gorm:"not null" json:"name"`
 	Location  string    `json:"location"`
+	Latitude  *float64  `json:"latitude,omitempty"`
+	Longitude *float64  `json:"longitude,omitempty"`
+	Priority  int       `gorm:"default:0" json:"priority"` // higher is preferred when routing orders
 	Capacity  int       `json:"capacity"`
 	ManagerID uint      `json:"manager_id"`
 	CreatedAt time.Time `json:"created_at"`
@@ -45,47 +51,88 @@ type StockMovement struct {
 	Reference   string    `json:"reference"` // Order ID, PO ID, etc.
 	Reason      string    `json:"reason"`
 	CreatedBy   string    `json:"created_by"`
-	CreatedAt   time.Time `json:"created_at"`
+	CreatedAt   time.Time `gorm:"index" json:"created_at"`
 	Product     Product   `gorm:"foreignKey:ProductID" json:"product,omitempty"`
 }
 type Supplier struct {
-	ID          uint      `gorm:"primaryKey" json:"id"`
-	Name        string    `gorm:"not null" json:"name"`
-	ContactName string    `json:"contact_name"`
-	Email       string    `json:"email"`
-	Phone       string    `json:"phone"`
-	Address     string    `json:"address"`
-	Rating      float64   `json:"rating"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID          uint              `gorm:"primaryKey" json:"id"`
+	Name        string            `gorm:"not null" json:"name"`
+	ContactName string            `json:"contact_name"`
+	Email       string            `json:"email"`
+	Phone       string            `json:"phone"`
+	Address     string            `json:"address"`
+	Rating      float64           `json:"rating"`
+	RatedAt     *time.Time        `json:"rated_at"`                                // nil until a rating has been computed
+	Status      string            `gorm:"not null;default:'active'" json:"status"` // active, inactive, suspended
+	ArchivedAt  *time.Time        `gorm:"index" json:"archived_at,omitempty"`
+	CreatedAt   time.Time         `json:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+	Contacts    []SupplierContact `gorm:"foreignKey:SupplierID" json:"contacts,omitempty"`
+}
+type SupplierContact struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	SupplierID uint      `gorm:"not null;index" json:"supplier_id"`
+	Name       string    `gorm:"not null" json:"name"`
+	Role       string    `json:"role"` // e.g., "sales", "accounts", "logistics"
+	Email      string    `json:"email"`
+	Phone      string    `json:"phone"`
+	IsPrimary  bool      `gorm:"default:false" json:"is_primary"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+type SupplierProduct struct {
+	ID               uint                 `gorm:"primaryKey" json:"id"`
+	SupplierID       uint                 `gorm:"not null;uniqueIndex:idx_supplier_product" json:"supplier_id"`
+	ProductID        uint                 `gorm:"not null;uniqueIndex:idx_supplier_product;index" json:"product_id"`
+	SupplierSKU      string               `json:"supplier_sku"`
+	UnitPrice        float64              `gorm:"not null" json:"unit_price"` // base price before quantity breaks
+	Currency         string               `gorm:"not null;default:'USD'" json:"currency"`
+	LeadTimeDays     int                  `json:"lead_time_days"`
+	MinOrderQuantity int                  `gorm:"not null;default:1" json:"min_order_quantity"`
+	Preferred        bool                 `gorm:"default:false" json:"preferred"`
+	CreatedAt        time.Time            `json:"created_at"`
+	UpdatedAt        time.Time            `json:"updated_at"`
+	PriceBreaks      []SupplierPriceBreak `gorm:"foreignKey:SupplierProductID" json:"price_breaks,omitempty"`
+	Supplier         Supplier             `gorm:"foreignKey:SupplierID" json:"supplier,omitempty"`
+	Product          Product              `gorm:"foreignKey:ProductID" json:"product,omitempty"`
+}
+type SupplierPriceBreak struct {
+	ID                uint    `gorm:"primaryKey" json:"id"`
+	SupplierProductID uint    `gorm:"not null;index" json:"supplier_product_id"`
+	MinQuantity       int     `gorm:"not null" json:"min_quantity"`
+	UnitPrice         float64 `gorm:"not null" json:"unit_price"`
 }
 type PurchaseOrder struct {
-	ID         uint       `gorm:"primaryKey" json:"id"`
-	PONumber   string     `gorm:"unique;not null" json:"po_number"`
-	SupplierID uint       `gorm:"not null;index" json:"supplier_id"`
-	Status     string     `gorm:"not null;default:'pending'" json:"status"` // pending, received, cancelled
-	TotalCost  float64    `json:"total_cost"`
-	OrderDate  time.Time  `json:"order_date"`
-	ReceivedAt *time.Time `json:"received_at,omitempty"`
-	CreatedAt  time.Time  `json:"created_at"`
-	UpdatedAt  time.Time  `json:"updated_at"`
-	Supplier   Supplier   `gorm:"foreignKey:SupplierID" json:"supplier,omitempty"`
-	Items      []POItem   `gorm:"foreignKey:POID" json:"items,omitempty"`
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	PONumber    string     `gorm:"unique;not null" json:"po_number"`
+	SupplierID  uint       `gorm:"not null;index" json:"supplier_id"`
+	WarehouseID uint       `gorm:"index" json:"warehouse_id"`                // destination; 0 means chosen on receipt
+	Status      string     `gorm:"not null;default:'pending'" json:"status"` // draft, pending, received, cancelled
+	TotalCost   float64    `json:"total_cost"`
+	OrderDate   time.Time  `json:"order_date"`
+	ExpectedAt  *time.Time `json:"expected_at,omitempty"`
+	ReceivedAt  *time.Time `json:"received_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	Supplier    Supplier   `gorm:"foreignKey:SupplierID" json:"supplier,omitempty"`
+	Items       []POItem   `gorm:"foreignKey:POID" json:"items,omitempty"`
 }
 type POItem struct {
-	ID        uint    `gorm:"primaryKey" json:"id"`
-	POID      uint    `gorm:"not null;index" json:"po_id"`
-	ProductID uint    `gorm:"not null;index" json:"product_id"`
-	Quantity  int     `gorm:"not null" json:"quantity"`
-	UnitPrice float64 `json:"unit_price"`
-	Product   Product `gorm:"foreignKey:ProductID" json:"product,omitempty"`
+	ID               uint    `gorm:"primaryKey" json:"id"`
+	POID             uint    `gorm:"not null;index" json:"po_id"`
+	ProductID        uint    `gorm:"not null;index" json:"product_id"`
+	Quantity         int     `gorm:"not null" json:"quantity"`
+	ReceivedQuantity int     `gorm:"not null;default:0" json:"received_quantity"` // accepted into stock
+	RejectedQuantity int     `gorm:"not null;default:0" json:"rejected_quantity"` // delivered but defective
+	UnitPrice        float64 `json:"unit_price"`
+	Product          Product `gorm:"foreignKey:ProductID" json:"product,omitempty"`
 }
 type Order struct {
 	ID            uint        `gorm:"primaryKey" json:"id"`
 	OrderNumber   string      `gorm:"unique;not null" json:"order_number"`
 	CustomerName  string      `gorm:"not null" json:"customer_name"`
 	CustomerEmail string      `json:"customer_email"`
-	Status        string      `gorm:"not null;default:'pending'" json:"status"` // pending, processing, shipped, delivered, cancelled
+	Status        string      `gorm:"not null;default:'pending'" json:"status"` // pending, backordered, processing, shipped, delivered, cancelled
 	TotalAmount   float64     `json:"total_amount"`
 	OrderDate     time.Time   `json:"order_date"`
 	ShippedAt     *time.Time  `json:"shipped_at,omitempty"`
@@ -95,13 +142,105 @@ type Order struct {
 	Items         []OrderItem `gorm:"foreignKey:OrderID" json:"items,omitempty"`
 }
 type OrderItem struct {
-	ID          uint    `gorm:"primaryKey" json:"id"`
-	OrderID     uint    `gorm:"not null;index" json:"order_id"`
-	ProductID   uint    `gorm:"not null;index" json:"product_id"`
-	WarehouseID uint    `gorm:"not null" json:"warehouse_id"`
-	Quantity    int     `gorm:"not null" json:"quantity"`
-	UnitPrice   float64 `json:"unit_price"`
-	Product     Product `gorm:"foreignKey:ProductID" json:"product,omitempty"`
+	ID                  uint    `gorm:"primaryKey" json:"id"`
+	OrderID             uint    `gorm:"not null;index" json:"order_id"`
+	ProductID           uint    `gorm:"not null;index" json:"product_id"`
+	WarehouseID         uint    `gorm:"not null" json:"warehouse_id"`
+	Quantity            int     `gorm:"not null" json:"quantity"`
+	BackorderedQuantity int     `gorm:"not null;default:0" json:"backordered_quantity"` // portion of Quantity still waiting for stock
+	UnitPrice           float64 `json:"unit_price"`
+	Product             Product `gorm:"foreignKey:ProductID" json:"product,omitempty"`
+}
+type AlertAcknowledgement struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	AlertType      string    `gorm:"not null;index" json:"alert_type"` // "low_stock_alert"
+	ProductID      uint      `gorm:"index" json:"product_id"`
+	WarehouseID    uint      `gorm:"index" json:"warehouse_id"`
+	Quantity       int       `json:"quantity"` // stock level when acknowledged
+	Note           string    `json:"note"`
+	AcknowledgedBy string    `json:"acknowledged_by"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+type OutboxEvent struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	Type           string     `gorm:"not null" json:"type"`
+	Actor          string     `json:"actor"`
+	Payload        string     `gorm:"type:text;not null" json:"payload"`
+	Attempts       int        `gorm:"default:0" json:"attempts"`
+	LastError      string     `json:"last_error"`
+	NextAttemptAt  time.Time  `gorm:"index" json:"next_attempt_at"`
+	DeliveredAt    *time.Time `gorm:"index" json:"delivered_at"`
+	DeliveredSinks []string   `gorm:"serializer:json;type:text" json:"delivered_sinks"` // sinks that already have the event
+	CreatedAt      time.Time  `json:"created_at"`
+}
+type WebhookSubscription struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	URL            string     `gorm:"not null" json:"url"`
+	EventTypes     []string   `gorm:"serializer:json;type:text;not null" json:"event_types"` // "*" matches every event
+	Secret         string     `gorm:"not null" json:"-"`
+	Active         bool       `gorm:"default:true" json:"active"`
+	FailureCount   int        `gorm:"default:0" json:"failure_count"` // consecutive failed attempts
+	DisabledAt     *time.Time `json:"disabled_at"`
+	DisabledReason string     `json:"disabled_reason"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+type WebhookDelivery struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	SubscriptionID uint       `gorm:"not null;index" json:"subscription_id"`
+	EventID        uint       `gorm:"not null;index" json:"event_id"` // outbox event ID, stable across retries and replays
+	EventType      string     `gorm:"not null" json:"event_type"`
+	Payload        string     `gorm:"type:text;not null" json:"payload"`
+	Status         string     `gorm:"default:'pending';index" json:"status"` // pending, delivered, failed
+	Attempts       int        `gorm:"default:0" json:"attempts"`
+	ResponseStatus int        `json:"response_status"`
+	LastError      string     `json:"last_error"`
+	ReplayOf       *uint      `json:"replay_of"`
+	NextAttemptAt  time.Time  `gorm:"index" json:"next_attempt_at"`
+	DeliveredAt    *time.Time `json:"delivered_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+type StockSnapshotRun struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	TakenAt   time.Time `gorm:"uniqueIndex;not null" json:"taken_at"` // includes movements created up to and at this time
+	Rows      int       `json:"rows"`
+	CreatedAt time.Time `json:"created_at"`
+}
+type StockSnapshot struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	TakenAt     time.Time `gorm:"not null;index:idx_stock_snapshot,priority:1" json:"taken_at"`
+	ProductID   uint      `gorm:"not null;index:idx_stock_snapshot,priority:2" json:"product_id"`
+	WarehouseID uint      `gorm:"not null;index:idx_stock_snapshot,priority:3" json:"warehouse_id"`
+	Quantity    int       `gorm:"not null" json:"quantity"` // zero quantities are not stored
+}
+type ClassificationRun struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	Days         int       `json:"days"`          // demand history considered
+	PerWarehouse bool      `json:"per_warehouse"` // classes were also assigned per warehouse
+	Products     int       `json:"products"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+type ProductClass struct {
+	ID               uint     `gorm:"primaryKey" json:"id"`
+	RunID            uint     `gorm:"not null;index" json:"run_id"`
+	ProductID        uint     `gorm:"not null;index" json:"product_id"`
+	WarehouseID      uint     `gorm:"not null;default:0" json:"warehouse_id"` // 0 for all warehouses
+	ABC              string   `gorm:"not null" json:"abc"`
+	XYZ              string   `gorm:"not null" json:"xyz"`
+	ConsumptionValue float64  `json:"consumption_value"` // units issued times unit cost
+	CumulativeShare  float64  `json:"cumulative_share"`  // of total consumption value, highest first
+	DemandCV         *float64 `json:"demand_cv"`         // coefficient of variation of weekly demand; nil without demand
+}
+type WSEvent struct {
+	Sequence    uint64    `gorm:"primaryKey;autoIncrement:false" json:"sequence"`
+	Topic       string    `gorm:"not null;index" json:"topic"`
+	Alert       bool      `json:"alert"`
+	WarehouseID uint      `json:"warehouse_id"`
+	ProductID   uint      `json:"product_id"`
+	SupplierID  uint      `json:"supplier_id"`
+	Data        string    `gorm:"type:text;not null" json:"data"`
+	CreatedAt   time.Time `gorm:"index" json:"created_at"`
 }
 type AuditLog struct {
 	ID        uint      `gorm:"primaryKey" json:"id"`
@@ -0,0 +1,149 @@
+package orders
+
+import (
+	"myapp/internal"
+	"myapp/internal/outbox"
+	"myapp/internal/websocket"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// backorderLine is an order line waiting for stock.
+type backorderLine struct {
+	ItemID      uint
+	OrderID     uint
+	OrderDate   time.Time
+	Backordered int
+}
+
+// planBackorders sorts lines oldest order first (then by line) and returns
+// how many of available units each line gets, in that order. Lines are
+// filled completely before the next one gets any; the last one served may
+// be filled in part.
+func planBackorders(lines []backorderLine, available int) []int {
+	sort.SliceStable(lines, func(i, j int) bool {
+		if !lines[i].OrderDate.Equal(lines[j].OrderDate) {
+			return lines[i].OrderDate.Before(lines[j].OrderDate)
+		}
+		return lines[i].ItemID < lines[j].ItemID
+	})
+	quantities := make([]int, len(lines))
+	for i, line := range lines {
+		if available <= 0 {
+			break
+		}
+		quantities[i] = min(line.Backordered, available)
+		available -= quantities[i]
+	}
+	return quantities
+}
+
+// AllocateBackorders hands available stock of a product in a warehouse to
+// backordered order lines, oldest order first. Orders left with no
+// backordered lines move from "backordered" back to "pending".
+// It returns the number of units allocated.
+func AllocateBackorders(productID, warehouseID uint) (int, error) {
+	allocated := 0
+	var completed []uint
+
+	err := internal.DB.Transaction(func(tx *gorm.DB) error {
+		var inv internal.Inventory
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("product_id = ? AND warehouse_id = ?", productID, warehouseID).
+			First(&inv).Error; err != nil {
+			return err
+		}
+		if inv.Quantity <= 0 {
+			return nil
+		}
+
+		var lines []backorderLine
+		if err := tx.Table("order_items").
+			Select("order_items.id AS item_id, order_items.order_id, orders.order_date, order_items.backordered_quantity AS backordered").
+			Joins("JOIN orders ON orders.id = order_items.order_id").
+			Where("order_items.product_id = ? AND order_items.warehouse_id = ? AND order_items.backordered_quantity > 0 AND orders.status = ?",
+				productID, warehouseID, "backordered").
+			Scan(&lines).Error; err != nil {
+			return err
+		}
+
+		quantities := planBackorders(lines, inv.Quantity)
+		for i, line := range lines {
+			qty := quantities[i]
+			if qty == 0 {
+				continue
+			}
+			inv.Quantity -= qty
+			allocated += qty
+
+			if err := tx.Model(&internal.OrderItem{}).Where("id = ?", line.ItemID).
+				Update("backordered_quantity", line.Backordered-qty).Error; err != nil {
+				return err
+			}
+
+			var order internal.Order
+			if err := tx.First(&order, line.OrderID).Error; err != nil {
+				return err
+			}
+			movement := internal.StockMovement{
+				ProductID:   productID,
+				WarehouseID: warehouseID,
+				Type:        "OUT",
+				Quantity:    -qty,
+				Reference:   order.OrderNumber,
+				Reason:      "Backorder allocation",
+				CreatedBy:   "system",
+				CreatedAt:   time.Now(),
+			}
+			if err := tx.Create(&movement).Error; err != nil {
+				return err
+			}
+
+			var remaining int64
+			if err := tx.Model(&internal.OrderItem{}).
+				Where("order_id = ? AND backordered_quantity > 0", order.ID).
+				Count(&remaining).Error; err != nil {
+				return err
+			}
+			if remaining == 0 {
+				if err := tx.Model(&order).Update("status", "pending").Error; err != nil {
+					return err
+				}
+				if err := outbox.Enqueue(tx, websocket.Event{Payload: websocket.OrderStatusChanged{
+					OrderID:     order.ID,
+					OrderNumber: order.OrderNumber,
+					OldStatus:   "backordered",
+					Status:      "pending",
+				}}); err != nil {
+					return err
+				}
+				completed = append(completed, order.ID)
+			}
+		}
+
+		if allocated == 0 {
+			return nil
+		}
+		if err := tx.Save(&inv).Error; err != nil {
+			return err
+		}
+		return outbox.Enqueue(tx, websocket.Event{Payload: websocket.InventoryUpdated{
+			InventoryID: inv.ID,
+			ProductID:   inv.ProductID,
+			WarehouseID: inv.WarehouseID,
+			Quantity:    inv.Quantity,
+			Action:      "allocated",
+		}})
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, orderID := range completed {
+		internal.LogAudit("ALLOCATE", "Order", orderID, "system", "Backorder fully allocated")
+	}
+	return allocated, nil
+}
@@ -0,0 +1,48 @@
+package orders
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestPlanBackorders(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2026, 3, d, 9, 0, 0, 0, time.UTC) }
+	// Listed newest first to show the plan does its own ordering
+	lines := func() []backorderLine {
+		return []backorderLine{
+			{ItemID: 4, OrderID: 3, OrderDate: day(3), Backordered: 2},
+			{ItemID: 3, OrderID: 2, OrderDate: day(2), Backordered: 4},
+			{ItemID: 2, OrderID: 1, OrderDate: day(1), Backordered: 1},
+			{ItemID: 1, OrderID: 1, OrderDate: day(1), Backordered: 5},
+		}
+	}
+
+	tests := []struct {
+		name      string
+		available int
+		want      []int // per line, oldest order and lowest line first
+	}{
+		{"nothing available", 0, []int{0, 0, 0, 0}},
+		{"oldest line partly filled", 3, []int{3, 0, 0, 0}},
+		{"oldest order filled before the next", 6, []int{5, 1, 0, 0}},
+		{"next order partly filled", 8, []int{5, 1, 2, 0}},
+		{"every line filled with stock to spare", 20, []int{5, 1, 4, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ls := lines()
+			got := planBackorders(ls, tt.available)
+			order := make([]uint, len(ls))
+			for i, l := range ls {
+				order[i] = l.ItemID
+			}
+			if !slices.Equal(order, []uint{1, 2, 3, 4}) {
+				t.Fatalf("lines served in order %v, want [1 2 3 4]", order)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("quantities = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,18 +2,30 @@ package orders
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
+	"math"
 	"myapp/internal"
+	"myapp/internal/export"
+	"myapp/internal/outbox"
+	"myapp/internal/suppliers"
+	"myapp/internal/websocket"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 func CreatePurchaseOrder(w http.ResponseWriter, r *http.Request) {
 	var req struct {
-		SupplierID uint `json:"supplier_id"`
-		Items      []struct {
+		SupplierID  uint       `json:"supplier_id"`
+		WarehouseID uint       `json:"warehouse_id"`
+		ExpectedAt  *time.Time `json:"expected_at"`
+		Items       []struct {
 			ProductID uint    `json:"product_id"`
 			Quantity  int     `json:"quantity"`
 			UnitPrice float64 `json:"unit_price"`
@@ -24,18 +36,49 @@ func CreatePurchaseOrder(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid request payload", http.StatusBadRequest)
 		return
 	}
+	var supplier internal.Supplier
+	if err := internal.DB.First(&supplier, req.SupplierID).Error; err != nil {
+		http.Error(w, "Supplier not found", http.StatusNotFound)
+		return
+	}
+	if !suppliers.CanOrderFrom(supplier) {
+		http.Error(w, "Supplier is suspended or archived", http.StatusBadRequest)
+		return
+	}
+
 	poNumber := fmt.Sprintf("PO-%d", time.Now().Unix())
 	var total float64
-	for _, item := range req.Items {
-		total += item.UnitPrice * float64(item.Quantity)
+	for i, item := range req.Items {
+		// Catalogue terms apply when the supplier lists the product
+		entry, err := suppliers.FindCatalogueEntry(req.SupplierID, item.ProductID)
+		if err == nil {
+			if item.Quantity < entry.MinOrderQuantity {
+				http.Error(w, fmt.Sprintf("Quantity for product %d is below the supplier minimum order quantity of %d",
+					item.ProductID, entry.MinOrderQuantity), http.StatusBadRequest)
+				return
+			}
+			if item.UnitPrice == 0 {
+				req.Items[i].UnitPrice = suppliers.PriceForQuantity(entry, item.Quantity)
+			}
+		}
+		total += req.Items[i].UnitPrice * float64(item.Quantity)
 	}
 
 	po := internal.PurchaseOrder{
-		PONumber:   poNumber,
-		SupplierID: req.SupplierID,
-		Status:     "pending",
-		TotalCost:  total,
-		OrderDate:  time.Now(),
+		PONumber:    poNumber,
+		SupplierID:  req.SupplierID,
+		WarehouseID: req.WarehouseID,
+		Status:      "pending",
+		TotalCost:   total,
+		OrderDate:   time.Now(),
+		ExpectedAt:  req.ExpectedAt,
+	}
+	if po.ExpectedAt == nil {
+		productIDs := make([]uint, 0, len(req.Items))
+		for _, item := range req.Items {
+			productIDs = append(productIDs, item.ProductID)
+		}
+		po.ExpectedAt = suppliers.ExpectedDelivery(po.SupplierID, productIDs, po.OrderDate)
 	}
 
 	if err := internal.DB.Create(&po).Error; err != nil {
@@ -80,6 +123,11 @@ func ListPurchaseOrders(w http.ResponseWriter, r *http.Request) {
 		"data":   pos,
 	})
 }
+
+// errAlreadyReceived means another request received the purchase order
+// first.
+var errAlreadyReceived = errors.New("purchase order is no longer pending")
+
 func ReceivePurchaseOrder(w http.ResponseWriter, r *http.Request) {
 	id := extractID(r.URL.Path, "/purchase-orders/")
 	if id == 0 {
@@ -87,8 +135,14 @@ func ReceivePurchaseOrder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Items is optional; lines not listed are received in full
 	var req struct {
 		WarehouseID uint `json:"warehouse_id"`
+		Items       []struct {
+			ProductID        uint `json:"product_id"`
+			ReceivedQuantity *int `json:"received_quantity"`
+			RejectedQuantity int  `json:"rejected_quantity"`
+		} `json:"items"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request payload", http.StatusBadRequest)
@@ -100,40 +154,141 @@ func ReceivePurchaseOrder(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Purchase order not found", http.StatusNotFound)
 		return
 	}
+	if po.Status != "pending" {
+		http.Error(w, "Only pending purchase orders can be received", http.StatusBadRequest)
+		return
+	}
+	if req.WarehouseID == 0 {
+		req.WarehouseID = po.WarehouseID
+	}
+	if req.WarehouseID == 0 {
+		http.Error(w, "Warehouse ID required", http.StatusBadRequest)
+		return
+	}
+	for i, item := range po.Items {
+		received := item.Quantity
+		rejected := 0
+		for _, line := range req.Items {
+			if line.ProductID != item.ProductID {
+				continue
+			}
+			if line.ReceivedQuantity != nil {
+				received = *line.ReceivedQuantity
+			}
+			rejected = line.RejectedQuantity
+		}
+		if received < 0 || rejected < 0 {
+			http.Error(w, "Received and rejected quantities cannot be negative", http.StatusBadRequest)
+			return
+		}
+		po.Items[i].ReceivedQuantity = received
+		po.Items[i].RejectedQuantity = rejected
+	}
+
+	now := time.Now()
+	po.Status = "received"
+	po.ReceivedAt = &now
+	received := websocket.PurchaseOrderReceived{
+		PurchaseOrderID: po.ID,
+		PONumber:        po.PONumber,
+		SupplierID:      po.SupplierID,
+		WarehouseID:     req.WarehouseID,
+		Items:           make([]websocket.ReceivedOrderLine, 0, len(po.Items)),
+	}
 	for _, item := range po.Items {
-		var inv internal.Inventory
-		err := internal.DB.Where("product_id = ? AND warehouse_id = ?",
-			item.ProductID, req.WarehouseID).First(&inv).Error
+		received.Items = append(received.Items, websocket.ReceivedOrderLine{
+			ProductID:        item.ProductID,
+			ReceivedQuantity: item.ReceivedQuantity,
+			RejectedQuantity: item.RejectedQuantity,
+		})
+	}
+	// Stock, movements and their events commit together
+	err := internal.DB.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&internal.PurchaseOrder{}).
+			Where("id = ? AND status = ?", po.ID, "pending").
+			Updates(map[string]interface{}{"status": po.Status, "received_at": po.ReceivedAt})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errAlreadyReceived
+		}
+		for _, item := range po.Items {
+			if err := tx.Model(&item).Updates(map[string]interface{}{
+				"received_quantity": item.ReceivedQuantity,
+				"rejected_quantity": item.RejectedQuantity,
+			}).Error; err != nil {
+				return err
+			}
+			if item.ReceivedQuantity == 0 {
+				continue
+			}
 
-		if err != nil {
-			inv = internal.Inventory{
+			action := "updated"
+			var inv internal.Inventory
+			err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("product_id = ? AND warehouse_id = ?", item.ProductID, req.WarehouseID).
+				First(&inv).Error
+			if err != nil {
+				action = "created"
+				inv = internal.Inventory{
+					ProductID:   item.ProductID,
+					WarehouseID: req.WarehouseID,
+					Quantity:    item.ReceivedQuantity,
+				}
+				err = tx.Create(&inv).Error
+			} else {
+				inv.Quantity += item.ReceivedQuantity
+				err = tx.Save(&inv).Error
+			}
+			if err != nil {
+				return err
+			}
+			movement := internal.StockMovement{
 				ProductID:   item.ProductID,
 				WarehouseID: req.WarehouseID,
-				Quantity:    item.Quantity,
+				Type:        "IN",
+				Quantity:    item.ReceivedQuantity,
+				Reference:   po.PONumber,
+				Reason:      "Purchase order received",
+				CreatedBy:   "system",
+				CreatedAt:   time.Now(),
+			}
+			if err := tx.Create(&movement).Error; err != nil {
+				return err
+			}
+			if err := outbox.Enqueue(tx, websocket.Event{Payload: websocket.InventoryUpdated{
+				InventoryID: inv.ID,
+				ProductID:   inv.ProductID,
+				WarehouseID: inv.WarehouseID,
+				Quantity:    inv.Quantity,
+				Action:      action,
+			}}); err != nil {
+				return err
 			}
-			internal.DB.Create(&inv)
-		} else {
-			inv.Quantity += item.Quantity
-			internal.DB.Save(&inv)
 		}
-		movement := internal.StockMovement{
-			ProductID:   item.ProductID,
-			WarehouseID: req.WarehouseID,
-			Type:        "IN",
-			Quantity:    item.Quantity,
-			Reference:   po.PONumber,
-			Reason:      "Purchase order received",
-			CreatedBy:   "system",
-			CreatedAt:   time.Now(),
+		return outbox.Enqueue(tx, websocket.Event{Payload: received})
+	})
+	if err == errAlreadyReceived {
+		http.Error(w, "Only pending purchase orders can be received", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to receive purchase order", http.StatusInternalServerError)
+		return
+	}
+
+	for _, item := range po.Items {
+		if item.ReceivedQuantity == 0 {
+			continue
+		}
+		if _, err := AllocateBackorders(item.ProductID, req.WarehouseID); err != nil {
+			log.Printf("Backorder allocation failed for product %d in warehouse %d: %v", item.ProductID, req.WarehouseID, err)
 		}
-		internal.DB.Create(&movement)
 	}
-	now := time.Now()
-	po.Status = "received"
-	po.ReceivedAt = &now
-	internal.DB.Save(&po)
 
 	internal.LogAudit("RECEIVE", "PurchaseOrder", po.ID, "system", "Purchase order received")
+	suppliers.RefreshRating(po.SupplierID)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -142,13 +297,59 @@ func ReceivePurchaseOrder(w http.ResponseWriter, r *http.Request) {
 		"data":    po,
 	})
 }
+func ApprovePurchaseOrder(w http.ResponseWriter, r *http.Request) {
+	id := extractID(r.URL.Path, "/purchase-orders/")
+	if id == 0 {
+		http.Error(w, "Invalid purchase order ID", http.StatusBadRequest)
+		return
+	}
+
+	var po internal.PurchaseOrder
+	if err := internal.DB.Preload("Items").First(&po, id).Error; err != nil {
+		http.Error(w, "Purchase order not found", http.StatusNotFound)
+		return
+	}
+	if po.Status != "draft" {
+		http.Error(w, "Only draft purchase orders can be approved", http.StatusBadRequest)
+		return
+	}
+	var supplier internal.Supplier
+	if err := internal.DB.First(&supplier, po.SupplierID).Error; err != nil || !suppliers.CanOrderFrom(supplier) {
+		http.Error(w, "Supplier is suspended or archived", http.StatusBadRequest)
+		return
+	}
+
+	po.Status = "pending"
+	po.OrderDate = time.Now()
+	productIDs := make([]uint, 0, len(po.Items))
+	for _, item := range po.Items {
+		productIDs = append(productIDs, item.ProductID)
+	}
+	po.ExpectedAt = suppliers.ExpectedDelivery(po.SupplierID, productIDs, po.OrderDate)
+	if err := internal.DB.Save(&po).Error; err != nil {
+		http.Error(w, "Failed to approve purchase order", http.StatusInternalServerError)
+		return
+	}
+
+	internal.LogAudit("APPROVE", "PurchaseOrder", po.ID, "system", "Approved draft purchase order")
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   po,
+	})
+}
 func CreateOrder(w http.ResponseWriter, r *http.Request) {
 	var req struct {
-		CustomerName  string `json:"customer_name"`
-		CustomerEmail string `json:"customer_email"`
-		Items         []struct {
+		CustomerName      string   `json:"customer_name"`
+		CustomerEmail     string   `json:"customer_email"`
+		CustomerLatitude  *float64 `json:"customer_latitude"`
+		CustomerLongitude *float64 `json:"customer_longitude"`
+		AllowBackorder    bool     `json:"allow_backorder"`
+		RoutingStrategy   string   `json:"routing_strategy"`
+		Items             []struct {
 			ProductID   uint `json:"product_id"`
-			WarehouseID uint `json:"warehouse_id"`
+			WarehouseID uint `json:"warehouse_id"` // 0 lets the order router pick
 			Quantity    int  `json:"quantity"`
 		} `json:"items"`
 	}
@@ -159,80 +360,194 @@ func CreateOrder(w http.ResponseWriter, r *http.Request) {
 	}
 	orderNumber := fmt.Sprintf("ORD-%d", time.Now().Unix())
 	var total float64
+	prices := make(map[uint]float64)
+	var allocations []Allocation
+	var unrouted []routeLine
+	var productIDs []uint
 	for _, item := range req.Items {
 		var product internal.Product
 		if err := internal.DB.First(&product, item.ProductID).Error; err != nil {
 			http.Error(w, "Product not found", http.StatusNotFound)
 			return
 		}
-		var inv internal.Inventory
-		if err := internal.DB.Where("product_id = ? AND warehouse_id = ?",
-			item.ProductID, item.WarehouseID).First(&inv).Error; err != nil {
+		prices[product.ID] = product.Price
+		total += product.Price * float64(item.Quantity)
+		productIDs = append(productIDs, item.ProductID)
+
+		if item.WarehouseID == 0 {
+			unrouted = append(unrouted, routeLine{ProductID: item.ProductID, Quantity: item.Quantity})
+			continue
+		}
+		allocations = append(allocations, Allocation{ProductID: item.ProductID, WarehouseID: item.WarehouseID, Quantity: item.Quantity})
+	}
+
+	stock, err := loadStock(productIDs)
+	if err != nil {
+		http.Error(w, "Failed to fetch inventory", http.StatusInternalServerError)
+		return
+	}
+	for _, alloc := range allocations {
+		if _, ok := stock[alloc.ProductID][alloc.WarehouseID]; ok {
+			continue
+		}
+		if !req.AllowBackorder {
 			http.Error(w, "Product not available in warehouse", http.StatusBadRequest)
 			return
 		}
-
-		if inv.Quantity < item.Quantity {
-			http.Error(w, "Insufficient stock", http.StatusBadRequest)
+		var warehouse internal.Warehouse
+		if err := internal.DB.First(&warehouse, alloc.WarehouseID).Error; err != nil {
+			http.Error(w, "Warehouse not found", http.StatusNotFound)
 			return
 		}
+	}
+	// Explicitly placed lines take their stock first; routing gets the rest
+	if err := fillAllocations(allocations, stock, req.AllowBackorder); err != nil {
+		http.Error(w, "Insufficient stock", http.StatusBadRequest)
+		return
+	}
 
-		total += product.Price * float64(item.Quantity)
+	if len(unrouted) > 0 {
+		strategy := req.RoutingStrategy
+		if strategy == "" {
+			strategy = defaultRoutingStrategy()
+		}
+		var warehouses []internal.Warehouse
+		if err := internal.DB.Order("id").Find(&warehouses).Error; err != nil {
+			http.Error(w, "Failed to fetch warehouses", http.StatusInternalServerError)
+			return
+		}
+		routed, err := routeLines(strategy, unrouted, warehouses, stock,
+			req.CustomerLatitude, req.CustomerLongitude, req.AllowBackorder)
+		if err != nil {
+			if err == errInsufficientStock {
+				http.Error(w, "Insufficient stock", http.StatusBadRequest)
+			} else {
+				http.Error(w, "Order routing failed: "+err.Error(), http.StatusBadRequest)
+			}
+			return
+		}
+		allocations = append(allocations, routed...)
 	}
 
 	order := internal.Order{
 		OrderNumber:   orderNumber,
 		CustomerName:  req.CustomerName,
 		CustomerEmail: req.CustomerEmail,
-		Status:        "pending",
 		TotalAmount:   total,
 		OrderDate:     time.Now(),
 	}
 
-	if err := internal.DB.Create(&order).Error; err != nil {
-		http.Error(w, "Failed to create order", http.StatusInternalServerError)
-		return
-	}
-	for _, item := range req.Items {
-		var product internal.Product
-		internal.DB.First(&product, item.ProductID)
+	// The order, its stock deductions and their events commit together.
+	// Stock read above may have changed since, so the split between
+	// allocated and backordered units is settled again on locked rows.
+	err = internal.DB.Transaction(func(tx *gorm.DB) error {
+		var rows []internal.Inventory
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("product_id IN ?", productIDs).
+			Order("id").
+			Find(&rows).Error; err != nil {
+			return err
+		}
+		locked := make(map[uint]map[uint]int)
+		inventories := make(map[[2]uint]*internal.Inventory, len(rows))
+		for i, inv := range rows {
+			if locked[inv.ProductID] == nil {
+				locked[inv.ProductID] = make(map[uint]int)
+			}
+			locked[inv.ProductID][inv.WarehouseID] = inv.Quantity
+			inventories[[2]uint{inv.ProductID, inv.WarehouseID}] = &rows[i]
+		}
+		if err := fillAllocations(allocations, locked, req.AllowBackorder); err != nil {
+			return err
+		}
 
-		orderItem := internal.OrderItem{
-			OrderID:     order.ID,
-			ProductID:   item.ProductID,
-			WarehouseID: item.WarehouseID,
-			Quantity:    item.Quantity,
-			UnitPrice:   product.Price,
+		order.Status = "pending"
+		for _, alloc := range allocations {
+			if alloc.Backordered > 0 {
+				order.Status = "backordered"
+				break
+			}
 		}
-		internal.DB.Create(&orderItem)
-		var inv internal.Inventory
-		internal.DB.Where("product_id = ? AND warehouse_id = ?",
-			item.ProductID, item.WarehouseID).First(&inv)
-		inv.Quantity -= item.Quantity
-		internal.DB.Save(&inv)
-		movement := internal.StockMovement{
-			ProductID:   item.ProductID,
-			WarehouseID: item.WarehouseID,
-			Type:        "OUT",
-			Quantity:    -item.Quantity,
-			Reference:   orderNumber,
-			Reason:      "Sales order",
-			CreatedBy:   "system",
-			CreatedAt:   time.Now(),
+		if err := tx.Create(&order).Error; err != nil {
+			return err
 		}
-		internal.DB.Create(&movement)
+		for _, alloc := range allocations {
+			orderItem := internal.OrderItem{
+				OrderID:             order.ID,
+				ProductID:           alloc.ProductID,
+				WarehouseID:         alloc.WarehouseID,
+				Quantity:            alloc.Quantity,
+				BackorderedQuantity: alloc.Backordered,
+				UnitPrice:           prices[alloc.ProductID],
+			}
+			if err := tx.Create(&orderItem).Error; err != nil {
+				return err
+			}
+			order.Items = append(order.Items, orderItem)
+
+			allocated := alloc.Quantity - alloc.Backordered
+			if allocated == 0 {
+				continue
+			}
+			inv := inventories[[2]uint{alloc.ProductID, alloc.WarehouseID}]
+			inv.Quantity -= allocated
+			if err := tx.Save(inv).Error; err != nil {
+				return err
+			}
+			movement := internal.StockMovement{
+				ProductID:   alloc.ProductID,
+				WarehouseID: alloc.WarehouseID,
+				Type:        "OUT",
+				Quantity:    -allocated,
+				Reference:   orderNumber,
+				Reason:      "Sales order",
+				CreatedBy:   "system",
+				CreatedAt:   time.Now(),
+			}
+			if err := tx.Create(&movement).Error; err != nil {
+				return err
+			}
+			if err := outbox.Enqueue(tx, websocket.Event{Payload: websocket.InventoryUpdated{
+				InventoryID: inv.ID,
+				ProductID:   inv.ProductID,
+				WarehouseID: inv.WarehouseID,
+				Quantity:    inv.Quantity,
+				Action:      "allocated",
+			}}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, errInsufficientStock) {
+		http.Error(w, "Insufficient stock", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to create order", http.StatusInternalServerError)
+		return
 	}
 
-	internal.LogAudit("CREATE", "Order", order.ID, "system", "Created new order")
+	if order.Status == "backordered" {
+		internal.LogAudit("CREATE", "Order", order.ID, "system", "Created new order with backordered items")
+	} else {
+		internal.LogAudit("CREATE", "Order", order.ID, "system", "Created new order")
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"status": "success",
-		"data":   order,
+		"status":     "success",
+		"data":       order,
+		"allocation": allocations,
 	})
 }
 func ListOrders(w http.ResponseWriter, r *http.Request) {
+	if format := export.Negotiate(r); format != export.JSON {
+		exportOrders(w, r, format)
+		return
+	}
+
 	var orders []internal.Order
 	query := internal.DB.Preload("Items.Product")
 	status := r.URL.Query().Get("status")
@@ -251,6 +566,47 @@ func ListOrders(w http.ResponseWriter, r *http.Request) {
 		"data":   orders,
 	})
 }
+
+// exportOrders streams one row per order line, repeating the order's
+// columns, so line totals can be summed in a spreadsheet.
+func exportOrders(w http.ResponseWriter, r *http.Request, format export.Format) {
+	type row struct {
+		OrderID             uint
+		OrderNumber         string
+		OrderDate           time.Time
+		Status              string
+		CustomerName        string
+		CustomerEmail       string
+		TotalAmount         float64
+		ProductID           *uint
+		SKU                 string
+		ProductName         string
+		WarehouseID         *uint
+		Quantity            int
+		BackorderedQuantity int
+		UnitPrice           float64
+	}
+	query := internal.DB.Table("orders o").
+		Select(`o.id as order_id, o.order_number, o.order_date, o.status, o.customer_name, o.customer_email, o.total_amount,
+			oi.product_id, p.sku, p.name as product_name, oi.warehouse_id,
+			COALESCE(oi.quantity, 0) as quantity, COALESCE(oi.backordered_quantity, 0) as backordered_quantity,
+			COALESCE(oi.unit_price, 0) as unit_price`).
+		Joins("LEFT JOIN order_items oi ON oi.order_id = o.id").
+		Joins("LEFT JOIN products p ON oi.product_id = p.id").
+		Order("o.created_at DESC, o.id DESC, oi.id")
+	if status := r.URL.Query().Get("status"); status != "" {
+		query = query.Where("o.status = ?", status)
+	}
+	export.Stream(w, format, "orders",
+		[]string{"order_id", "order_number", "order_date", "status", "customer_name", "customer_email", "order_total",
+			"product_id", "sku", "product_name", "warehouse_id", "quantity", "backordered_quantity", "unit_price", "line_total"},
+		query,
+		func(o *row) []interface{} {
+			return []interface{}{o.OrderID, o.OrderNumber, o.OrderDate, o.Status, o.CustomerName, o.CustomerEmail, o.TotalAmount,
+				o.ProductID, o.SKU, o.ProductName, o.WarehouseID, o.Quantity, o.BackorderedQuantity, o.UnitPrice,
+				math.Round(float64(o.Quantity)*o.UnitPrice*100) / 100}
+		})
+}
 func UpdateOrderStatus(w http.ResponseWriter, r *http.Request) {
 	id := extractID(r.URL.Path, "/orders/")
 	if id == 0 {
@@ -272,6 +628,23 @@ func UpdateOrderStatus(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Backorder allocation only picks up orders in "backordered", so the
+	// order can only be cancelled until every line is covered.
+	if req.Status != order.Status && req.Status != "cancelled" {
+		var backordered int64
+		if err := internal.DB.Model(&internal.OrderItem{}).
+			Where("order_id = ? AND backordered_quantity > 0", order.ID).
+			Count(&backordered).Error; err != nil {
+			http.Error(w, "Failed to check backordered items", http.StatusInternalServerError)
+			return
+		}
+		if backordered > 0 {
+			http.Error(w, "Order still has backordered items", http.StatusBadRequest)
+			return
+		}
+	}
+
+	oldStatus := order.Status
 	order.Status = req.Status
 	now := time.Now()
 
@@ -282,7 +655,24 @@ func UpdateOrderStatus(w http.ResponseWriter, r *http.Request) {
 		order.DeliveredAt = &now
 	}
 
-	internal.DB.Save(&order)
+	err := internal.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&order).Error; err != nil {
+			return err
+		}
+		if oldStatus == order.Status {
+			return nil
+		}
+		return outbox.Enqueue(tx, websocket.Event{Payload: websocket.OrderStatusChanged{
+			OrderID:     order.ID,
+			OrderNumber: order.OrderNumber,
+			OldStatus:   oldStatus,
+			Status:      order.Status,
+		}})
+	})
+	if err != nil {
+		http.Error(w, "Failed to update order status", http.StatusInternalServerError)
+		return
+	}
 
 	internal.LogAudit("UPDATE_STATUS", "Order", order.ID, "system", "Updated order status to "+req.Status)
 
@@ -0,0 +1,265 @@
+package orders
+
+import (
+	"errors"
+	"math"
+	"myapp/internal"
+	"os"
+	"sort"
+)
+
+// Routing strategies for order lines submitted without a warehouse
+const (
+	RouteFewestSplits = "fewest_splits"
+	RouteNearest      = "nearest"
+	RouteHighestStock = "highest_stock"
+	RoutePriority     = "priority"
+)
+
+var (
+	errUnknownStrategy   = errors.New("unknown routing strategy")
+	errCustomerLocation  = errors.New("customer_latitude and customer_longitude are required for nearest routing")
+	errInsufficientStock = errors.New("insufficient stock")
+	errNoWarehouses      = errors.New("no warehouses available")
+)
+
+// Allocation is the quantity of a product taken from one warehouse for an
+// order. Backordered is the part of Quantity not yet covered by stock.
+type Allocation struct {
+	ProductID   uint `json:"product_id"`
+	WarehouseID uint `json:"warehouse_id"`
+	Quantity    int  `json:"quantity"`
+	Backordered int  `json:"backordered_quantity"`
+}
+
+type routeLine struct {
+	ProductID uint
+	Quantity  int
+}
+
+// defaultRoutingStrategy reads ORDER_ROUTING_STRATEGY, falling back to
+// fewest_splits.
+func defaultRoutingStrategy() string {
+	if s := os.Getenv("ORDER_ROUTING_STRATEGY"); s != "" {
+		return s
+	}
+	return RouteFewestSplits
+}
+
+// loadStock returns on-hand quantity per product and warehouse for the given
+// products.
+func loadStock(productIDs []uint) (map[uint]map[uint]int, error) {
+	stock := make(map[uint]map[uint]int)
+	for _, id := range productIDs {
+		stock[id] = make(map[uint]int)
+	}
+	var rows []internal.Inventory
+	if err := internal.DB.Where("product_id IN ?", productIDs).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, inv := range rows {
+		stock[inv.ProductID][inv.WarehouseID] = max(inv.Quantity, 0)
+	}
+	return stock, nil
+}
+
+// fillAllocations sets how much of each allocation is backordered, taking
+// what stock covers and consuming it, so lines for the same product and
+// warehouse share one balance. Without allowBackorder any shortfall is
+// errInsufficientStock.
+func fillAllocations(allocations []Allocation, stock map[uint]map[uint]int, allowBackorder bool) error {
+	for i := range allocations {
+		a := &allocations[i]
+		if stock[a.ProductID] == nil {
+			stock[a.ProductID] = make(map[uint]int)
+		}
+		available := max(stock[a.ProductID][a.WarehouseID], 0)
+		filled := min(a.Quantity, available)
+		a.Backordered = a.Quantity - filled
+		if a.Backordered > 0 && !allowBackorder {
+			return errInsufficientStock
+		}
+		stock[a.ProductID][a.WarehouseID] = available - filled
+	}
+	return nil
+}
+
+// routeLines assigns each line to warehouses using the given strategy.
+// A line is kept in a single warehouse when one can fill it; otherwise it is
+// split across warehouses in strategy order. Any quantity that cannot be
+// covered is backordered on the first-ranked warehouse when allowBackorder
+// is set, and is an error otherwise. stock is consumed as lines are routed.
+func routeLines(strategy string, lines []routeLine, warehouses []internal.Warehouse,
+	stock map[uint]map[uint]int, customerLat, customerLng *float64, allowBackorder bool) ([]Allocation, error) {
+	if len(lines) == 0 {
+		return nil, nil
+	}
+	if len(warehouses) == 0 {
+		return nil, errNoWarehouses
+	}
+
+	var ranked []internal.Warehouse
+	switch strategy {
+	case RouteFewestSplits:
+		return routeFewestSplits(lines, warehouses, stock, allowBackorder)
+	case RouteNearest:
+		if customerLat == nil || customerLng == nil {
+			return nil, errCustomerLocation
+		}
+		ranked = rankByDistance(warehouses, *customerLat, *customerLng)
+	case RoutePriority:
+		ranked = append([]internal.Warehouse(nil), warehouses...)
+		sort.SliceStable(ranked, func(i, j int) bool {
+			return ranked[i].Priority > ranked[j].Priority
+		})
+	case RouteHighestStock:
+		// Ranked per line below
+	default:
+		return nil, errUnknownStrategy
+	}
+
+	var allocations []Allocation
+	for _, line := range lines {
+		order := ranked
+		if strategy == RouteHighestStock {
+			order = rankByStock(warehouses, stock[line.ProductID])
+		}
+		allocs, err := fillLine(line, order, stock, allowBackorder)
+		if err != nil {
+			return nil, err
+		}
+		allocations = append(allocations, allocs...)
+	}
+	return allocations, nil
+}
+
+// routeFewestSplits repeatedly picks the warehouse that can completely fill
+// the most remaining lines, so the order ships from as few places as
+// possible. Lines no single warehouse can fill are split, largest stock first.
+func routeFewestSplits(lines []routeLine, warehouses []internal.Warehouse,
+	stock map[uint]map[uint]int, allowBackorder bool) ([]Allocation, error) {
+	var allocations []Allocation
+	remaining := append([]routeLine(nil), lines...)
+
+	for len(remaining) > 0 {
+		bestIdx, bestCount, bestUnits := -1, 0, 0
+		for i, wh := range warehouses {
+			count, units := 0, 0
+			for _, line := range remaining {
+				if stock[line.ProductID][wh.ID] >= line.Quantity {
+					count++
+					units += line.Quantity
+				}
+			}
+			if count > bestCount || (count == bestCount && count > 0 && units > bestUnits) {
+				bestIdx, bestCount, bestUnits = i, count, units
+			}
+		}
+		if bestIdx == -1 {
+			break
+		}
+
+		wh := warehouses[bestIdx]
+		var rest []routeLine
+		for _, line := range remaining {
+			if stock[line.ProductID][wh.ID] >= line.Quantity {
+				stock[line.ProductID][wh.ID] -= line.Quantity
+				allocations = append(allocations, Allocation{
+					ProductID:   line.ProductID,
+					WarehouseID: wh.ID,
+					Quantity:    line.Quantity,
+				})
+			} else {
+				rest = append(rest, line)
+			}
+		}
+		remaining = rest
+	}
+
+	for _, line := range remaining {
+		allocs, err := fillLine(line, rankByStock(warehouses, stock[line.ProductID]), stock, allowBackorder)
+		if err != nil {
+			return nil, err
+		}
+		allocations = append(allocations, allocs...)
+	}
+	return allocations, nil
+}
+
+// fillLine takes a line from the first warehouse able to fill it, or splits
+// it across warehouses in the given order.
+func fillLine(line routeLine, ranked []internal.Warehouse, stock map[uint]map[uint]int, allowBackorder bool) ([]Allocation, error) {
+	for _, wh := range ranked {
+		if stock[line.ProductID][wh.ID] >= line.Quantity {
+			stock[line.ProductID][wh.ID] -= line.Quantity
+			return []Allocation{{ProductID: line.ProductID, WarehouseID: wh.ID, Quantity: line.Quantity}}, nil
+		}
+	}
+
+	var allocations []Allocation
+	need := line.Quantity
+	for _, wh := range ranked {
+		qty := min(stock[line.ProductID][wh.ID], need)
+		if qty <= 0 {
+			continue
+		}
+		stock[line.ProductID][wh.ID] -= qty
+		need -= qty
+		allocations = append(allocations, Allocation{ProductID: line.ProductID, WarehouseID: wh.ID, Quantity: qty})
+	}
+	if need == 0 {
+		return allocations, nil
+	}
+	if !allowBackorder {
+		return nil, errInsufficientStock
+	}
+
+	// Backorder the shortfall on the preferred warehouse
+	for i := range allocations {
+		if allocations[i].WarehouseID == ranked[0].ID {
+			allocations[i].Quantity += need
+			allocations[i].Backordered = need
+			return allocations, nil
+		}
+	}
+	return append(allocations, Allocation{
+		ProductID:   line.ProductID,
+		WarehouseID: ranked[0].ID,
+		Quantity:    need,
+		Backordered: need,
+	}), nil
+}
+
+func rankByStock(warehouses []internal.Warehouse, stock map[uint]int) []internal.Warehouse {
+	ranked := append([]internal.Warehouse(nil), warehouses...)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return stock[ranked[i].ID] > stock[ranked[j].ID]
+	})
+	return ranked
+}
+
+// rankByDistance orders warehouses nearest first; warehouses without
+// coordinates go last.
+func rankByDistance(warehouses []internal.Warehouse, lat, lng float64) []internal.Warehouse {
+	distance := func(wh internal.Warehouse) float64 {
+		if wh.Latitude == nil || wh.Longitude == nil {
+			return math.Inf(1)
+		}
+		return haversineKm(lat, lng, *wh.Latitude, *wh.Longitude)
+	}
+	ranked := append([]internal.Warehouse(nil), warehouses...)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return distance(ranked[i]) < distance(ranked[j])
+	})
+	return ranked
+}
+
+func haversineKm(lat1, lng1, lat2, lng2 float64) float64 {
+	const earthRadiusKm = 6371.0
+	toRad := func(deg float64) float64 { return deg * math.Pi / 180 }
+	dLat := toRad(lat2 - lat1)
+	dLng := toRad(lng2 - lng1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRad(lat1))*math.Cos(toRad(lat2))*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return earthRadiusKm * 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
@@ -0,0 +1,242 @@
+package orders
+
+import (
+	"myapp/internal"
+	"slices"
+	"testing"
+)
+
+func ptr(f float64) *float64 { return &f }
+
+// Warehouse 2 has the highest priority and sits near (10, 10); warehouse 3
+// has no coordinates.
+var testWarehouses = []internal.Warehouse{
+	{ID: 1, Priority: 1, Latitude: ptr(0), Longitude: ptr(0)},
+	{ID: 2, Priority: 5, Latitude: ptr(10), Longitude: ptr(10)},
+	{ID: 3},
+}
+
+func TestRouteLines(t *testing.T) {
+	tests := []struct {
+		name       string
+		strategy   string
+		lines      []routeLine
+		stock      map[uint]map[uint]int
+		lat, lng   *float64
+		backorder  bool
+		want       []Allocation
+		wantErr    error
+		wantRemain map[uint]map[uint]int
+	}{
+		{
+			name:     "fewest splits ships from the warehouse holding every line",
+			strategy: RouteFewestSplits,
+			lines:    []routeLine{{1, 5}, {2, 5}},
+			stock:    map[uint]map[uint]int{1: {1: 10, 2: 5}, 2: {2: 5}},
+			want:     []Allocation{{1, 2, 5, 0}, {2, 2, 5, 0}},
+		},
+		{
+			name:       "fewest splits consumes stock between lines",
+			strategy:   RouteFewestSplits,
+			lines:      []routeLine{{1, 6}, {1, 6}},
+			stock:      map[uint]map[uint]int{1: {1: 10, 2: 6}},
+			want:       []Allocation{{1, 1, 6, 0}, {1, 2, 6, 0}},
+			wantRemain: map[uint]map[uint]int{1: {1: 4, 2: 0}},
+		},
+		{
+			name:     "fewest splits splits largest stock first",
+			strategy: RouteFewestSplits,
+			lines:    []routeLine{{1, 12}},
+			stock:    map[uint]map[uint]int{1: {1: 10, 2: 5}},
+			want:     []Allocation{{1, 1, 10, 0}, {1, 2, 2, 0}},
+		},
+		{
+			name:     "nearest picks the closest warehouse with stock",
+			strategy: RouteNearest,
+			lines:    []routeLine{{1, 3}},
+			stock:    map[uint]map[uint]int{1: {1: 10, 2: 10, 3: 10}},
+			lat:      ptr(9),
+			lng:      ptr(9),
+			want:     []Allocation{{1, 2, 3, 0}},
+		},
+		{
+			name:     "nearest falls back to warehouses without coordinates",
+			strategy: RouteNearest,
+			lines:    []routeLine{{1, 3}},
+			stock:    map[uint]map[uint]int{1: {3: 10}},
+			lat:      ptr(9),
+			lng:      ptr(9),
+			want:     []Allocation{{1, 3, 3, 0}},
+		},
+		{
+			name:     "nearest needs the customer location",
+			strategy: RouteNearest,
+			lines:    []routeLine{{1, 3}},
+			stock:    map[uint]map[uint]int{1: {1: 10}},
+			wantErr:  errCustomerLocation,
+		},
+		{
+			name:     "highest stock ranks per line",
+			strategy: RouteHighestStock,
+			lines:    []routeLine{{1, 3}, {2, 3}},
+			stock:    map[uint]map[uint]int{1: {1: 4, 2: 8}, 2: {1: 9, 3: 5}},
+			want:     []Allocation{{1, 2, 3, 0}, {2, 1, 3, 0}},
+		},
+		{
+			name:     "priority prefers the highest priority",
+			strategy: RoutePriority,
+			lines:    []routeLine{{1, 3}},
+			stock:    map[uint]map[uint]int{1: {1: 10, 2: 10}},
+			want:     []Allocation{{1, 2, 3, 0}},
+		},
+		{
+			name:     "priority splits in priority order",
+			strategy: RoutePriority,
+			lines:    []routeLine{{1, 12}},
+			stock:    map[uint]map[uint]int{1: {1: 10, 2: 5}},
+			want:     []Allocation{{1, 2, 5, 0}, {1, 1, 7, 0}},
+		},
+		{
+			name:      "shortfall is backordered on the first ranked warehouse",
+			strategy:  RoutePriority,
+			lines:     []routeLine{{1, 20}},
+			stock:     map[uint]map[uint]int{1: {1: 10, 2: 5}},
+			backorder: true,
+			want:      []Allocation{{1, 2, 10, 5}, {1, 1, 10, 0}},
+		},
+		{
+			name:      "backorder without any stock",
+			strategy:  RoutePriority,
+			lines:     []routeLine{{1, 4}},
+			stock:     map[uint]map[uint]int{1: {}},
+			backorder: true,
+			want:      []Allocation{{1, 2, 4, 4}},
+		},
+		{
+			name:      "backorder on a first ranked warehouse that had no stock",
+			strategy:  RoutePriority,
+			lines:     []routeLine{{1, 8}},
+			stock:     map[uint]map[uint]int{1: {1: 5}},
+			backorder: true,
+			want:      []Allocation{{1, 1, 5, 0}, {1, 2, 3, 3}},
+		},
+		{
+			name:     "shortfall without backorders fails",
+			strategy: RouteFewestSplits,
+			lines:    []routeLine{{1, 20}},
+			stock:    map[uint]map[uint]int{1: {1: 10, 2: 5}},
+			wantErr:  errInsufficientStock,
+		},
+		{
+			name:     "unknown strategy",
+			strategy: "cheapest",
+			lines:    []routeLine{{1, 1}},
+			stock:    map[uint]map[uint]int{1: {1: 1}},
+			wantErr:  errUnknownStrategy,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := routeLines(tt.strategy, tt.lines, testWarehouses, tt.stock, tt.lat, tt.lng, tt.backorder)
+			if err != tt.wantErr {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("allocations = %+v, want %+v", got, tt.want)
+			}
+			for productID, byWarehouse := range tt.wantRemain {
+				for warehouseID, qty := range byWarehouse {
+					if tt.stock[productID][warehouseID] != qty {
+						t.Errorf("stock of product %d in warehouse %d = %d, want %d",
+							productID, warehouseID, tt.stock[productID][warehouseID], qty)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestRouteLinesWithoutWarehouses(t *testing.T) {
+	if _, err := routeLines(RoutePriority, []routeLine{{1, 1}}, nil, nil, nil, nil, true); err != errNoWarehouses {
+		t.Fatalf("err = %v, want %v", err, errNoWarehouses)
+	}
+	if got, err := routeLines(RoutePriority, nil, nil, nil, nil, nil, true); err != nil || got != nil {
+		t.Fatalf("no lines = %v, %v", got, err)
+	}
+}
+
+func TestFillAllocations(t *testing.T) {
+	tests := []struct {
+		name      string
+		allocs    []Allocation
+		stock     map[uint]map[uint]int
+		backorder bool
+		want      []Allocation
+		wantErr   error
+	}{
+		{
+			name:   "covered by stock",
+			allocs: []Allocation{{ProductID: 1, WarehouseID: 1, Quantity: 4}},
+			stock:  map[uint]map[uint]int{1: {1: 10}},
+			want:   []Allocation{{1, 1, 4, 0}},
+		},
+		{
+			name:      "shortfall is backordered",
+			allocs:    []Allocation{{ProductID: 1, WarehouseID: 1, Quantity: 12}},
+			stock:     map[uint]map[uint]int{1: {1: 10}},
+			backorder: true,
+			want:      []Allocation{{1, 1, 12, 2}},
+		},
+		{
+			name: "lines for the same product and warehouse share stock",
+			allocs: []Allocation{
+				{ProductID: 1, WarehouseID: 1, Quantity: 6},
+				{ProductID: 1, WarehouseID: 1, Quantity: 6},
+			},
+			stock:     map[uint]map[uint]int{1: {1: 10}},
+			backorder: true,
+			want:      []Allocation{{1, 1, 6, 0}, {1, 1, 6, 2}},
+		},
+		{
+			name: "shared stock runs short without backorders",
+			allocs: []Allocation{
+				{ProductID: 1, WarehouseID: 1, Quantity: 6},
+				{ProductID: 1, WarehouseID: 1, Quantity: 6},
+			},
+			stock:   map[uint]map[uint]int{1: {1: 10}},
+			wantErr: errInsufficientStock,
+		},
+		{
+			name:      "negative stock counts as none",
+			allocs:    []Allocation{{ProductID: 1, WarehouseID: 1, Quantity: 3}},
+			stock:     map[uint]map[uint]int{1: {1: -2}},
+			backorder: true,
+			want:      []Allocation{{1, 1, 3, 3}},
+		},
+		{
+			name:      "no inventory row",
+			allocs:    []Allocation{{ProductID: 2, WarehouseID: 1, Quantity: 3}},
+			stock:     map[uint]map[uint]int{},
+			backorder: true,
+			want:      []Allocation{{2, 1, 3, 3}},
+		},
+		{
+			name:   "backordered quantity from routing is recomputed",
+			allocs: []Allocation{{ProductID: 1, WarehouseID: 2, Quantity: 5, Backordered: 5}},
+			stock:  map[uint]map[uint]int{1: {2: 5}},
+			want:   []Allocation{{1, 2, 5, 0}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := fillAllocations(tt.allocs, tt.stock, tt.backorder)
+			if err != tt.wantErr {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && !slices.Equal(tt.allocs, tt.want) {
+				t.Errorf("allocations = %+v, want %+v", tt.allocs, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,202 @@
+// Package outbox records domain events in the same transaction as the
+// change that caused them and dispatches them afterwards, so clients only
+// ever see events for changes that were committed.
+package outbox
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"myapp/internal"
+	"myapp/internal/websocket"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	batchSize = 100
+	// maxAttempts is how often a failing event is retried before it is left
+	// in the table for inspection.
+	maxAttempts = 10
+	maxBackoff  = 5 * time.Minute
+	// claimTimeout is how long a dispatcher owns the events it claimed.
+	claimTimeout = time.Minute
+)
+
+// Sink receives dispatched events. Delivery is at least once: after a
+// crash a sink may see the same outbox ID again. A failing sink is retried
+// on its own; the others are not called again.
+type Sink func(id uint, event websocket.Event) error
+
+type namedSink struct {
+	name string
+	fn   Sink
+}
+
+var sinks []namedSink
+
+// AddSink registers a destination for dispatched events. It must be called
+// before Start.
+func AddSink(name string, sink Sink) {
+	sinks = append(sinks, namedSink{name: name, fn: sink})
+}
+
+// HubSink publishes events to WebSocket clients.
+func HubSink(id uint, event websocket.Event) error {
+	hub := websocket.GetHub()
+	if hub == nil {
+		return nil
+	}
+	return hub.Publish(event)
+}
+
+// Enqueue records event in tx. It is dispatched only once tx commits.
+func Enqueue(tx *gorm.DB, event websocket.Event) error {
+	if err := event.Validate(); err != nil {
+		return err
+	}
+	payload, err := json.Marshal(event.Payload)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	return tx.Create(&internal.OutboxEvent{
+		Type:          event.Payload.EventType(),
+		Actor:         event.Actor,
+		Payload:       string(payload),
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	}).Error
+}
+
+// Start dispatches pending events every interval and deletes delivered
+// events older than retention once an hour.
+func Start(interval, retention time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			for {
+				n, err := dispatch()
+				if err != nil {
+					log.Printf("Outbox dispatch failed: %v", err)
+				}
+				if n < batchSize {
+					break
+				}
+			}
+		}
+	}()
+
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := cleanup(retention); err != nil {
+				log.Printf("Outbox cleanup failed: %v", err)
+			}
+		}
+	}()
+}
+
+// dispatch delivers a batch of due events to the sinks that have not had
+// them yet. A failure on one event does not hold up the rest of the batch,
+// which would otherwise stay leased until claimTimeout.
+func dispatch() (int, error) {
+	rows, err := claim()
+	if err != nil {
+		return 0, err
+	}
+	var errs []error
+	for _, row := range rows {
+		if err := attempt(row); err != nil {
+			log.Printf("Outbox event %d (%s): failed to record attempt: %v", row.ID, row.Type, err)
+			errs = append(errs, fmt.Errorf("event %d: %w", row.ID, err))
+		}
+	}
+	return len(rows), errors.Join(errs...)
+}
+
+// claim takes a batch of due events. Rows are locked with SKIP LOCKED so
+// several API instances can dispatch side by side, and their next attempt
+// is pushed past claimTimeout so nobody else picks them up while the sinks
+// run outside the transaction. After a crash they become due again.
+func claim() ([]internal.OutboxEvent, error) {
+	var rows []internal.OutboxEvent
+	err := internal.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("delivered_at IS NULL AND next_attempt_at <= ? AND attempts < ?", now, maxAttempts).
+			Order("id").Limit(batchSize).Find(&rows).Error; err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		ids := make([]uint, len(rows))
+		for i, row := range rows {
+			ids[i] = row.ID
+		}
+		return tx.Model(&internal.OutboxEvent{}).Where("id IN ?", ids).
+			Update("next_attempt_at", now.Add(claimTimeout)).Error
+	})
+	return rows, err
+}
+
+// attempt delivers a claimed event and records the outcome. Sinks that
+// succeed are remembered, so when another sink fails only that one is
+// retried.
+func attempt(row internal.OutboxEvent) error {
+	delivered, err := deliver(row)
+	now := time.Now()
+	row.Attempts++
+	row.DeliveredSinks = delivered
+	if err != nil {
+		backoff := min(time.Duration(1<<min(row.Attempts-1, 16))*time.Second, maxBackoff)
+		row.LastError = err.Error()
+		row.NextAttemptAt = now.Add(backoff)
+		log.Printf("Outbox event %d (%s) attempt %d failed: %v", row.ID, row.Type, row.Attempts, err)
+	} else {
+		row.LastError = ""
+		row.DeliveredAt = &now
+	}
+	return internal.DB.Model(&row).
+		Select("attempts", "delivered_sinks", "last_error", "next_attempt_at", "delivered_at").
+		Updates(&row).Error
+}
+
+// deliver sends row to every sink not in row.DeliveredSinks and returns the
+// sinks that now have it.
+func deliver(row internal.OutboxEvent) ([]string, error) {
+	delivered := slices.Clone(row.DeliveredSinks)
+	payload, err := websocket.DecodePayload(row.Type, []byte(row.Payload))
+	if err != nil {
+		return delivered, err
+	}
+	event := websocket.Event{
+		Timestamp: row.CreatedAt.Format(time.RFC3339),
+		Actor:     row.Actor,
+		Payload:   payload,
+	}
+	var errs []error
+	for _, sink := range sinks {
+		if slices.Contains(delivered, sink.name) {
+			continue
+		}
+		if err := sink.fn(row.ID, event); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", sink.name, err))
+			continue
+		}
+		delivered = append(delivered, sink.name)
+	}
+	return delivered, errors.Join(errs...)
+}
+
+func cleanup(retention time.Duration) error {
+	return internal.DB.Where("delivered_at < ?", time.Now().Add(-retention)).
+		Delete(&internal.OutboxEvent{}).Error
+}
@@ -0,0 +1,69 @@
+package outbox
+
+import (
+	"encoding/json"
+	"errors"
+	"myapp/internal"
+	"myapp/internal/websocket"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestDeliverSkipsSinksThatHaveTheEvent(t *testing.T) {
+	defer func(saved []namedSink) { sinks = saved }(sinks)
+
+	calls := map[string]int{}
+	failing := true
+	sinks = nil
+	AddSink("websocket", func(uint, websocket.Event) error {
+		calls["websocket"]++
+		return nil
+	})
+	AddSink("webhooks", func(uint, websocket.Event) error {
+		calls["webhooks"]++
+		if failing {
+			return errors.New("endpoint down")
+		}
+		return nil
+	})
+	AddSink("audit", func(uint, websocket.Event) error {
+		calls["audit"]++
+		return nil
+	})
+
+	payload, _ := json.Marshal(websocket.SupplierStatusAlert{SupplierID: 1, Status: "warning"})
+	row := internal.OutboxEvent{ID: 7, Type: "supplier_status_alert", Payload: string(payload), CreatedAt: time.Now()}
+
+	delivered, err := deliver(row)
+	if err == nil {
+		t.Fatal("expected the webhooks failure to be reported")
+	}
+	if !slices.Equal(delivered, []string{"websocket", "audit"}) {
+		t.Fatalf("delivered = %v, want [websocket audit]", delivered)
+	}
+
+	// The retry only calls the sink that failed
+	failing = false
+	row.DeliveredSinks = delivered
+	delivered, err = deliver(row)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(delivered) != 3 {
+		t.Fatalf("delivered = %v, want all three sinks", delivered)
+	}
+	want := map[string]int{"websocket": 1, "webhooks": 2, "audit": 1}
+	for name, n := range want {
+		if calls[name] != n {
+			t.Errorf("%s called %d times, want %d", name, calls[name], n)
+		}
+	}
+}
+
+func TestDeliverRejectsUnknownEventType(t *testing.T) {
+	row := internal.OutboxEvent{ID: 1, Type: "no_such_event", Payload: "{}"}
+	if _, err := deliver(row); err == nil {
+		t.Fatal("expected an error for an unknown event type")
+	}
+}
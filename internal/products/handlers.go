@@ -3,10 +3,13 @@ package products
 import (
 	"encoding/json"
 	"myapp/internal"
+	"myapp/internal/outbox"
 	"myapp/internal/websocket"
 	"net/http"
 	"strconv"
 	"strings"
+
+	"gorm.io/gorm"
 )
 
 func CreateProduct(w http.ResponseWriter, r *http.Request) {
@@ -15,18 +18,30 @@ func CreateProduct(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid request payload", http.StatusBadRequest)
 		return
 	}
+	// Classes are only set by classification runs
+	product.ABCClass, product.XYZClass = "", ""
 
-	if err := internal.DB.Create(&product).Error; err != nil {
+	// The event is written with the product so it is only sent if the
+	// product is saved
+	err := internal.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&product).Error; err != nil {
+			return err
+		}
+		return outbox.Enqueue(tx, websocket.Event{Payload: websocket.ProductUpdated{
+			ProductID:   product.ID,
+			ProductName: product.Name,
+			SKU:         product.SKU,
+			Category:    product.Category,
+			Price:       product.Price,
+			Action:      "created",
+		}})
+	})
+	if err != nil {
 		http.Error(w, "Failed to create product", http.StatusInternalServerError)
 		return
 	}
 	internal.LogAudit("CREATE", "Product", product.ID, "system", "Created new product")
 
-	// Broadcast product creation via WebSocket
-	if hub := websocket.GetHub(); hub != nil {
-		hub.BroadcastProductUpdate(product.ID, product.Name, product.SKU, product.Category, product.Price, "created")
-	}
-
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -41,6 +56,7 @@ func ListProducts(w http.ResponseWriter, r *http.Request) {
 	if category != "" {
 		query = query.Where("category = ?", category)
 	}
+	query = filterByClass(query, r.URL.Query().Get("abc"), r.URL.Query().Get("xyz"), r.URL.Query().Get("warehouse_id"))
 
 	if err := query.Find(&products).Error; err != nil {
 		http.Error(w, "Failed to fetch products", http.StatusInternalServerError)
@@ -53,6 +69,33 @@ func ListProducts(w http.ResponseWriter, r *http.Request) {
 		"data":   products,
 	})
 }
+
+// filterByClass limits products to comma-separated ABC and XYZ classes,
+// e.g. ?abc=A,B&xyz=X. Classes are the overall ones stored on the product,
+// or with a warehouse ID those of that warehouse in the latest run.
+func filterByClass(query *gorm.DB, abc, xyz, warehouseID string) *gorm.DB {
+	if abc == "" && xyz == "" {
+		return query
+	}
+	if warehouseID == "" {
+		if abc != "" {
+			query = query.Where("abc_class IN ?", strings.Split(abc, ","))
+		}
+		if xyz != "" {
+			query = query.Where("xyz_class IN ?", strings.Split(xyz, ","))
+		}
+		return query
+	}
+	classes := internal.DB.Model(&internal.ProductClass{}).Select("product_id").
+		Where("warehouse_id = ? AND run_id = (SELECT MAX(id) FROM classification_runs)", warehouseID)
+	if abc != "" {
+		classes = classes.Where("abc IN ?", strings.Split(abc, ","))
+	}
+	if xyz != "" {
+		classes = classes.Where("xyz IN ?", strings.Split(xyz, ","))
+	}
+	return query.Where("id IN (?)", classes)
+}
 func GetProduct(w http.ResponseWriter, r *http.Request) {
 	id := extractID(r.URL.Path, "/products/")
 	if id == 0 {
@@ -90,30 +133,49 @@ func UpdateProduct(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid request payload", http.StatusBadRequest)
 		return
 	}
+	// Classes are only set by classification runs
+	updates.ABCClass, updates.XYZClass = "", ""
 
 	// Store old price for price change alerts
 	oldPrice := product.Price
 
-	if err := internal.DB.Model(&product).Updates(updates).Error; err != nil {
-		http.Error(w, "Failed to update product", http.StatusInternalServerError)
-		return
-	}
-
-	internal.LogAudit("UPDATE", "Product", product.ID, "system", "Updated product")
+	err := internal.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&product).Updates(updates).Error; err != nil {
+			return err
+		}
+		if err := outbox.Enqueue(tx, websocket.Event{Payload: websocket.ProductUpdated{
+			ProductID:   product.ID,
+			ProductName: product.Name,
+			SKU:         product.SKU,
+			Category:    product.Category,
+			Price:       product.Price,
+			Action:      "updated",
+		}}); err != nil {
+			return err
+		}
 
-	// Broadcast product update via WebSocket
-	if hub := websocket.GetHub(); hub != nil {
-		hub.BroadcastProductUpdate(product.ID, product.Name, product.SKU, product.Category, product.Price, "updated")
-		
 		// Send price alert if price changed by more than 10%
 		if oldPrice > 0 && product.Price > 0 {
 			changePercent := ((product.Price - oldPrice) / oldPrice) * 100
 			if changePercent > 10 || changePercent < -10 {
-				hub.BroadcastProductPriceAlert(product.ID, product.Name, oldPrice, product.Price, changePercent)
+				return outbox.Enqueue(tx, websocket.Event{Payload: websocket.ProductPriceAlert{
+					ProductID:     product.ID,
+					ProductName:   product.Name,
+					OldPrice:      oldPrice,
+					NewPrice:      product.Price,
+					ChangePercent: changePercent,
+				}})
 			}
 		}
+		return nil
+	})
+	if err != nil {
+		http.Error(w, "Failed to update product", http.StatusInternalServerError)
+		return
 	}
 
+	internal.LogAudit("UPDATE", "Product", product.ID, "system", "Updated product")
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"status": "success",
@@ -127,31 +189,32 @@ func DeleteProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Fetch product details before deletion for WebSocket broadcast
 	var product internal.Product
 	if err := internal.DB.First(&product, id).Error; err != nil {
 		http.Error(w, "Product not found", http.StatusNotFound)
 		return
 	}
 
-	productName := product.Name
-	productSKU := product.SKU
-	productCategory := product.Category
-	productPrice := product.Price
-	productID := product.ID
-
-	if err := internal.DB.Delete(&product).Error; err != nil {
+	err := internal.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&product).Error; err != nil {
+			return err
+		}
+		return outbox.Enqueue(tx, websocket.Event{Payload: websocket.ProductUpdated{
+			ProductID:   product.ID,
+			ProductName: product.Name,
+			SKU:         product.SKU,
+			Category:    product.Category,
+			Price:       product.Price,
+			Action:      "deleted",
+		}})
+	})
+	if err != nil {
 		http.Error(w, "Failed to delete product", http.StatusInternalServerError)
 		return
 	}
 
 	internal.LogAudit("DELETE", "Product", uint(id), "system", "Deleted product")
 
-	// Broadcast product deletion via WebSocket
-	if hub := websocket.GetHub(); hub != nil {
-		hub.BroadcastProductUpdate(productID, productName, productSKU, productCategory, productPrice, "deleted")
-	}
-
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"status":  "success",
@@ -0,0 +1,223 @@
+package replenishment
+
+import (
+	"fmt"
+	"log"
+	"myapp/internal"
+	"myapp/internal/suppliers"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Suggestion is a reorder proposal for one product in one warehouse.
+type Suggestion struct {
+	ProductID   uint    `json:"product_id"`
+	ProductName string  `json:"product_name"`
+	SKU         string  `json:"sku"`
+	WarehouseID uint    `json:"warehouse_id"`
+	SupplierID  uint    `json:"supplier_id,omitempty"`
+	OnHand      int     `json:"on_hand"`
+	OnOrder     int     `json:"on_order"`
+	MinStock    int     `json:"min_stock"`
+	MaxStock    int     `json:"max_stock"`
+	Quantity    int     `json:"quantity"`
+	UnitPrice   float64 `json:"unit_price"`
+}
+
+// Result summarises one replenishment run.
+type Result struct {
+	Suggestions    []Suggestion             `json:"suggestions"`
+	Unassigned     []Suggestion             `json:"unassigned"` // no orderable preferred supplier
+	PurchaseOrders []internal.PurchaseOrder `json:"purchase_orders"`
+}
+
+// Run finds inventory at or below its reorder point (MinStock), tops it up
+// to MaxStock less what is already on hand and on order, and groups the
+// lines into one draft purchase order per supplier and warehouse. With
+// dryRun set nothing is written.
+func Run(dryRun bool) (*Result, error) {
+	var rows []internal.Inventory
+	if err := internal.DB.Preload("Product").
+		Where("quantity <= min_stock").
+		Order("warehouse_id, product_id").
+		Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	onOrder, err := loadOnOrder()
+	if err != nil {
+		return nil, err
+	}
+
+	orderable := make(map[uint]bool)
+	var supplierList []internal.Supplier
+	if err := internal.DB.Find(&supplierList).Error; err != nil {
+		return nil, err
+	}
+	for _, sup := range supplierList {
+		orderable[sup.ID] = suppliers.CanOrderFrom(sup)
+	}
+
+	result := &Result{}
+	type poKey struct{ supplierID, warehouseID uint }
+	groups := make(map[poKey][]Suggestion)
+	var keys []poKey
+
+	for _, inv := range rows {
+		qty, incoming := reorderQuantity(inv, onOrder)
+		if qty <= 0 {
+			continue
+		}
+		s := Suggestion{
+			ProductID:   inv.ProductID,
+			ProductName: inv.Product.Name,
+			SKU:         inv.Product.SKU,
+			WarehouseID: inv.WarehouseID,
+			OnHand:      inv.Quantity,
+			OnOrder:     incoming,
+			MinStock:    inv.MinStock,
+			MaxStock:    inv.MaxStock,
+			Quantity:    qty,
+			UnitPrice:   inv.Product.Cost,
+		}
+		if inv.Product.PreferredSupplierID == nil || !orderable[*inv.Product.PreferredSupplierID] {
+			result.Unassigned = append(result.Unassigned, s)
+			continue
+		}
+		s.SupplierID = *inv.Product.PreferredSupplierID
+		if entry, err := suppliers.FindCatalogueEntry(s.SupplierID, s.ProductID); err == nil {
+			s.Quantity = max(s.Quantity, entry.MinOrderQuantity)
+			s.UnitPrice = suppliers.PriceForQuantity(entry, s.Quantity)
+		}
+		result.Suggestions = append(result.Suggestions, s)
+
+		k := poKey{s.SupplierID, s.WarehouseID}
+		if _, ok := groups[k]; !ok {
+			keys = append(keys, k)
+		}
+		groups[k] = append(groups[k], s)
+	}
+
+	if dryRun {
+		return result, nil
+	}
+
+	for _, k := range keys {
+		po, err := createDraft(k.supplierID, k.warehouseID, groups[k])
+		if err != nil {
+			return nil, err
+		}
+		result.PurchaseOrders = append(result.PurchaseOrders, *po)
+		internal.LogAudit("CREATE", "PurchaseOrder", po.ID, "system", "Drafted replenishment purchase order")
+	}
+	return result, nil
+}
+
+type stockKey struct{ productID, warehouseID uint }
+
+// reorderQuantity is what tops inv up to MaxStock given what is on hand and
+// on order, and the quantity counted as on order for it. Open orders
+// without a destination cover the product's first shortfalls, each unit
+// counted once: what inv takes from that pool is removed from onOrder.
+func reorderQuantity(inv internal.Inventory, onOrder map[stockKey]int) (qty, incoming int) {
+	incoming = onOrder[stockKey{inv.ProductID, inv.WarehouseID}]
+	qty = inv.MaxStock - inv.Quantity - incoming
+	pool := stockKey{inv.ProductID, 0}
+	if qty > 0 && onOrder[pool] > 0 {
+		covered := min(qty, onOrder[pool])
+		onOrder[pool] -= covered
+		incoming += covered
+		qty -= covered
+	}
+	return qty, incoming
+}
+
+// loadOnOrder sums quantities on open (draft or pending) purchase orders per
+// product and destination warehouse. Orders without a warehouse are keyed
+// by warehouse 0.
+func loadOnOrder() (map[stockKey]int, error) {
+	var rows []struct {
+		ProductID   uint
+		WarehouseID uint
+		Quantity    int
+	}
+	if err := internal.DB.Table("po_items pi").
+		Select("pi.product_id, po.warehouse_id, SUM(pi.quantity) as quantity").
+		Joins("JOIN purchase_orders po ON pi.po_id = po.id").
+		Where("po.status IN ?", []string{"draft", "pending"}).
+		Group("pi.product_id, po.warehouse_id").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	onOrder := make(map[stockKey]int)
+	for _, r := range rows {
+		onOrder[stockKey{r.ProductID, r.WarehouseID}] = r.Quantity
+	}
+	return onOrder, nil
+}
+
+func createDraft(supplierID, warehouseID uint, lines []Suggestion) (*internal.PurchaseOrder, error) {
+	var total float64
+	for _, l := range lines {
+		total += l.UnitPrice * float64(l.Quantity)
+	}
+	po := internal.PurchaseOrder{
+		SupplierID:  supplierID,
+		WarehouseID: warehouseID,
+		Status:      "draft",
+		TotalCost:   total,
+		OrderDate:   time.Now(),
+	}
+	productIDs := make([]uint, 0, len(lines))
+	for _, l := range lines {
+		productIDs = append(productIDs, l.ProductID)
+	}
+	po.ExpectedAt = suppliers.ExpectedDelivery(supplierID, productIDs, po.OrderDate)
+
+	err := internal.DB.Transaction(func(tx *gorm.DB) error {
+		// Numbering by the reserved ID keeps drafts from concurrent runs apart
+		if err := tx.Raw("SELECT nextval(pg_get_serial_sequence('purchase_orders', 'id'))").
+			Scan(&po.ID).Error; err != nil {
+			return err
+		}
+		po.PONumber = fmt.Sprintf("PO-%d-S%d-W%d", po.ID, supplierID, warehouseID)
+		if err := tx.Create(&po).Error; err != nil {
+			return err
+		}
+		for _, l := range lines {
+			item := internal.POItem{
+				POID:      po.ID,
+				ProductID: l.ProductID,
+				Quantity:  l.Quantity,
+				UnitPrice: l.UnitPrice,
+			}
+			if err := tx.Create(&item).Error; err != nil {
+				return err
+			}
+			po.Items = append(po.Items, item)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &po, nil
+}
+
+// StartScheduler runs replenishment every interval until the process exits.
+func StartScheduler(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			result, err := Run(false)
+			if err != nil {
+				log.Printf("Scheduled replenishment failed: %v", err)
+				continue
+			}
+			log.Printf("Scheduled replenishment drafted %d purchase orders (%d items without a preferred supplier)",
+				len(result.PurchaseOrders), len(result.Unassigned))
+		}
+	}()
+}
@@ -0,0 +1,90 @@
+package replenishment
+
+import (
+	"myapp/internal"
+	"testing"
+)
+
+func TestReorderQuantity(t *testing.T) {
+	tests := []struct {
+		name         string
+		inv          internal.Inventory
+		onOrder      map[stockKey]int
+		wantQty      int
+		wantIncoming int
+		wantPool     int
+	}{
+		{
+			name:    "tops up to max stock",
+			inv:     internal.Inventory{ProductID: 1, WarehouseID: 1, Quantity: 3, MaxStock: 20},
+			wantQty: 17,
+		},
+		{
+			name:         "open orders for the warehouse count",
+			inv:          internal.Inventory{ProductID: 1, WarehouseID: 1, Quantity: 3, MaxStock: 20},
+			onOrder:      map[stockKey]int{{1, 1}: 10, {1, 2}: 50},
+			wantQty:      7,
+			wantIncoming: 10,
+		},
+		{
+			name:         "enough on order",
+			inv:          internal.Inventory{ProductID: 1, WarehouseID: 1, Quantity: 3, MaxStock: 20},
+			onOrder:      map[stockKey]int{{1, 1}: 25},
+			wantQty:      -8,
+			wantIncoming: 25,
+		},
+		{
+			name:         "negative stock is made up",
+			inv:          internal.Inventory{ProductID: 1, WarehouseID: 1, Quantity: -4, MaxStock: 10},
+			wantQty:      14,
+			wantIncoming: 0,
+		},
+		{
+			name:         "orders without a warehouse cover the shortfall",
+			inv:          internal.Inventory{ProductID: 1, WarehouseID: 1, Quantity: 3, MaxStock: 20},
+			onOrder:      map[stockKey]int{{1, 1}: 5, {1, 0}: 4},
+			wantQty:      8,
+			wantIncoming: 9,
+			wantPool:     0,
+		},
+		{
+			name:         "pool only gives what is needed",
+			inv:          internal.Inventory{ProductID: 1, WarehouseID: 1, Quantity: 3, MaxStock: 20},
+			onOrder:      map[stockKey]int{{1, 0}: 30},
+			wantQty:      0,
+			wantIncoming: 17,
+			wantPool:     13,
+		},
+		{
+			name:         "pool is per product",
+			inv:          internal.Inventory{ProductID: 1, WarehouseID: 1, Quantity: 3, MaxStock: 20},
+			onOrder:      map[stockKey]int{{2, 0}: 30},
+			wantQty:      17,
+			wantIncoming: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			onOrder := tt.onOrder
+			if onOrder == nil {
+				onOrder = map[stockKey]int{}
+			}
+			qty, incoming := reorderQuantity(tt.inv, onOrder)
+			if qty != tt.wantQty || incoming != tt.wantIncoming {
+				t.Errorf("reorderQuantity = %d, %d on order; want %d, %d", qty, incoming, tt.wantQty, tt.wantIncoming)
+			}
+			if pool := onOrder[stockKey{tt.inv.ProductID, 0}]; pool != tt.wantPool {
+				t.Errorf("pool left = %d, want %d", pool, tt.wantPool)
+			}
+		})
+	}
+}
+
+func TestReorderQuantitySharesPoolAcrossWarehouses(t *testing.T) {
+	onOrder := map[stockKey]int{{1, 0}: 10}
+	first, _ := reorderQuantity(internal.Inventory{ProductID: 1, WarehouseID: 1, MaxStock: 6}, onOrder)
+	second, incoming := reorderQuantity(internal.Inventory{ProductID: 1, WarehouseID: 2, MaxStock: 6}, onOrder)
+	if first != 0 || second != 2 || incoming != 4 {
+		t.Errorf("first reorders %d, second %d with %d on order; want 0, then 2 with 4", first, second, incoming)
+	}
+}
@@ -0,0 +1,25 @@
+package replenishment
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+func RunReplenishment(w http.ResponseWriter, r *http.Request) {
+	dryRun := r.URL.Query().Get("dry_run") == "true"
+
+	result, err := Run(dryRun)
+	if err != nil {
+		http.Error(w, "Failed to run replenishment", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if !dryRun && len(result.PurchaseOrders) > 0 {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   result,
+	})
+}
@@ -3,17 +3,24 @@ package reports
 import (
 	"encoding/json"
 	"myapp/internal"
+	"myapp/internal/export"
 	"net/http"
+	"time"
 )
 
+type stockSummaryRow struct {
+	WarehouseName string  `json:"warehouse_name"`
+	ProductName   string  `json:"product_name"`
+	SKU           string  `json:"sku"`
+	Quantity      int     `json:"quantity"`
+	Value         float64 `json:"value"`
+	RetailValue   float64 `json:"retail_value"`
+}
+
+// GetStockSummary lists on-hand stock valued at standard cost (Product.Cost),
+// with the selling-price value alongside as retail_value.
 func GetStockSummary(w http.ResponseWriter, r *http.Request) {
-	var results []struct {
-		WarehouseName string  `json:"warehouse_name"`
-		ProductName   string  `json:"product_name"`
-		SKU           string  `json:"sku"`
-		Quantity      int     `json:"quantity"`
-		Value         float64 `json:"value"`
-	}
+	var results []stockSummaryRow
 
 	query := `
 		SELECT 
@@ -21,37 +28,95 @@ func GetStockSummary(w http.ResponseWriter, r *http.Request) {
 			p.name as product_name,
 			p.sku,
 			i.quantity,
-			(i.quantity * p.price) as value
+			(i.quantity * p.cost) as value,
+			(i.quantity * p.price) as retail_value
 		FROM inventories i
 		JOIN products p ON i.product_id = p.id
 		JOIN warehouses w ON i.warehouse_id = w.id
 		ORDER BY w.name, p.name
 	`
 
+	if format := export.Negotiate(r); format != export.JSON {
+		export.Stream(w, format, "stock-summary",
+			[]string{"warehouse_name", "product_name", "sku", "quantity", "value", "retail_value"},
+			internal.DB.Raw(query),
+			func(s *stockSummaryRow) []interface{} {
+				return []interface{}{s.WarehouseName, s.ProductName, s.SKU, s.Quantity, s.Value, s.RetailValue}
+			})
+		return
+	}
+
 	if err := internal.DB.Raw(query).Scan(&results).Error; err != nil {
 		http.Error(w, "Failed to generate stock summary", http.StatusInternalServerError)
 		return
 	}
-	var totalValue float64
+	var totalValue, totalRetailValue float64
 	var totalItems int
 	for _, r := range results {
 		totalValue += r.Value
+		totalRetailValue += r.RetailValue
 		totalItems += r.Quantity
 	}
 
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data": map[string]interface{}{
+			"items":              results,
+			"total_value":        totalValue,
+			"total_retail_value": totalRetailValue,
+			"total_items":        totalItems,
+		},
+	})
+}
+func GetBackorderReport(w http.ResponseWriter, r *http.Request) {
+	var results []struct {
+		ProductID           uint      `json:"product_id"`
+		ProductName         string    `json:"product_name"`
+		SKU                 string    `json:"sku"`
+		BackorderedQuantity int       `json:"backordered_quantity"`
+		OrderCount          int       `json:"order_count"`
+		OldestOrderDate     time.Time `json:"oldest_order_date"`
+	}
+
+	query := internal.DB.Table("order_items oi").
+		Select(`p.id as product_id,
+			p.name as product_name,
+			p.sku,
+			SUM(oi.backordered_quantity) as backordered_quantity,
+			COUNT(DISTINCT o.id) as order_count,
+			MIN(o.order_date) as oldest_order_date`).
+		Joins("JOIN orders o ON oi.order_id = o.id").
+		Joins("JOIN products p ON oi.product_id = p.id").
+		Where("oi.backordered_quantity > 0 AND o.status = ?", "backordered")
+	productID := r.URL.Query().Get("product_id")
+	if productID != "" {
+		query = query.Where("oi.product_id = ?", productID)
+	}
+
+	if err := query.Group("p.id, p.name, p.sku").
+		Order("backordered_quantity DESC").
+		Scan(&results).Error; err != nil {
+		http.Error(w, "Failed to generate backorder report", http.StatusInternalServerError)
+		return
+	}
+	var totalUnits int
+	for _, r := range results {
+		totalUnits += r.BackorderedQuantity
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"status": "success",
 		"data": map[string]interface{}{
 			"items":       results,
-			"total_value": totalValue,
-			"total_items": totalItems,
+			"total_units": totalUnits,
 		},
 	})
 }
 func GetAuditLogs(w http.ResponseWriter, r *http.Request) {
 	var logs []internal.AuditLog
-	query := internal.DB.Order("created_at DESC").Limit(100)
+	query := internal.DB.Model(&internal.AuditLog{}).Order("created_at DESC")
 	entity := r.URL.Query().Get("entity")
 	if entity != "" {
 		query = query.Where("entity = ?", entity)
@@ -61,6 +126,18 @@ func GetAuditLogs(w http.ResponseWriter, r *http.Request) {
 		query = query.Where("action = ?", action)
 	}
 
+	// Exports cover the whole trail; JSON shows the latest entries
+	if format := export.Negotiate(r); format != export.JSON {
+		export.Stream(w, format, "audit-logs",
+			[]string{"id", "created_at", "action", "entity", "entity_id", "user_id", "details", "ip_address"},
+			query,
+			func(l *internal.AuditLog) []interface{} {
+				return []interface{}{l.ID, l.CreatedAt, l.Action, l.Entity, l.EntityID, l.UserID, l.Details, l.IPAddress}
+			})
+		return
+	}
+	query = query.Limit(100)
+
 	if err := query.Find(&logs).Error; err != nil {
 		http.Error(w, "Failed to fetch audit logs", http.StatusInternalServerError)
 		return
@@ -0,0 +1,406 @@
+package reports
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"myapp/internal"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// salesGroup is a GROUP BY expression for the sales report and the label
+// shown for each group.
+type salesGroup struct {
+	key, label string
+	period     bool // groups are time buckets, sorted by key
+}
+
+var salesGroups = map[string]salesGroup{
+	"product":  {key: "p.sku", label: "MAX(p.name)"},
+	"category": {key: "p.category", label: "MAX(p.category)"},
+	// Customers have no table; the email identifies them when present
+	"customer": {key: "LOWER(COALESCE(NULLIF(o.customer_email, ''), o.customer_name))", label: "MAX(o.customer_name)"},
+	"day":      {key: "to_char(o.order_date, 'YYYY-MM-DD')", label: "MAX(to_char(o.order_date, 'YYYY-MM-DD'))", period: true},
+	"week":     {key: "to_char(date_trunc('week', o.order_date), 'YYYY-MM-DD')", label: "MAX(to_char(o.order_date, 'IYYY-\"W\"IW'))", period: true},
+	"month":    {key: "to_char(o.order_date, 'YYYY-MM')", label: "MAX(to_char(o.order_date, 'YYYY-MM'))", period: true},
+}
+
+// salesRow is one group of order lines as read from the database.
+type salesRow struct {
+	Key     string
+	Label   string
+	Units   int
+	Orders  int
+	Revenue float64
+	Cost    float64
+}
+
+// salesFigures are the measures reported for a group or a whole period.
+// Cost is Product.Cost at report time; order lines do not record it.
+type salesFigures struct {
+	Units     int     `json:"units"`
+	Orders    int     `json:"orders"`
+	Revenue   float64 `json:"revenue"`
+	Cost      float64 `json:"cost"`
+	Margin    float64 `json:"margin"`
+	MarginPct float64 `json:"margin_pct"`
+}
+
+type salesLine struct {
+	Key   string `json:"key"`
+	Label string `json:"label"`
+	salesFigures
+	Previous         *salesFigures `json:"previous,omitempty"`
+	RevenueChangePct *float64      `json:"revenue_change_pct,omitempty"`
+	UnitsChangePct   *float64      `json:"units_change_pct,omitempty"`
+	MarginChangePct  *float64      `json:"margin_change_pct,omitempty"`
+}
+
+// salesFilter narrows the order lines included in a sales report.
+type salesFilter struct {
+	from, until time.Time // order_date in [from, until)
+	warehouseID int
+	productID   int
+	category    string
+}
+
+func (f salesFilter) previous() salesFilter {
+	// Whole days, so a DST change inside the period does not shift it
+	days := int(f.until.Sub(f.from).Round(24*time.Hour) / (24 * time.Hour))
+	p := f
+	p.from, p.until = f.from.AddDate(0, 0, -days), f.from
+	return p
+}
+
+func figures(r salesRow) salesFigures {
+	f := salesFigures{
+		Units:   r.Units,
+		Orders:  r.Orders,
+		Revenue: round2(r.Revenue),
+		Cost:    round2(r.Cost),
+		Margin:  round2(r.Revenue - r.Cost),
+	}
+	if r.Revenue != 0 {
+		f.MarginPct = round2((r.Revenue - r.Cost) / r.Revenue * 100)
+	}
+	return f
+}
+
+func round2(v float64) float64 {
+	if v < 0 {
+		return -round2(-v)
+	}
+	return float64(int64(v*100+0.5)) / 100
+}
+
+// changePct is the percentage change from prev to cur, or nil when there
+// is nothing to compare against.
+func changePct(cur, prev float64) *float64 {
+	if prev == 0 {
+		return nil
+	}
+	pct := round2((cur - prev) / prev * 100)
+	return &pct
+}
+
+func compare(line *salesLine, prev salesFigures) {
+	line.Previous = &prev
+	line.RevenueChangePct = changePct(line.Revenue, prev.Revenue)
+	line.UnitsChangePct = changePct(float64(line.Units), float64(prev.Units))
+	line.MarginChangePct = changePct(line.Margin, prev.Margin)
+}
+
+// compareSales pairs each current group with the same group in the
+// previous period. Groups sold only in the previous period are listed with
+// zero current figures so drops to nothing are visible. Time buckets never
+// match across periods, so previous is nil for them.
+func compareSales(current, previous []salesRow) []salesLine {
+	lines := make([]salesLine, 0, len(current))
+	index := make(map[string]int, len(current))
+	for _, r := range current {
+		index[r.Key] = len(lines)
+		lines = append(lines, salesLine{Key: r.Key, Label: r.Label, salesFigures: figures(r)})
+	}
+	if previous == nil {
+		return lines
+	}
+	for _, r := range previous {
+		i, ok := index[r.Key]
+		if !ok {
+			i = len(lines)
+			lines = append(lines, salesLine{Key: r.Key, Label: r.Label})
+		}
+		compare(&lines[i], figures(r))
+	}
+	for i := range lines {
+		if lines[i].Previous == nil {
+			compare(&lines[i], salesFigures{})
+		}
+	}
+	return lines
+}
+
+func totalSales(rows []salesRow) salesRow {
+	var t salesRow
+	for _, r := range rows {
+		t.Units += r.Units
+		t.Revenue += r.Revenue
+		t.Cost += r.Cost
+	}
+	return t
+}
+
+// querySales sums order lines per group, excluding cancelled orders.
+func querySales(g salesGroup, f salesFilter) ([]salesRow, error) {
+	var rows []salesRow
+	err := salesQuery(f).
+		Select(fmt.Sprintf(`%s AS key, %s AS label,
+			SUM(oi.quantity) AS units,
+			COUNT(DISTINCT o.id) AS orders,
+			SUM(oi.quantity * oi.unit_price) AS revenue,
+			SUM(oi.quantity * p.cost) AS cost`, g.key, g.label)).
+		Group(g.key).
+		Scan(&rows).Error
+	return rows, err
+}
+
+// countOrders counts distinct orders, which cannot be summed across groups.
+func countOrders(f salesFilter) (int, error) {
+	var n int64
+	err := salesQuery(f).Distinct("o.id").Count(&n).Error
+	return int(n), err
+}
+
+func salesQuery(f salesFilter) *gorm.DB {
+	query := internal.DB.Table("order_items oi").
+		Joins("JOIN orders o ON oi.order_id = o.id").
+		Joins("JOIN products p ON oi.product_id = p.id").
+		Where("o.status <> ? AND o.order_date >= ? AND o.order_date < ?", "cancelled", f.from, f.until)
+	if f.warehouseID != 0 {
+		query = query.Where("oi.warehouse_id = ?", f.warehouseID)
+	}
+	if f.productID != 0 {
+		query = query.Where("oi.product_id = ?", f.productID)
+	}
+	if f.category != "" {
+		query = query.Where("p.category = ?", f.category)
+	}
+	return query
+}
+
+// parseSalesFilter reads ?from=&to= (inclusive dates, default the last 30
+// days), warehouse_id, product_id and category.
+func parseSalesFilter(q url.Values, now time.Time) (salesFilter, error) {
+	var f salesFilter
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	to := today
+	if v := q.Get("to"); v != "" {
+		d, err := time.ParseInLocation("2006-01-02", v, time.Local)
+		if err != nil {
+			return f, errors.New("Invalid to date")
+		}
+		to = d
+	}
+	from := to.AddDate(0, 0, -29)
+	if v := q.Get("from"); v != "" {
+		d, err := time.ParseInLocation("2006-01-02", v, time.Local)
+		if err != nil {
+			return f, errors.New("Invalid from date")
+		}
+		from = d
+	}
+	if from.After(to) {
+		return f, errors.New("from must not be after to")
+	}
+	f.from, f.until = from, to.AddDate(0, 0, 1)
+	f.warehouseID, _ = strconv.Atoi(q.Get("warehouse_id"))
+	f.productID, _ = strconv.Atoi(q.Get("product_id"))
+	f.category = q.Get("category")
+	return f, nil
+}
+
+// GetSalesReport reports revenue, units and gross margin grouped by
+// product, category, customer, day, week or month, compared with the
+// previous period of the same length:
+// GET /reports/sales?group_by=category&from=2026-03-01&to=2026-03-31.
+func GetSalesReport(w http.ResponseWriter, r *http.Request) {
+	groupBy := r.URL.Query().Get("group_by")
+	if groupBy == "" {
+		groupBy = "product"
+	}
+	group, ok := salesGroups[groupBy]
+	if !ok {
+		http.Error(w, "group_by must be product, category, customer, day, week or month", http.StatusBadRequest)
+		return
+	}
+	filter, err := parseSalesFilter(r.URL.Query(), time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	prevFilter := filter.previous()
+
+	current, err := querySales(group, filter)
+	if err != nil {
+		http.Error(w, "Failed to generate sales report", http.StatusInternalServerError)
+		return
+	}
+	previous, err := querySales(group, prevFilter)
+	if err != nil {
+		http.Error(w, "Failed to generate sales report", http.StatusInternalServerError)
+		return
+	}
+	orders, err := countOrders(filter)
+	if err != nil {
+		http.Error(w, "Failed to generate sales report", http.StatusInternalServerError)
+		return
+	}
+	prevOrders, err := countOrders(prevFilter)
+	if err != nil {
+		http.Error(w, "Failed to generate sales report", http.StatusInternalServerError)
+		return
+	}
+
+	var lines []salesLine
+	if group.period {
+		lines = compareSales(current, nil)
+		sort.Slice(lines, func(i, j int) bool { return lines[i].Key < lines[j].Key })
+	} else {
+		lines = compareSales(current, previous)
+		sort.SliceStable(lines, func(i, j int) bool {
+			if lines[i].Revenue != lines[j].Revenue {
+				return lines[i].Revenue > lines[j].Revenue
+			}
+			return lines[i].Key < lines[j].Key
+		})
+	}
+
+	cur, prev := totalSales(current), totalSales(previous)
+	cur.Orders, prev.Orders = orders, prevOrders
+	totals := salesLine{Key: "total", Label: "Total", salesFigures: figures(cur)}
+	compare(&totals, figures(prev))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data": map[string]interface{}{
+			"group_by": groupBy,
+			"from":     filter.from.Format("2006-01-02"),
+			"to":       filter.until.AddDate(0, 0, -1).Format("2006-01-02"),
+			"previous_period": map[string]string{
+				"from": prevFilter.from.Format("2006-01-02"),
+				"to":   prevFilter.until.AddDate(0, 0, -1).Format("2006-01-02"),
+			},
+			"items":  lines,
+			"totals": totals,
+		},
+	})
+}
+
+// rankProducts returns the best and worst limit lines by metric. Bottom
+// sellers are listed worst first.
+func rankProducts(lines []salesLine, metric string, limit int) (top, bottom []salesLine) {
+	value := func(l salesLine) float64 {
+		switch metric {
+		case "units":
+			return float64(l.Units)
+		case "margin":
+			return l.Margin
+		}
+		return l.Revenue
+	}
+	sorted := append([]salesLine(nil), lines...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if value(sorted[i]) != value(sorted[j]) {
+			return value(sorted[i]) > value(sorted[j])
+		}
+		return sorted[i].Key < sorted[j].Key
+	})
+	limit = min(limit, len(sorted))
+	top = sorted[:limit]
+	bottom = make([]salesLine, 0, limit)
+	for i := len(sorted) - 1; i >= len(sorted)-limit; i-- {
+		bottom = append(bottom, sorted[i])
+	}
+	return top, bottom
+}
+
+// GetTopProducts lists the best and worst selling products by revenue,
+// units or margin. Products without sales in the period count as bottom
+// sellers: GET /reports/sales/top-products?metric=units&limit=10.
+func GetTopProducts(w http.ResponseWriter, r *http.Request) {
+	metric := r.URL.Query().Get("metric")
+	if metric == "" {
+		metric = "revenue"
+	}
+	if metric != "revenue" && metric != "units" && metric != "margin" {
+		http.Error(w, "metric must be revenue, units or margin", http.StatusBadRequest)
+		return
+	}
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = 10
+	}
+	filter, err := parseSalesFilter(r.URL.Query(), time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	current, err := querySales(salesGroups["product"], filter)
+	if err != nil {
+		http.Error(w, "Failed to generate sales report", http.StatusInternalServerError)
+		return
+	}
+	previous, err := querySales(salesGroups["product"], filter.previous())
+	if err != nil {
+		http.Error(w, "Failed to generate sales report", http.StatusInternalServerError)
+		return
+	}
+	lines := compareSales(current, previous)
+
+	// Add unsold products so the bottom of the list is not just slow sellers
+	var products []internal.Product
+	query := internal.DB.Select("sku, name")
+	if filter.productID != 0 {
+		query = query.Where("id = ?", filter.productID)
+	}
+	if filter.category != "" {
+		query = query.Where("category = ?", filter.category)
+	}
+	if err := query.Find(&products).Error; err != nil {
+		http.Error(w, "Failed to fetch products", http.StatusInternalServerError)
+		return
+	}
+	listed := make(map[string]bool, len(lines))
+	for _, l := range lines {
+		listed[l.Key] = true
+	}
+	for _, p := range products {
+		if !listed[p.SKU] {
+			line := salesLine{Key: p.SKU, Label: p.Name}
+			compare(&line, salesFigures{})
+			lines = append(lines, line)
+		}
+	}
+
+	top, bottom := rankProducts(lines, metric, limit)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data": map[string]interface{}{
+			"metric": metric,
+			"from":   filter.from.Format("2006-01-02"),
+			"to":     filter.until.AddDate(0, 0, -1).Format("2006-01-02"),
+			"top":    top,
+			"bottom": bottom,
+		},
+	})
+}
@@ -0,0 +1,82 @@
+package reports
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestCompareSales(t *testing.T) {
+	current := []salesRow{
+		{Key: "A", Units: 10, Orders: 2, Revenue: 200, Cost: 150},
+		{Key: "B", Units: 1, Orders: 1, Revenue: 50, Cost: 0},
+	}
+	previous := []salesRow{
+		{Key: "A", Units: 5, Orders: 1, Revenue: 100, Cost: 75},
+		{Key: "C", Units: 3, Orders: 3, Revenue: 30, Cost: 20},
+	}
+	lines := compareSales(current, previous)
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines, want 3", len(lines))
+	}
+
+	a := lines[0]
+	if a.Margin != 50 || a.MarginPct != 25 {
+		t.Errorf("A margin = %v (%v%%), want 50 (25%%)", a.Margin, a.MarginPct)
+	}
+	if a.RevenueChangePct == nil || *a.RevenueChangePct != 100 {
+		t.Errorf("A revenue change = %v, want 100", a.RevenueChangePct)
+	}
+	if b := lines[1]; b.Previous == nil || b.RevenueChangePct != nil {
+		t.Errorf("B without previous sales = %+v", b)
+	}
+	if c := lines[2]; c.Key != "C" || c.Revenue != 0 || *c.RevenueChangePct != -100 {
+		t.Errorf("C sold only previously = %+v", c)
+	}
+
+	if periods := compareSales(current, nil); periods[0].Previous != nil {
+		t.Error("time buckets should not be compared")
+	}
+}
+
+func TestRankProducts(t *testing.T) {
+	lines := []salesLine{
+		{Key: "A", salesFigures: salesFigures{Units: 1, Revenue: 300}},
+		{Key: "B", salesFigures: salesFigures{Units: 9, Revenue: 90}},
+		{Key: "C"},
+	}
+	top, bottom := rankProducts(lines, "units", 2)
+	if top[0].Key != "B" || top[1].Key != "A" {
+		t.Errorf("top = %v, %v", top[0].Key, top[1].Key)
+	}
+	if bottom[0].Key != "C" || bottom[1].Key != "A" {
+		t.Errorf("bottom = %v, %v", bottom[0].Key, bottom[1].Key)
+	}
+	if top, _ := rankProducts(lines, "revenue", 10); len(top) != 3 || top[0].Key != "A" {
+		t.Errorf("top by revenue = %+v", top)
+	}
+}
+
+func TestParseSalesFilter(t *testing.T) {
+	now := time.Date(2026, 3, 31, 15, 0, 0, 0, time.Local)
+	f, err := parseSalesFilter(url.Values{}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2026, 3, 2, 0, 0, 0, 0, time.Local); !f.from.Equal(want) {
+		t.Errorf("default from = %v, want %v", f.from, want)
+	}
+	if want := time.Date(2026, 4, 1, 0, 0, 0, 0, time.Local); !f.until.Equal(want) {
+		t.Errorf("default until = %v, want %v", f.until, want)
+	}
+
+	f, _ = parseSalesFilter(url.Values{"from": {"2026-03-01"}, "to": {"2026-03-31"}}, now)
+	prev := f.previous()
+	if want := time.Date(2026, 1, 29, 0, 0, 0, 0, time.Local); !prev.from.Equal(want) || !prev.until.Equal(f.from) {
+		t.Errorf("previous period = %v to %v", prev.from, prev.until)
+	}
+
+	if _, err := parseSalesFilter(url.Values{"from": {"2026-04-02"}, "to": {"2026-04-01"}}, now); err == nil {
+		t.Error("from after to accepted")
+	}
+}
@@ -0,0 +1,354 @@
+package reports
+
+import (
+	"encoding/json"
+	"errors"
+	"myapp/internal"
+	"myapp/internal/valuation"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// agingBuckets are the upper bounds, in days, of the stock age buckets.
+// Units older than the last bound fall in the final bucket.
+var agingBuckets = []struct {
+	label   string
+	maxDays int
+}{
+	{"0-30", 30},
+	{"31-60", 60},
+	{"61-90", 90},
+	{"91-180", 180},
+	{"181+", -1},
+}
+
+type agingBucket struct {
+	Label string `json:"label"`
+	Units int    `json:"units"`
+}
+
+// receipt is an inbound stock movement: a PO receipt or positive adjustment.
+type receipt struct {
+	Quantity  int
+	CreatedAt time.Time
+}
+
+// ageStock spreads the units on hand over the aging buckets, assuming the
+// oldest units were issued first so what is left came from the most recent
+// receipts. receipts must be newest first. Units not covered by any receipt
+// predate the movement history and are counted as oldest.
+func ageStock(onHand int, receipts []receipt, now time.Time) []agingBucket {
+	buckets := make([]agingBucket, len(agingBuckets))
+	for i, b := range agingBuckets {
+		buckets[i].Label = b.label
+	}
+	last := len(buckets) - 1
+	for _, rc := range receipts {
+		if onHand <= 0 {
+			break
+		}
+		units := min(onHand, rc.Quantity)
+		onHand -= units
+		age := int(now.Sub(rc.CreatedAt).Hours() / 24)
+		i := 0
+		for i < last && age > agingBuckets[i].maxDays {
+			i++
+		}
+		buckets[i].Units += units
+	}
+	if onHand > 0 {
+		buckets[last].Units += onHand
+	}
+	return buckets
+}
+
+// turnoverLine is the stock movement profile of one inventory row over the
+// report window.
+type turnoverLine struct {
+	ProductID         uint          `json:"product_id"`
+	ProductName       string        `json:"product_name"`
+	SKU               string        `json:"sku"`
+	Category          string        `json:"category"`
+	WarehouseID       uint          `json:"warehouse_id"`
+	WarehouseName     string        `json:"warehouse_name"`
+	OnHand            int           `json:"on_hand"`
+	UnitsOut          int           `json:"units_out"`
+	AvgDailyOut       float64       `json:"avg_daily_out"`
+	DaysOfSupply      *float64      `json:"days_of_supply"`
+	Turnover          *float64      `json:"turnover"`
+	AnnualTurnover    *float64      `json:"annual_turnover"`
+	LastSale          *time.Time    `json:"last_sale"`
+	DaysSinceLastSale *int          `json:"days_since_last_sale"`
+	UnitCost          float64       `json:"unit_cost"`
+	CostValue         float64       `json:"cost_value"`
+	Aging             []agingBucket `json:"aging"`
+}
+
+// computeTurnover derives the ratios of a line from OnHand, UnitsOut and
+// the net stock change over a window of days.
+func (l *turnoverLine) computeTurnover(days, netChange int) {
+	l.AvgDailyOut = round2(float64(l.UnitsOut) / float64(days))
+	if l.UnitsOut > 0 {
+		dos := round2(float64(l.OnHand) / (float64(l.UnitsOut) / float64(days)))
+		l.DaysOfSupply = &dos
+	}
+	// Average of opening and closing stock; the window's units out over it
+	opening := l.OnHand - netChange
+	if avg := float64(opening+l.OnHand) / 2; avg > 0 {
+		turnover := round2(float64(l.UnitsOut) / avg)
+		annual := round2(float64(l.UnitsOut) / avg * 365 / float64(days))
+		l.Turnover, l.AnnualTurnover = &turnover, &annual
+	}
+}
+
+// turnoverQuery selects the inventory rows to analyse.
+type turnoverQuery struct {
+	days        int
+	warehouseID int
+	category    string
+	method      valuation.Method
+}
+
+func parseTurnoverQuery(q url.Values, defaultDays int) (turnoverQuery, error) {
+	tq := turnoverQuery{days: defaultDays}
+	if v := q.Get("days"); v != "" {
+		days, err := strconv.Atoi(v)
+		if err != nil || days <= 0 {
+			return tq, errors.New("days must be a positive number")
+		}
+		tq.days = days
+	}
+	tq.warehouseID, _ = strconv.Atoi(q.Get("warehouse_id"))
+	tq.category = q.Get("category")
+	method, err := valuation.ParseMethod(q.Get("method"))
+	if err != nil {
+		return tq, errors.New("Method must be fifo, average or standard")
+	}
+	tq.method = method
+	return tq, nil
+}
+
+type stockKey struct{ product, warehouse uint }
+
+// loadTurnover builds a turnover line for every inventory row, valued at
+// cost with tq.method from the last stock snapshot before the window.
+func loadTurnover(tq turnoverQuery, now time.Time) ([]turnoverLine, error) {
+	since := now.AddDate(0, 0, -tq.days)
+
+	// filter narrows a query on a table with product_id and warehouse_id
+	filter := func(query *gorm.DB, table string) *gorm.DB {
+		if tq.warehouseID != 0 {
+			query = query.Where(table+".warehouse_id = ?", tq.warehouseID)
+		}
+		if tq.category != "" {
+			query = query.Where(table+".product_id IN (?)",
+				internal.DB.Model(&internal.Product{}).Select("id").Where("category = ?", tq.category))
+		}
+		return query
+	}
+
+	var inventories []internal.Inventory
+	query := filter(internal.DB.Preload("Product").Preload("Warehouse"), "inventories")
+	if err := query.Find(&inventories).Error; err != nil {
+		return nil, err
+	}
+
+	var stats []struct {
+		ProductID   uint
+		WarehouseID uint
+		UnitsOut    int
+		NetChange   int
+		LastSale    *time.Time
+	}
+	statsQuery := internal.DB.Table("stock_movements").
+		Select(`product_id, warehouse_id,
+			SUM(CASE WHEN type = 'OUT' AND created_at > ? THEN -quantity ELSE 0 END) AS units_out,
+			SUM(CASE WHEN created_at > ? THEN quantity ELSE 0 END) AS net_change,
+			MAX(CASE WHEN type = 'OUT' THEN created_at END) AS last_sale`, since, since)
+	if err := filter(statsQuery, "stock_movements").Group("product_id, warehouse_id").Scan(&stats).Error; err != nil {
+		return nil, err
+	}
+
+	// Only the most recent receipts that add up to the quantity on hand
+	// are needed to age it; newer counts the units received after each one.
+	var inbound []struct {
+		ProductID   uint
+		WarehouseID uint
+		Quantity    int
+		CreatedAt   time.Time
+	}
+	receiptsQuery := filter(internal.DB.Table("stock_movements sm").
+		Select(`sm.product_id, sm.warehouse_id, sm.quantity, sm.created_at, sm.id, i.quantity AS on_hand,
+			SUM(sm.quantity) OVER (PARTITION BY sm.product_id, sm.warehouse_id
+				ORDER BY sm.created_at DESC, sm.id DESC) - sm.quantity AS newer`).
+		Joins("JOIN inventories i ON i.product_id = sm.product_id AND i.warehouse_id = sm.warehouse_id").
+		Where("sm.quantity > 0 AND i.quantity > 0"), "sm")
+	if err := internal.DB.Table("(?) AS r", receiptsQuery).
+		Select("product_id, warehouse_id, quantity, created_at").
+		Where("newer < on_hand").
+		Order("created_at DESC, id DESC").
+		Scan(&inbound).Error; err != nil {
+		return nil, err
+	}
+
+	movements, err := valuation.LoadFrom(since, now, uint(tq.warehouseID), tq.category)
+	if err != nil {
+		return nil, err
+	}
+	costs, err := valuation.StandardCosts()
+	if err != nil {
+		return nil, err
+	}
+	unitCosts := make(map[stockKey]float64)
+	for _, p := range valuation.Value(tq.method, movements, costs) {
+		if p.Quantity > 0 {
+			unitCosts[stockKey{p.ProductID, p.WarehouseID}] = p.UnitCost
+		}
+	}
+
+	type stat struct {
+		unitsOut, netChange int
+		lastSale            *time.Time
+	}
+	statByKey := make(map[stockKey]stat, len(stats))
+	for _, s := range stats {
+		statByKey[stockKey{s.ProductID, s.WarehouseID}] = stat{s.UnitsOut, s.NetChange, s.LastSale}
+	}
+	receipts := make(map[stockKey][]receipt)
+	for _, in := range inbound {
+		k := stockKey{in.ProductID, in.WarehouseID}
+		receipts[k] = append(receipts[k], receipt{in.Quantity, in.CreatedAt})
+	}
+
+	lines := make([]turnoverLine, 0, len(inventories))
+	for _, inv := range inventories {
+		k := stockKey{inv.ProductID, inv.WarehouseID}
+		s := statByKey[k]
+		unitCost, ok := unitCosts[k]
+		if !ok {
+			unitCost = inv.Product.Cost
+		}
+		l := turnoverLine{
+			ProductID:     inv.ProductID,
+			ProductName:   inv.Product.Name,
+			SKU:           inv.Product.SKU,
+			Category:      inv.Product.Category,
+			WarehouseID:   inv.WarehouseID,
+			WarehouseName: inv.Warehouse.Name,
+			OnHand:        inv.Quantity,
+			UnitsOut:      s.unitsOut,
+			LastSale:      s.lastSale,
+			UnitCost:      unitCost,
+			CostValue:     round2(float64(inv.Quantity) * unitCost),
+			Aging:         ageStock(inv.Quantity, receipts[k], now),
+		}
+		l.computeTurnover(tq.days, s.netChange)
+		if s.lastSale != nil {
+			days := int(now.Sub(*s.lastSale).Hours() / 24)
+			l.DaysSinceLastSale = &days
+		}
+		lines = append(lines, l)
+	}
+	return lines, nil
+}
+
+// GetTurnoverReport shows how fast each product moves in each warehouse
+// over the last ?days= (default 90): turnover, days of supply, last sale
+// and the age of the stock on hand. Slowest movers are listed first.
+func GetTurnoverReport(w http.ResponseWriter, r *http.Request) {
+	tq, err := parseTurnoverQuery(r.URL.Query(), 90)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	lines, err := loadTurnover(tq, time.Now())
+	if err != nil {
+		http.Error(w, "Failed to generate turnover report", http.StatusInternalServerError)
+		return
+	}
+
+	annual := func(l turnoverLine) float64 {
+		if l.AnnualTurnover == nil {
+			return 0
+		}
+		return *l.AnnualTurnover
+	}
+	sort.SliceStable(lines, func(i, j int) bool {
+		if annual(lines[i]) != annual(lines[j]) {
+			return annual(lines[i]) < annual(lines[j])
+		}
+		return lines[i].CostValue > lines[j].CostValue
+	})
+
+	totals := make([]agingBucket, len(agingBuckets))
+	var totalValue float64
+	for _, l := range lines {
+		for i, b := range l.Aging {
+			totals[i].Label = b.Label
+			totals[i].Units += b.Units
+		}
+		totalValue += l.CostValue
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data": map[string]interface{}{
+			"days":        tq.days,
+			"method":      tq.method,
+			"items":       lines,
+			"aging":       totals,
+			"total_value": round2(totalValue),
+		},
+	})
+}
+
+// GetDeadStockReport lists stock on hand with no sales (OUT movements) in
+// the last ?days= (default 180) and its value at cost.
+func GetDeadStockReport(w http.ResponseWriter, r *http.Request) {
+	tq, err := parseTurnoverQuery(r.URL.Query(), 180)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	now := time.Now()
+	lines, err := loadTurnover(tq, now)
+	if err != nil {
+		http.Error(w, "Failed to generate dead stock report", http.StatusInternalServerError)
+		return
+	}
+
+	cutoff := now.AddDate(0, 0, -tq.days)
+	dead := make([]turnoverLine, 0)
+	byWarehouse := make(map[string]float64)
+	var totalValue float64
+	var totalUnits int
+	for _, l := range lines {
+		if l.OnHand <= 0 || (l.LastSale != nil && l.LastSale.After(cutoff)) {
+			continue
+		}
+		dead = append(dead, l)
+		byWarehouse[l.WarehouseName] += l.CostValue
+		totalValue += l.CostValue
+		totalUnits += l.OnHand
+	}
+	sort.SliceStable(dead, func(i, j int) bool { return dead[i].CostValue > dead[j].CostValue })
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data": map[string]interface{}{
+			"days":         tq.days,
+			"method":       tq.method,
+			"items":        dead,
+			"by_warehouse": byWarehouse,
+			"total_units":  totalUnits,
+			"total_value":  round2(totalValue),
+		},
+	})
+}
@@ -0,0 +1,46 @@
+package reports
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAgeStock(t *testing.T) {
+	now := time.Date(2026, 6, 30, 12, 0, 0, 0, time.UTC)
+	receipts := []receipt{
+		{Quantity: 5, CreatedAt: now.AddDate(0, 0, -10)},
+		{Quantity: 20, CreatedAt: now.AddDate(0, 0, -45)},
+		{Quantity: 50, CreatedAt: now.AddDate(0, 0, -200)},
+	}
+	got := ageStock(15, receipts, now)
+	want := []int{5, 10, 0, 0, 0}
+	for i, b := range got {
+		if b.Units != want[i] {
+			t.Errorf("bucket %s = %d, want %d", b.Label, b.Units, want[i])
+		}
+	}
+
+	// Stock beyond the recorded receipts predates the history
+	if got := ageStock(80, receipts, now); got[4].Units != 55 {
+		t.Errorf("181+ bucket = %d, want 55", got[4].Units)
+	}
+}
+
+func TestComputeTurnover(t *testing.T) {
+	// 90 units out over 30 days, 60 on hand after a net change of -40
+	l := turnoverLine{OnHand: 60, UnitsOut: 90}
+	l.computeTurnover(30, -40)
+	if l.AvgDailyOut != 3 || *l.DaysOfSupply != 20 {
+		t.Errorf("avg daily out = %v, days of supply = %v", l.AvgDailyOut, *l.DaysOfSupply)
+	}
+	// average stock (100 + 60) / 2 = 80
+	if *l.Turnover != 1.13 || *l.AnnualTurnover != 13.69 {
+		t.Errorf("turnover = %v, annual = %v", *l.Turnover, *l.AnnualTurnover)
+	}
+
+	idle := turnoverLine{OnHand: 10}
+	idle.computeTurnover(30, 0)
+	if idle.DaysOfSupply != nil || *idle.Turnover != 0 {
+		t.Errorf("idle line = %+v", idle)
+	}
+}
@@ -0,0 +1,105 @@
+package reports
+
+import (
+	"encoding/json"
+	"myapp/internal"
+	"myapp/internal/valuation"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// GetValuationReport values stock at cost rather than selling price, as of
+// now or the end of ?as_of=YYYY-MM-DD, by replaying stock movements.
+func GetValuationReport(w http.ResponseWriter, r *http.Request) {
+	method, err := valuation.ParseMethod(r.URL.Query().Get("method"))
+	if err != nil {
+		http.Error(w, "Method must be fifo, average or standard", http.StatusBadRequest)
+		return
+	}
+	asOf := time.Now()
+	if v := r.URL.Query().Get("as_of"); v != "" {
+		asOf, err = internal.ParseAsOf(v)
+		if err != nil {
+			http.Error(w, "Invalid as_of date", http.StatusBadRequest)
+			return
+		}
+	}
+	warehouseID, _ := strconv.Atoi(r.URL.Query().Get("warehouse_id"))
+	category := r.URL.Query().Get("category")
+
+	movements, err := valuation.Load(asOf, uint(warehouseID), category)
+	if err != nil {
+		http.Error(w, "Failed to load stock movements", http.StatusInternalServerError)
+		return
+	}
+	costs, err := valuation.StandardCosts()
+	if err != nil {
+		http.Error(w, "Failed to load product costs", http.StatusInternalServerError)
+		return
+	}
+	positions := valuation.Value(method, movements, costs)
+
+	productIDs := make([]uint, 0, len(positions))
+	warehouseIDs := make([]uint, 0, len(positions))
+	for _, pos := range positions {
+		productIDs = append(productIDs, pos.ProductID)
+		warehouseIDs = append(warehouseIDs, pos.WarehouseID)
+	}
+	productByID, err := internal.ProductsByID(productIDs)
+	if err != nil {
+		http.Error(w, "Failed to fetch products", http.StatusInternalServerError)
+		return
+	}
+	warehouseNames, err := internal.WarehouseNames(warehouseIDs)
+	if err != nil {
+		http.Error(w, "Failed to fetch warehouses", http.StatusInternalServerError)
+		return
+	}
+
+	type item struct {
+		valuation.Position
+		ProductName   string  `json:"product_name"`
+		SKU           string  `json:"sku"`
+		Category      string  `json:"category"`
+		WarehouseName string  `json:"warehouse_name"`
+		RetailValue   float64 `json:"retail_value"`
+	}
+	items := make([]item, 0, len(positions))
+	byWarehouse := make(map[string]float64)
+	byCategory := make(map[string]float64)
+	var totalValue float64
+	var totalItems int
+	for _, pos := range positions {
+		if pos.Quantity == 0 {
+			continue
+		}
+		p := productByID[pos.ProductID]
+		items = append(items, item{
+			Position:      pos,
+			ProductName:   p.Name,
+			SKU:           p.SKU,
+			Category:      p.Category,
+			WarehouseName: warehouseNames[pos.WarehouseID],
+			RetailValue:   float64(pos.Quantity) * p.Price,
+		})
+		byWarehouse[warehouseNames[pos.WarehouseID]] += pos.Value
+		byCategory[p.Category] += pos.Value
+		totalValue += pos.Value
+		totalItems += pos.Quantity
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data": map[string]interface{}{
+			"method":       method,
+			"as_of":        asOf.Format(time.RFC3339),
+			"items":        items,
+			"by_warehouse": byWarehouse,
+			"by_category":  byCategory,
+			"total_value":  totalValue,
+			"total_items":  totalItems,
+		},
+	})
+}
@@ -0,0 +1,375 @@
+package suppliers
+
+import (
+	"encoding/json"
+	"errors"
+	"myapp/internal"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+// FindCatalogueEntry returns the catalogue entry for a product sold by a
+// supplier, with its price breaks.
+func FindCatalogueEntry(supplierID, productID uint) (*internal.SupplierProduct, error) {
+	var entry internal.SupplierProduct
+	if err := internal.DB.Preload("PriceBreaks").
+		Where("supplier_id = ? AND product_id = ?", supplierID, productID).
+		First(&entry).Error; err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// PriceForQuantity returns the unit price for ordering qty units, using the
+// largest price break the quantity qualifies for.
+func PriceForQuantity(entry *internal.SupplierProduct, qty int) float64 {
+	price := entry.UnitPrice
+	best := 0
+	for _, pb := range entry.PriceBreaks {
+		if qty >= pb.MinQuantity && pb.MinQuantity > best {
+			best = pb.MinQuantity
+			price = pb.UnitPrice
+		}
+	}
+	return price
+}
+
+// validateCatalogueEntry checks the prices, price breaks and lead time of a
+// catalogue entry and returns a message for the client when one is invalid,
+// or "" when the entry is valid.
+func validateCatalogueEntry(entry *internal.SupplierProduct) string {
+	if entry.UnitPrice < 0 {
+		return "Unit price cannot be negative"
+	}
+	if entry.LeadTimeDays < 0 {
+		return "Lead time cannot be negative"
+	}
+	seen := make(map[int]bool, len(entry.PriceBreaks))
+	for _, pb := range entry.PriceBreaks {
+		if pb.MinQuantity <= 0 {
+			return "Price break min_quantity must be positive"
+		}
+		if pb.UnitPrice < 0 {
+			return "Price break unit price cannot be negative"
+		}
+		if seen[pb.MinQuantity] {
+			return "Price breaks must have distinct min_quantity"
+		}
+		seen[pb.MinQuantity] = true
+	}
+	return ""
+}
+
+// isUniqueViolation reports whether err is a Postgres unique constraint
+// violation.
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
+// ExpectedDelivery estimates when a supplier will deliver the given products
+// if ordered at orderDate, using the longest catalogue lead time. It returns
+// nil when none of the products has a lead time on record.
+func ExpectedDelivery(supplierID uint, productIDs []uint, orderDate time.Time) *time.Time {
+	var leadDays int
+	if err := internal.DB.Model(&internal.SupplierProduct{}).
+		Select("COALESCE(MAX(lead_time_days), 0)").
+		Where("supplier_id = ? AND product_id IN ?", supplierID, productIDs).
+		Scan(&leadDays).Error; err != nil || leadDays == 0 {
+		return nil
+	}
+	expected := orderDate.AddDate(0, 0, leadDays)
+	return &expected
+}
+
+func ListSupplierProducts(w http.ResponseWriter, r *http.Request) {
+	id := extractID(r.URL.Path, "/suppliers/")
+	if id == 0 {
+		http.Error(w, "Invalid supplier ID", http.StatusBadRequest)
+		return
+	}
+
+	var supplier internal.Supplier
+	if err := internal.DB.First(&supplier, id).Error; err != nil {
+		http.Error(w, "Supplier not found", http.StatusNotFound)
+		return
+	}
+
+	var entries []internal.SupplierProduct
+	if err := internal.DB.Preload("Product").Preload("PriceBreaks", orderByMinQuantity).
+		Where("supplier_id = ?", id).Find(&entries).Error; err != nil {
+		http.Error(w, "Failed to fetch supplier products", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   entries,
+	})
+}
+func AddSupplierProduct(w http.ResponseWriter, r *http.Request) {
+	id := extractID(r.URL.Path, "/suppliers/")
+	if id == 0 {
+		http.Error(w, "Invalid supplier ID", http.StatusBadRequest)
+		return
+	}
+
+	var supplier internal.Supplier
+	if err := internal.DB.First(&supplier, id).Error; err != nil {
+		http.Error(w, "Supplier not found", http.StatusNotFound)
+		return
+	}
+
+	var entry internal.SupplierProduct
+	if err := json.NewDecoder(r.Body).Decode(&entry); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	entry.ID = 0
+	entry.SupplierID = supplier.ID
+	if entry.MinOrderQuantity <= 0 {
+		entry.MinOrderQuantity = 1
+	}
+	if entry.Currency == "" {
+		entry.Currency = "USD"
+	}
+	if msg := validateCatalogueEntry(&entry); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	var product internal.Product
+	if err := internal.DB.First(&product, entry.ProductID).Error; err != nil {
+		http.Error(w, "Product not found", http.StatusNotFound)
+		return
+	}
+
+	err := internal.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Supplier", "Product").Create(&entry).Error; err != nil {
+			return err
+		}
+		if entry.Preferred {
+			return setPreferred(tx, &entry)
+		}
+		return nil
+	})
+	if isUniqueViolation(err) {
+		http.Error(w, "Product is already in the supplier catalogue", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to add supplier product", http.StatusInternalServerError)
+		return
+	}
+
+	internal.LogAudit("CREATE", "SupplierProduct", entry.ID, "system", "Added product to supplier catalogue")
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   entry,
+	})
+}
+func UpdateSupplierProduct(w http.ResponseWriter, r *http.Request) {
+	supplierID, productID := extractCatalogueIDs(r.URL.Path)
+	if supplierID == 0 || productID == 0 {
+		http.Error(w, "Invalid supplier or product ID", http.StatusBadRequest)
+		return
+	}
+
+	entry, err := FindCatalogueEntry(uint(supplierID), uint(productID))
+	if err != nil {
+		http.Error(w, "Supplier product not found", http.StatusNotFound)
+		return
+	}
+
+	var req struct {
+		SupplierSKU      *string                       `json:"supplier_sku"`
+		UnitPrice        *float64                      `json:"unit_price"`
+		Currency         *string                       `json:"currency"`
+		LeadTimeDays     *int                          `json:"lead_time_days"`
+		MinOrderQuantity *int                          `json:"min_order_quantity"`
+		Preferred        *bool                         `json:"preferred"`
+		PriceBreaks      []internal.SupplierPriceBreak `json:"price_breaks"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	if req.SupplierSKU != nil {
+		entry.SupplierSKU = *req.SupplierSKU
+	}
+	if req.UnitPrice != nil {
+		entry.UnitPrice = *req.UnitPrice
+	}
+	if req.Currency != nil {
+		entry.Currency = *req.Currency
+	}
+	if req.LeadTimeDays != nil {
+		entry.LeadTimeDays = *req.LeadTimeDays
+	}
+	if req.MinOrderQuantity != nil && *req.MinOrderQuantity > 0 {
+		entry.MinOrderQuantity = *req.MinOrderQuantity
+	}
+	wasPreferred := entry.Preferred
+	if req.Preferred != nil {
+		entry.Preferred = *req.Preferred
+	}
+	if req.PriceBreaks != nil {
+		entry.PriceBreaks = req.PriceBreaks
+	}
+	if msg := validateCatalogueEntry(entry); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	err = internal.DB.Transaction(func(tx *gorm.DB) error {
+		if req.PriceBreaks != nil {
+			if err := tx.Where("supplier_product_id = ?", entry.ID).Delete(&internal.SupplierPriceBreak{}).Error; err != nil {
+				return err
+			}
+			entry.PriceBreaks = nil
+			for _, pb := range req.PriceBreaks {
+				pb.ID = 0
+				pb.SupplierProductID = entry.ID
+				if err := tx.Create(&pb).Error; err != nil {
+					return err
+				}
+				entry.PriceBreaks = append(entry.PriceBreaks, pb)
+			}
+		}
+		if err := tx.Omit("PriceBreaks", "Supplier", "Product").Save(entry).Error; err != nil {
+			return err
+		}
+		if entry.Preferred && !wasPreferred {
+			return setPreferred(tx, entry)
+		}
+		if !entry.Preferred && wasPreferred {
+			return tx.Model(&internal.Product{}).
+				Where("id = ? AND preferred_supplier_id = ?", entry.ProductID, entry.SupplierID).
+				Update("preferred_supplier_id", nil).Error
+		}
+		return nil
+	})
+	if err != nil {
+		http.Error(w, "Failed to update supplier product", http.StatusInternalServerError)
+		return
+	}
+
+	internal.LogAudit("UPDATE", "SupplierProduct", entry.ID, "system", "Updated supplier catalogue entry")
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   entry,
+	})
+}
+func RemoveSupplierProduct(w http.ResponseWriter, r *http.Request) {
+	supplierID, productID := extractCatalogueIDs(r.URL.Path)
+	if supplierID == 0 || productID == 0 {
+		http.Error(w, "Invalid supplier or product ID", http.StatusBadRequest)
+		return
+	}
+
+	entry, err := FindCatalogueEntry(uint(supplierID), uint(productID))
+	if err != nil {
+		http.Error(w, "Supplier product not found", http.StatusNotFound)
+		return
+	}
+
+	err = internal.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("supplier_product_id = ?", entry.ID).Delete(&internal.SupplierPriceBreak{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(entry).Error; err != nil {
+			return err
+		}
+		if entry.Preferred {
+			return tx.Model(&internal.Product{}).
+				Where("id = ? AND preferred_supplier_id = ?", entry.ProductID, entry.SupplierID).
+				Update("preferred_supplier_id", nil).Error
+		}
+		return nil
+	})
+	if err != nil {
+		http.Error(w, "Failed to remove supplier product", http.StatusInternalServerError)
+		return
+	}
+
+	internal.LogAudit("DELETE", "SupplierProduct", entry.ID, "system", "Removed product from supplier catalogue")
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"message": "Supplier product removed successfully",
+	})
+}
+func ListProductSuppliers(w http.ResponseWriter, r *http.Request) {
+	id := extractID(r.URL.Path, "/products/")
+	if id == 0 {
+		http.Error(w, "Invalid product ID", http.StatusBadRequest)
+		return
+	}
+
+	var product internal.Product
+	if err := internal.DB.First(&product, id).Error; err != nil {
+		http.Error(w, "Product not found", http.StatusNotFound)
+		return
+	}
+
+	var entries []internal.SupplierProduct
+	if err := internal.DB.Preload("Supplier").Preload("PriceBreaks", orderByMinQuantity).
+		Where("product_id = ?", id).
+		Order("preferred DESC, unit_price").
+		Find(&entries).Error; err != nil {
+		http.Error(w, "Failed to fetch product suppliers", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   entries,
+	})
+}
+
+// setPreferred makes entry the only preferred supplier for its product and
+// records it on the product for replenishment.
+func setPreferred(tx *gorm.DB, entry *internal.SupplierProduct) error {
+	if err := tx.Model(&internal.SupplierProduct{}).
+		Where("product_id = ? AND id <> ?", entry.ProductID, entry.ID).
+		Update("preferred", false).Error; err != nil {
+		return err
+	}
+	return tx.Model(&internal.Product{}).
+		Where("id = ?", entry.ProductID).
+		Update("preferred_supplier_id", entry.SupplierID).Error
+}
+
+func orderByMinQuantity(db *gorm.DB) *gorm.DB {
+	return db.Order("min_quantity")
+}
+
+// extractCatalogueIDs parses /suppliers/{id}/products/{productId}.
+func extractCatalogueIDs(path string) (int, int) {
+	return extractID(path, "/suppliers/"), extractChildID(path, "/products/")
+}
+
+// extractChildID returns the ID following segment in path, e.g. the contact
+// ID in /suppliers/1/contacts/7.
+func extractChildID(path, segment string) int {
+	idx := strings.Index(path, segment)
+	if idx == -1 {
+		return 0
+	}
+	id, _ := strconv.Atoi(strings.Trim(path[idx+len(segment):], "/"))
+	return id
+}
@@ -0,0 +1,71 @@
+package suppliers
+
+import (
+	"myapp/internal"
+	"testing"
+)
+
+func TestPriceForQuantity(t *testing.T) {
+	entry := &internal.SupplierProduct{
+		UnitPrice: 10,
+		// Deliberately out of order
+		PriceBreaks: []internal.SupplierPriceBreak{
+			{MinQuantity: 100, UnitPrice: 8},
+			{MinQuantity: 50, UnitPrice: 9},
+			{MinQuantity: 500, UnitPrice: 7},
+		},
+	}
+	tests := []struct {
+		qty  int
+		want float64
+	}{
+		{1, 10},
+		{49, 10},
+		{50, 9},
+		{99, 9},
+		{100, 8},
+		{499, 8},
+		{500, 7},
+		{10000, 7},
+	}
+	for _, tt := range tests {
+		if got := PriceForQuantity(entry, tt.qty); got != tt.want {
+			t.Errorf("PriceForQuantity(%d) = %v, want %v", tt.qty, got, tt.want)
+		}
+	}
+
+	if got := PriceForQuantity(&internal.SupplierProduct{UnitPrice: 4.5}, 1000); got != 4.5 {
+		t.Errorf("without breaks = %v, want the unit price", got)
+	}
+}
+
+func TestValidateCatalogueEntry(t *testing.T) {
+	breaks := func(pbs ...internal.SupplierPriceBreak) []internal.SupplierPriceBreak { return pbs }
+	tests := []struct {
+		name    string
+		entry   internal.SupplierProduct
+		invalid bool
+	}{
+		{"valid", internal.SupplierProduct{UnitPrice: 10, LeadTimeDays: 7, PriceBreaks: breaks(
+			internal.SupplierPriceBreak{MinQuantity: 10, UnitPrice: 9})}, false},
+		{"free sample", internal.SupplierProduct{UnitPrice: 0}, false},
+		{"negative price", internal.SupplierProduct{UnitPrice: -1}, true},
+		{"negative lead time", internal.SupplierProduct{UnitPrice: 10, LeadTimeDays: -2}, true},
+		{"zero break quantity", internal.SupplierProduct{UnitPrice: 10, PriceBreaks: breaks(
+			internal.SupplierPriceBreak{MinQuantity: 0, UnitPrice: 9})}, true},
+		{"negative break quantity", internal.SupplierProduct{UnitPrice: 10, PriceBreaks: breaks(
+			internal.SupplierPriceBreak{MinQuantity: -5, UnitPrice: 9})}, true},
+		{"negative break price", internal.SupplierProduct{UnitPrice: 10, PriceBreaks: breaks(
+			internal.SupplierPriceBreak{MinQuantity: 10, UnitPrice: -9})}, true},
+		{"duplicate break quantity", internal.SupplierProduct{UnitPrice: 10, PriceBreaks: breaks(
+			internal.SupplierPriceBreak{MinQuantity: 10, UnitPrice: 9},
+			internal.SupplierPriceBreak{MinQuantity: 10, UnitPrice: 8})}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if msg := validateCatalogueEntry(&tt.entry); (msg != "") != tt.invalid {
+				t.Errorf("validateCatalogueEntry = %q, want invalid %v", msg, tt.invalid)
+			}
+		})
+	}
+}
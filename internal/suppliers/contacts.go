@@ -0,0 +1,184 @@
+package suppliers
+
+import (
+	"encoding/json"
+	"myapp/internal"
+	"net/http"
+
+	"gorm.io/gorm"
+)
+
+func ListSupplierContacts(w http.ResponseWriter, r *http.Request) {
+	id := extractID(r.URL.Path, "/suppliers/")
+	if id == 0 {
+		http.Error(w, "Invalid supplier ID", http.StatusBadRequest)
+		return
+	}
+
+	var contacts []internal.SupplierContact
+	if err := internal.DB.Where("supplier_id = ?", id).
+		Order("is_primary DESC, name").Find(&contacts).Error; err != nil {
+		http.Error(w, "Failed to fetch supplier contacts", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   contacts,
+	})
+}
+func AddSupplierContact(w http.ResponseWriter, r *http.Request) {
+	id := extractID(r.URL.Path, "/suppliers/")
+	if id == 0 {
+		http.Error(w, "Invalid supplier ID", http.StatusBadRequest)
+		return
+	}
+
+	var supplier internal.Supplier
+	if err := internal.DB.First(&supplier, id).Error; err != nil {
+		http.Error(w, "Supplier not found", http.StatusNotFound)
+		return
+	}
+
+	var contact internal.SupplierContact
+	if err := json.NewDecoder(r.Body).Decode(&contact); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	if contact.Name == "" {
+		http.Error(w, "Contact name required", http.StatusBadRequest)
+		return
+	}
+	contact.ID = 0
+	contact.SupplierID = supplier.ID
+
+	err := internal.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&contact).Error; err != nil {
+			return err
+		}
+		if contact.IsPrimary {
+			return setPrimaryContact(tx, &contact)
+		}
+		return nil
+	})
+	if err != nil {
+		http.Error(w, "Failed to add supplier contact", http.StatusInternalServerError)
+		return
+	}
+
+	internal.LogAudit("CREATE", "SupplierContact", contact.ID, "system", "Added supplier contact")
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   contact,
+	})
+}
+func UpdateSupplierContact(w http.ResponseWriter, r *http.Request) {
+	supplierID := extractID(r.URL.Path, "/suppliers/")
+	contactID := extractChildID(r.URL.Path, "/contacts/")
+	if supplierID == 0 || contactID == 0 {
+		http.Error(w, "Invalid supplier or contact ID", http.StatusBadRequest)
+		return
+	}
+
+	var contact internal.SupplierContact
+	if err := internal.DB.Where("id = ? AND supplier_id = ?", contactID, supplierID).
+		First(&contact).Error; err != nil {
+		http.Error(w, "Supplier contact not found", http.StatusNotFound)
+		return
+	}
+
+	// Omitted fields are left alone; empty strings and false clear them
+	var req struct {
+		Name      *string `json:"name"`
+		Role      *string `json:"role"`
+		Email     *string `json:"email"`
+		Phone     *string `json:"phone"`
+		IsPrimary *bool   `json:"is_primary"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	if req.Name != nil && *req.Name == "" {
+		http.Error(w, "Contact name required", http.StatusBadRequest)
+		return
+	}
+	updates := make(map[string]interface{})
+	if req.Name != nil {
+		updates["name"] = *req.Name
+	}
+	if req.Role != nil {
+		updates["role"] = *req.Role
+	}
+	if req.Email != nil {
+		updates["email"] = *req.Email
+	}
+	if req.Phone != nil {
+		updates["phone"] = *req.Phone
+	}
+	if req.IsPrimary != nil {
+		updates["is_primary"] = *req.IsPrimary
+	}
+
+	err := internal.DB.Transaction(func(tx *gorm.DB) error {
+		if len(updates) > 0 {
+			if err := tx.Model(&contact).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+		if req.IsPrimary != nil && *req.IsPrimary {
+			return setPrimaryContact(tx, &contact)
+		}
+		return nil
+	})
+	if err != nil {
+		http.Error(w, "Failed to update supplier contact", http.StatusInternalServerError)
+		return
+	}
+
+	internal.LogAudit("UPDATE", "SupplierContact", contact.ID, "system", "Updated supplier contact")
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   contact,
+	})
+}
+func DeleteSupplierContact(w http.ResponseWriter, r *http.Request) {
+	supplierID := extractID(r.URL.Path, "/suppliers/")
+	contactID := extractChildID(r.URL.Path, "/contacts/")
+	if supplierID == 0 || contactID == 0 {
+		http.Error(w, "Invalid supplier or contact ID", http.StatusBadRequest)
+		return
+	}
+
+	result := internal.DB.Where("id = ? AND supplier_id = ?", contactID, supplierID).
+		Delete(&internal.SupplierContact{})
+	if result.Error != nil {
+		http.Error(w, "Failed to delete supplier contact", http.StatusInternalServerError)
+		return
+	}
+	if result.RowsAffected == 0 {
+		http.Error(w, "Supplier contact not found", http.StatusNotFound)
+		return
+	}
+
+	internal.LogAudit("DELETE", "SupplierContact", uint(contactID), "system", "Deleted supplier contact")
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"message": "Supplier contact deleted successfully",
+	})
+}
+
+// setPrimaryContact makes contact the supplier's only primary contact.
+func setPrimaryContact(tx *gorm.DB, contact *internal.SupplierContact) error {
+	return tx.Model(&internal.SupplierContact{}).
+		Where("supplier_id = ? AND id <> ?", contact.SupplierID, contact.ID).
+		Update("is_primary", false).Error
+}
@@ -3,10 +3,14 @@ package suppliers
 import (
 	"encoding/json"
 	"myapp/internal"
+	"myapp/internal/outbox"
 	"myapp/internal/websocket"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
+
+	"gorm.io/gorm"
 )
 
 func CreateSupplier(w http.ResponseWriter, r *http.Request) {
@@ -15,19 +19,37 @@ func CreateSupplier(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid request payload", http.StatusBadRequest)
 		return
 	}
+	// Rating is computed from purchase order history, not entered
+	supplier.Rating = 0
+	supplier.RatedAt = nil
+	supplier.ArchivedAt = nil
+	if supplier.Status == "" {
+		supplier.Status = "active"
+	} else if !validStatus(supplier.Status) {
+		http.Error(w, "Invalid supplier status", http.StatusBadRequest)
+		return
+	}
 
-	if err := internal.DB.Create(&supplier).Error; err != nil {
+	err := internal.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&supplier).Error; err != nil {
+			return err
+		}
+		return outbox.Enqueue(tx, websocket.Event{Payload: websocket.SupplierUpdated{
+			SupplierID:   supplier.ID,
+			SupplierName: supplier.Name,
+			Email:        supplier.Email,
+			Phone:        supplier.Phone,
+			Address:      supplier.Address,
+			Action:       "created",
+		}})
+	})
+	if err != nil {
 		http.Error(w, "Failed to create supplier", http.StatusInternalServerError)
 		return
 	}
 
 	internal.LogAudit("CREATE", "Supplier", supplier.ID, "system", "Created new supplier")
 
-	// Broadcast supplier creation via WebSocket
-	if hub := websocket.GetHub(); hub != nil {
-		hub.BroadcastSupplierUpdate(supplier.ID, supplier.Name, supplier.Email, supplier.Phone, supplier.Address, "created")
-	}
-
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -37,7 +59,15 @@ func CreateSupplier(w http.ResponseWriter, r *http.Request) {
 }
 func ListSuppliers(w http.ResponseWriter, r *http.Request) {
 	var suppliers []internal.Supplier
-	if err := internal.DB.Find(&suppliers).Error; err != nil {
+	query := internal.DB.Preload("Contacts")
+	if r.URL.Query().Get("include_archived") != "true" {
+		query = query.Where("archived_at IS NULL")
+	}
+	status := r.URL.Query().Get("status")
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if err := query.Find(&suppliers).Error; err != nil {
 		http.Error(w, "Failed to fetch suppliers", http.StatusInternalServerError)
 		return
 	}
@@ -48,6 +78,58 @@ func ListSuppliers(w http.ResponseWriter, r *http.Request) {
 		"data":   suppliers,
 	})
 }
+func GetSupplier(w http.ResponseWriter, r *http.Request) {
+	id := extractID(r.URL.Path, "/suppliers/")
+	if id == 0 {
+		http.Error(w, "Invalid supplier ID", http.StatusBadRequest)
+		return
+	}
+
+	var supplier internal.Supplier
+	if err := internal.DB.Preload("Contacts").First(&supplier, id).Error; err != nil {
+		http.Error(w, "Supplier not found", http.StatusNotFound)
+		return
+	}
+
+	var openPOs []internal.PurchaseOrder
+	if err := internal.DB.Preload("Items.Product").
+		Where("supplier_id = ? AND status IN ?", id, []string{"draft", "pending"}).
+		Order("order_date DESC").Find(&openPOs).Error; err != nil {
+		http.Error(w, "Failed to fetch purchase orders", http.StatusInternalServerError)
+		return
+	}
+
+	var spend struct {
+		TotalSpend    float64
+		SpendLastYear float64
+	}
+	if err := internal.DB.Model(&internal.PurchaseOrder{}).
+		Select("COALESCE(SUM(total_cost), 0) as total_spend, COALESCE(SUM(CASE WHEN received_at >= ? THEN total_cost ELSE 0 END), 0) as spend_last_year",
+			time.Now().AddDate(-1, 0, 0)).
+		Where("supplier_id = ? AND status = ?", id, "received").
+		Scan(&spend).Error; err != nil {
+		http.Error(w, "Failed to calculate supplier spend", http.StatusInternalServerError)
+		return
+	}
+	var openCommitment float64
+	for _, po := range openPOs {
+		if po.Status == "pending" {
+			openCommitment += po.TotalCost
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data": struct {
+			internal.Supplier
+			OpenPurchaseOrders []internal.PurchaseOrder `json:"open_purchase_orders"`
+			TotalSpend         float64                  `json:"total_spend"`
+			SpendLast12Months  float64                  `json:"spend_last_12_months"`
+			OpenCommitment     float64                  `json:"open_commitment"`
+		}{supplier, openPOs, spend.TotalSpend, spend.SpendLastYear, openCommitment},
+	})
+}
 func UpdateSupplier(w http.ResponseWriter, r *http.Request) {
 	id := extractID(r.URL.Path, "/suppliers/")
 	if id == 0 {
@@ -66,25 +148,169 @@ func UpdateSupplier(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid request payload", http.StatusBadRequest)
 		return
 	}
+	// Rating is computed and status has its own endpoint
+	updates.Rating = 0
+	updates.RatedAt = nil
+	updates.Status = ""
+	updates.ArchivedAt = nil
+	updates.Contacts = nil
 
-	if err := internal.DB.Model(&supplier).Updates(updates).Error; err != nil {
+	err := internal.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&supplier).Updates(updates).Error; err != nil {
+			return err
+		}
+		return outbox.Enqueue(tx, websocket.Event{Payload: websocket.SupplierUpdated{
+			SupplierID:   supplier.ID,
+			SupplierName: supplier.Name,
+			Email:        supplier.Email,
+			Phone:        supplier.Phone,
+			Address:      supplier.Address,
+			Action:       "updated",
+		}})
+	})
+	if err != nil {
 		http.Error(w, "Failed to update supplier", http.StatusInternalServerError)
 		return
 	}
 
 	internal.LogAudit("UPDATE", "Supplier", supplier.ID, "system", "Updated supplier")
 
-	// Broadcast supplier update via WebSocket
-	if hub := websocket.GetHub(); hub != nil {
-		hub.BroadcastSupplierUpdate(supplier.ID, supplier.Name, supplier.Email, supplier.Phone, supplier.Address, "updated")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   supplier,
+	})
+}
+func UpdateSupplierStatus(w http.ResponseWriter, r *http.Request) {
+	id := extractID(r.URL.Path, "/suppliers/")
+	if id == 0 {
+		http.Error(w, "Invalid supplier ID", http.StatusBadRequest)
+		return
 	}
 
+	var req struct {
+		Status string `json:"status"`
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	if !validStatus(req.Status) {
+		http.Error(w, "Invalid supplier status", http.StatusBadRequest)
+		return
+	}
+
+	var supplier internal.Supplier
+	if err := internal.DB.First(&supplier, id).Error; err != nil {
+		http.Error(w, "Supplier not found", http.StatusNotFound)
+		return
+	}
+	if supplier.ArchivedAt != nil {
+		http.Error(w, "Supplier is archived", http.StatusBadRequest)
+		return
+	}
+
+	message := req.Reason
+	if message == "" {
+		message = "Supplier status changed to " + req.Status
+	}
+	err := internal.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&supplier).Update("status", req.Status).Error; err != nil {
+			return err
+		}
+		return outbox.Enqueue(tx, websocket.Event{Payload: websocket.SupplierStatusAlert{
+			SupplierID:   supplier.ID,
+			SupplierName: supplier.Name,
+			Status:       req.Status,
+			Message:      message,
+		}})
+	})
+	if err != nil {
+		http.Error(w, "Failed to update supplier status", http.StatusInternalServerError)
+		return
+	}
+
+	internal.LogAudit("UPDATE_STATUS", "Supplier", supplier.ID, "system", "Updated supplier status to "+req.Status)
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"status": "success",
 		"data":   supplier,
 	})
 }
+func ArchiveSupplier(w http.ResponseWriter, r *http.Request) {
+	id := extractID(r.URL.Path, "/suppliers/")
+	if id == 0 {
+		http.Error(w, "Invalid supplier ID", http.StatusBadRequest)
+		return
+	}
+
+	var supplier internal.Supplier
+	if err := internal.DB.First(&supplier, id).Error; err != nil {
+		http.Error(w, "Supplier not found", http.StatusNotFound)
+		return
+	}
+	if supplier.ArchivedAt != nil {
+		http.Error(w, "Supplier is already archived", http.StatusBadRequest)
+		return
+	}
+
+	var openPOs int64
+	if err := internal.DB.Model(&internal.PurchaseOrder{}).
+		Where("supplier_id = ? AND status = ?", id, "pending").Count(&openPOs).Error; err != nil {
+		http.Error(w, "Failed to check purchase orders", http.StatusInternalServerError)
+		return
+	}
+	if openPOs > 0 {
+		http.Error(w, "Supplier has pending purchase orders", http.StatusConflict)
+		return
+	}
+
+	now := time.Now()
+	err := internal.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&supplier).Updates(map[string]interface{}{
+			"status":      "inactive",
+			"archived_at": now,
+		}).Error; err != nil {
+			return err
+		}
+		return outbox.Enqueue(tx, websocket.Event{Payload: websocket.SupplierUpdated{
+			SupplierID:   supplier.ID,
+			SupplierName: supplier.Name,
+			Email:        supplier.Email,
+			Phone:        supplier.Phone,
+			Address:      supplier.Address,
+			Action:       "deleted",
+		}})
+	})
+	if err != nil {
+		http.Error(w, "Failed to archive supplier", http.StatusInternalServerError)
+		return
+	}
+
+	internal.LogAudit("ARCHIVE", "Supplier", supplier.ID, "system", "Archived supplier")
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"message": "Supplier archived successfully",
+	})
+}
+
+// CanOrderFrom reports whether new purchase orders may be raised with a
+// supplier.
+func CanOrderFrom(supplier internal.Supplier) bool {
+	return supplier.Status != "suspended" && supplier.ArchivedAt == nil
+}
+
+func validStatus(status string) bool {
+	switch status {
+	case "active", "inactive", "suspended":
+		return true
+	}
+	return false
+}
 
 func extractID(path, prefix string) int {
 	idStr := strings.TrimPrefix(path, prefix)
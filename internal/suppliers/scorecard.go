@@ -0,0 +1,241 @@
+package suppliers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"myapp/internal"
+	"myapp/internal/outbox"
+	"myapp/internal/websocket"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Weights of each metric in the overall score. Metrics without data are left
+// out and the remaining weights rescaled.
+const (
+	onTimeWeight   = 0.4
+	fillRateWeight = 0.3
+	qualityWeight  = 0.2
+	priceWeight    = 0.1
+)
+
+const defaultScoreWindow = 180 * 24 * time.Hour
+
+// Scorecard holds a supplier's delivery performance over a time window.
+// Rates are fractions between 0 and 1; nil means no data in the window.
+type Scorecard struct {
+	SupplierID     uint      `json:"supplier_id"`
+	SupplierName   string    `json:"supplier_name"`
+	From           time.Time `json:"from"`
+	To             time.Time `json:"to"`
+	ReceivedOrders int       `json:"received_orders"`
+	OnTimeRate     *float64  `json:"on_time_rate"`
+	FillRate       *float64  `json:"fill_rate"`
+	DefectRate     *float64  `json:"defect_rate"`
+	PriceVariance  *float64  `json:"price_variance"` // mean absolute deviation from catalogue price
+	Rating         *float64  `json:"rating"`         // 0-5
+}
+
+// ComputeScorecard scores purchase orders from a supplier received between
+// from and to.
+func ComputeScorecard(supplier internal.Supplier, from, to time.Time) (*Scorecard, error) {
+	var pos []internal.PurchaseOrder
+	if err := internal.DB.Preload("Items").
+		Where("supplier_id = ? AND status = ? AND received_at BETWEEN ? AND ?", supplier.ID, "received", from, to).
+		Find(&pos).Error; err != nil {
+		return nil, err
+	}
+
+	card := &Scorecard{
+		SupplierID:     supplier.ID,
+		SupplierName:   supplier.Name,
+		From:           from,
+		To:             to,
+		ReceivedOrders: len(pos),
+	}
+
+	var withExpected, onTime int
+	var ordered, received, rejected int
+	var varianceSum float64
+	var priced int
+	catalogue := make(map[uint]*internal.SupplierProduct)
+	for _, po := range pos {
+		if po.ExpectedAt != nil && po.ReceivedAt != nil {
+			withExpected++
+			if !po.ReceivedAt.After(endOfDay(*po.ExpectedAt)) {
+				onTime++
+			}
+		}
+		for _, item := range po.Items {
+			ordered += item.Quantity
+			received += item.ReceivedQuantity
+			rejected += item.RejectedQuantity
+
+			entry, ok := catalogue[item.ProductID]
+			if !ok {
+				entry, _ = FindCatalogueEntry(supplier.ID, item.ProductID)
+				catalogue[item.ProductID] = entry
+			}
+			if entry == nil {
+				continue
+			}
+			if expected := PriceForQuantity(entry, item.Quantity); expected > 0 {
+				varianceSum += math.Abs(item.UnitPrice-expected) / expected
+				priced++
+			}
+		}
+	}
+
+	if withExpected > 0 {
+		card.OnTimeRate = ratio(onTime, withExpected)
+	}
+	if ordered > 0 {
+		// Rejected units were not supplied; over-deliveries do not count
+		card.FillRate = ratio(min(received, ordered), ordered)
+	}
+	if received+rejected > 0 {
+		card.DefectRate = ratio(rejected, received+rejected)
+	}
+	if priced > 0 {
+		v := varianceSum / float64(priced)
+		card.PriceVariance = &v
+	}
+
+	var score, weight float64
+	if card.OnTimeRate != nil {
+		score += onTimeWeight * *card.OnTimeRate
+		weight += onTimeWeight
+	}
+	if card.FillRate != nil {
+		score += fillRateWeight * *card.FillRate
+		weight += fillRateWeight
+	}
+	if card.DefectRate != nil {
+		score += qualityWeight * (1 - *card.DefectRate)
+		weight += qualityWeight
+	}
+	if card.PriceVariance != nil {
+		score += priceWeight * (1 - math.Min(*card.PriceVariance, 1))
+		weight += priceWeight
+	}
+	if weight > 0 {
+		rating := math.Round(score/weight*5*100) / 100
+		card.Rating = &rating
+	}
+	return card, nil
+}
+
+// RefreshRating recomputes a supplier's stored Rating from recent purchase
+// orders and raises a status alert when it falls below the configured
+// threshold. The first computed rating alerts if it starts out below it.
+func RefreshRating(supplierID uint) {
+	var supplier internal.Supplier
+	if err := internal.DB.First(&supplier, supplierID).Error; err != nil {
+		return
+	}
+
+	now := time.Now()
+	card, err := ComputeScorecard(supplier, now.Add(-defaultScoreWindow), now)
+	if err != nil {
+		log.Printf("Failed to score supplier %d: %v", supplierID, err)
+		return
+	}
+	if card.Rating == nil {
+		return
+	}
+
+	threshold := ratingThreshold()
+	wasAbove := supplier.RatedAt == nil || supplier.Rating >= threshold
+	err = internal.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&supplier).Updates(map[string]interface{}{
+			"rating":   *card.Rating,
+			"rated_at": now,
+		}).Error; err != nil {
+			return err
+		}
+		if *card.Rating < threshold && wasAbove {
+			return outbox.Enqueue(tx, websocket.Event{Payload: websocket.SupplierStatusAlert{
+				SupplierID:   supplier.ID,
+				SupplierName: supplier.Name,
+				Status:       "warning",
+				Message:      fmt.Sprintf("Supplier rating dropped to %.2f (threshold %.2f)", *card.Rating, threshold),
+			}})
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to save rating for supplier %d: %v", supplierID, err)
+	}
+}
+
+func GetSupplierScorecard(w http.ResponseWriter, r *http.Request) {
+	id := extractID(r.URL.Path, "/suppliers/")
+	if id == 0 {
+		http.Error(w, "Invalid supplier ID", http.StatusBadRequest)
+		return
+	}
+
+	var supplier internal.Supplier
+	if err := internal.DB.First(&supplier, id).Error; err != nil {
+		http.Error(w, "Supplier not found", http.StatusNotFound)
+		return
+	}
+
+	to := time.Now()
+	from := to.Add(-defaultScoreWindow)
+	if v := r.URL.Query().Get("from"); v != "" {
+		t, err := time.ParseInLocation("2006-01-02", v, time.Local)
+		if err != nil {
+			http.Error(w, "Invalid from date, expected YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+		from = t
+	}
+	if v := r.URL.Query().Get("to"); v != "" {
+		t, err := internal.ParseAsOf(v)
+		if err != nil {
+			http.Error(w, "Invalid to date, expected YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+		to = t
+	}
+
+	card, err := ComputeScorecard(supplier, from, to)
+	if err != nil {
+		http.Error(w, "Failed to compute supplier scorecard", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   card,
+	})
+}
+
+// ratingThreshold reads SUPPLIER_RATING_THRESHOLD, defaulting to 3.0.
+func ratingThreshold() float64 {
+	if v := os.Getenv("SUPPLIER_RATING_THRESHOLD"); v != "" {
+		if t, err := strconv.ParseFloat(v, 64); err == nil {
+			return t
+		}
+	}
+	return 3.0
+}
+
+func ratio(n, d int) *float64 {
+	v := float64(n) / float64(d)
+	return &v
+}
+
+// endOfDay is the last microsecond of t's day, matching internal.ParseAsOf.
+func endOfDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d+1, 0, 0, 0, 0, t.Location()).Add(-time.Microsecond)
+}
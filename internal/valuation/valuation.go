@@ -0,0 +1,258 @@
+// Package valuation values inventory at cost by replaying stock movements.
+// Receipts from purchase orders carry their PO unit price; other inbound
+// movements are costed at the position's current average cost, or the
+// product's standard cost when there is none.
+package valuation
+
+import (
+	"fmt"
+	"myapp/internal"
+	"os"
+	"sort"
+	"time"
+)
+
+// Method selects how issued units are costed.
+type Method string
+
+const (
+	// FIFO issues the oldest cost layers first.
+	FIFO Method = "fifo"
+	// WeightedAverage re-averages the unit cost on every receipt.
+	WeightedAverage Method = "average"
+	// Standard values every unit at Product.Cost.
+	Standard Method = "standard"
+)
+
+// ParseMethod accepts "fifo", "average" or "standard". An empty string
+// returns the INVENTORY_VALUATION_METHOD default, or FIFO.
+func ParseMethod(s string) (Method, error) {
+	if s == "" {
+		s = os.Getenv("INVENTORY_VALUATION_METHOD")
+	}
+	switch Method(s) {
+	case "":
+		return FIFO, nil
+	case FIFO, WeightedAverage, Standard:
+		return Method(s), nil
+	}
+	return "", fmt.Errorf("unknown valuation method %q", s)
+}
+
+// Movement is a stock movement with the cost known for receipts.
+type Movement struct {
+	ProductID   uint
+	WarehouseID uint
+	Quantity    int      // positive in, negative out
+	UnitCost    *float64 // purchase price for PO receipts, nil otherwise
+	CreatedAt   time.Time
+}
+
+// Position is the valued stock of one product in one warehouse.
+type Position struct {
+	ProductID   uint    `json:"product_id"`
+	WarehouseID uint    `json:"warehouse_id"`
+	Quantity    int     `json:"quantity"`
+	Value       float64 `json:"value"`
+	UnitCost    float64 `json:"unit_cost"`
+}
+
+type key struct{ product, warehouse uint }
+
+type layer struct {
+	quantity int
+	cost     float64
+}
+
+// ledger tracks one position while movements are replayed.
+type ledger struct {
+	layers   []layer // FIFO only, oldest first
+	quantity int
+	value    float64
+	lastCost float64
+}
+
+// Value replays movements in order and returns the closing position of
+// every product and warehouse, sorted by product then warehouse. standard
+// maps product IDs to Product.Cost.
+func Value(method Method, movements []Movement, standard map[uint]float64) []Position {
+	ledgers := make(map[key]*ledger)
+	for _, m := range movements {
+		k := key{m.ProductID, m.WarehouseID}
+		l := ledgers[k]
+		if l == nil {
+			l = &ledger{lastCost: standard[m.ProductID]}
+			ledgers[k] = l
+		}
+		if m.Quantity > 0 {
+			l.receive(method, m.Quantity, l.inboundCost(method, m, standard))
+		} else if m.Quantity < 0 {
+			l.issue(method, -m.Quantity, standard[m.ProductID])
+		}
+	}
+
+	positions := make([]Position, 0, len(ledgers))
+	for k, l := range ledgers {
+		p := Position{ProductID: k.product, WarehouseID: k.warehouse, Quantity: l.quantity, Value: round(l.value)}
+		if l.quantity != 0 {
+			p.UnitCost = round(l.value / float64(l.quantity))
+		}
+		positions = append(positions, p)
+	}
+	sort.Slice(positions, func(i, j int) bool {
+		if positions[i].ProductID != positions[j].ProductID {
+			return positions[i].ProductID < positions[j].ProductID
+		}
+		return positions[i].WarehouseID < positions[j].WarehouseID
+	})
+	return positions
+}
+
+func (l *ledger) inboundCost(method Method, m Movement, standard map[uint]float64) float64 {
+	switch {
+	case method == Standard:
+		return standard[m.ProductID]
+	case m.UnitCost != nil:
+		return *m.UnitCost
+	case l.quantity > 0:
+		return l.value / float64(l.quantity)
+	}
+	return l.lastCost
+}
+
+func (l *ledger) receive(method Method, qty int, cost float64) {
+	l.lastCost = cost
+	if method == FIFO {
+		// Units issued while stock was negative are settled first, at the
+		// cost they were carried at; the difference is cost of goods sold.
+		if l.quantity < 0 {
+			settled := min(qty, -l.quantity)
+			l.value += float64(settled) * l.value / float64(l.quantity)
+			l.quantity += settled
+			qty -= settled
+		}
+		if qty > 0 {
+			l.layers = append(l.layers, layer{quantity: qty, cost: cost})
+			l.quantity += qty
+			l.value += float64(qty) * cost
+		}
+		return
+	}
+	l.quantity += qty
+	if method == Standard {
+		l.value = float64(l.quantity) * cost
+		return
+	}
+	l.value += float64(qty) * cost
+}
+
+func (l *ledger) issue(method Method, qty int, standardCost float64) {
+	switch method {
+	case FIFO:
+		for qty > 0 && len(l.layers) > 0 {
+			take := min(qty, l.layers[0].quantity)
+			l.layers[0].quantity -= take
+			l.quantity -= take
+			l.value -= float64(take) * l.layers[0].cost
+			l.lastCost = l.layers[0].cost
+			qty -= take
+			if l.layers[0].quantity == 0 {
+				l.layers = l.layers[1:]
+			}
+		}
+		// Issued beyond what was received: carry at the last known cost
+		l.quantity -= qty
+		l.value -= float64(qty) * l.lastCost
+	case WeightedAverage:
+		cost := l.lastCost
+		if l.quantity > 0 {
+			cost = l.value / float64(l.quantity)
+		}
+		l.quantity -= qty
+		l.value -= float64(qty) * cost
+		l.lastCost = cost
+	case Standard:
+		l.quantity -= qty
+		l.value = float64(l.quantity) * standardCost
+	}
+}
+
+func round(v float64) float64 {
+	if v < 0 {
+		return -round(-v)
+	}
+	return float64(int64(v*100+0.5)) / 100
+}
+
+// Load reads the movements up to asOf, optionally limited to a warehouse
+// and product category, with the PO unit price of each receipt.
+func Load(asOf time.Time, warehouseID uint, category string) ([]Movement, error) {
+	return loadMovements(time.Time{}, asOf, warehouseID, category)
+}
+
+// LoadFrom is Load starting from the latest stock snapshot taken at or
+// before from rather than the beginning of the movement history. Snapshot
+// quantities become opening movements without a unit cost, so they are
+// carried at standard cost. Without a snapshot it reads the full history.
+func LoadFrom(from, asOf time.Time, warehouseID uint, category string) ([]Movement, error) {
+	var run internal.StockSnapshotRun
+	if err := internal.DB.Where("taken_at <= ?", from).Order("taken_at DESC").Limit(1).Find(&run).Error; err != nil {
+		return nil, err
+	}
+	if run.ID == 0 {
+		return Load(asOf, warehouseID, category)
+	}
+
+	var opening []Movement
+	query := internal.DB.Table("stock_snapshots ss").
+		Select("ss.product_id, ss.warehouse_id, ss.quantity, ss.taken_at AS created_at").
+		Where("ss.taken_at = ?", run.TakenAt)
+	if warehouseID != 0 {
+		query = query.Where("ss.warehouse_id = ?", warehouseID)
+	}
+	if category != "" {
+		query = query.Joins("JOIN products p ON ss.product_id = p.id").Where("p.category = ?", category)
+	}
+	if err := query.Order("ss.product_id, ss.warehouse_id").Scan(&opening).Error; err != nil {
+		return nil, err
+	}
+	movements, err := loadMovements(run.TakenAt, asOf, warehouseID, category)
+	if err != nil {
+		return nil, err
+	}
+	return append(opening, movements...), nil
+}
+
+// loadMovements reads the movements created after after and up to asOf.
+func loadMovements(after, asOf time.Time, warehouseID uint, category string) ([]Movement, error) {
+	var movements []Movement
+	query := internal.DB.Table("stock_movements sm").
+		Select(`sm.product_id, sm.warehouse_id, sm.quantity, sm.created_at,
+			(SELECT AVG(pi.unit_price) FROM po_items pi
+				JOIN purchase_orders po ON pi.po_id = po.id
+				WHERE sm.type = 'IN' AND po.po_number = sm.reference AND pi.product_id = sm.product_id) as unit_cost`).
+		Where("sm.created_at > ? AND sm.created_at <= ?", after, asOf)
+	if warehouseID != 0 {
+		query = query.Where("sm.warehouse_id = ?", warehouseID)
+	}
+	if category != "" {
+		query = query.Joins("JOIN products p ON sm.product_id = p.id").Where("p.category = ?", category)
+	}
+	if err := query.Order("sm.created_at, sm.id").Scan(&movements).Error; err != nil {
+		return nil, err
+	}
+	return movements, nil
+}
+
+// StandardCosts returns Product.Cost by product ID.
+func StandardCosts() (map[uint]float64, error) {
+	var products []internal.Product
+	if err := internal.DB.Select("id, cost").Find(&products).Error; err != nil {
+		return nil, err
+	}
+	costs := make(map[uint]float64, len(products))
+	for _, p := range products {
+		costs[p.ID] = p.Cost
+	}
+	return costs, nil
+}
@@ -0,0 +1,81 @@
+package valuation
+
+import (
+	"testing"
+)
+
+func cost(c float64) *float64 { return &c }
+
+// receipts of 10 @ 5 and 10 @ 8, then 15 issued, then 5 adjusted in.
+var movements = []Movement{
+	{ProductID: 1, WarehouseID: 1, Quantity: 10, UnitCost: cost(5)},
+	{ProductID: 1, WarehouseID: 1, Quantity: 10, UnitCost: cost(8)},
+	{ProductID: 1, WarehouseID: 1, Quantity: -15},
+	{ProductID: 1, WarehouseID: 1, Quantity: 5},
+	{ProductID: 1, WarehouseID: 2, Quantity: 4, UnitCost: cost(7)},
+}
+
+var standard = map[uint]float64{1: 6}
+
+func TestValueMethods(t *testing.T) {
+	tests := []struct {
+		method Method
+		want   []Position
+	}{
+		// 10@5 issued, then 5@8; 5@8 left plus 5 adjusted in at the 8 average
+		{FIFO, []Position{
+			{ProductID: 1, WarehouseID: 1, Quantity: 10, Value: 80, UnitCost: 8},
+			{ProductID: 1, WarehouseID: 2, Quantity: 4, Value: 28, UnitCost: 7},
+		}},
+		// average 6.5 after receipts; 5 left at 6.5 plus 5 adjusted in at 6.5
+		{WeightedAverage, []Position{
+			{ProductID: 1, WarehouseID: 1, Quantity: 10, Value: 65, UnitCost: 6.5},
+			{ProductID: 1, WarehouseID: 2, Quantity: 4, Value: 28, UnitCost: 7},
+		}},
+		{Standard, []Position{
+			{ProductID: 1, WarehouseID: 1, Quantity: 10, Value: 60, UnitCost: 6},
+			{ProductID: 1, WarehouseID: 2, Quantity: 4, Value: 24, UnitCost: 6},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.method), func(t *testing.T) {
+			got := Value(tt.method, movements, standard)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %+v", got)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("position %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestFIFOSettlesNegativeStock(t *testing.T) {
+	got := Value(FIFO, []Movement{
+		{ProductID: 1, WarehouseID: 1, Quantity: -3},
+		{ProductID: 1, WarehouseID: 1, Quantity: 5, UnitCost: cost(10)},
+	}, standard)
+	want := Position{ProductID: 1, WarehouseID: 1, Quantity: 2, Value: 20, UnitCost: 10}
+	if len(got) != 1 || got[0] != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestParseMethod(t *testing.T) {
+	t.Setenv("INVENTORY_VALUATION_METHOD", "")
+	if m, err := ParseMethod(""); err != nil || m != FIFO {
+		t.Errorf("default = %v, %v", m, err)
+	}
+	t.Setenv("INVENTORY_VALUATION_METHOD", "average")
+	if m, _ := ParseMethod(""); m != WeightedAverage {
+		t.Errorf("env default = %v", m)
+	}
+	if m, _ := ParseMethod("standard"); m != Standard {
+		t.Errorf("explicit = %v", m)
+	}
+	if _, err := ParseMethod("lifo"); err == nil {
+		t.Error("lifo accepted")
+	}
+}
@@ -3,10 +3,13 @@ package warehouses
 import (
 	"encoding/json"
 	"myapp/internal"
+	"myapp/internal/outbox"
 	"myapp/internal/websocket"
 	"net/http"
 	"strconv"
 	"strings"
+
+	"gorm.io/gorm"
 )
 
 func CreateWarehouse(w http.ResponseWriter, r *http.Request) {
@@ -16,18 +19,25 @@ func CreateWarehouse(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := internal.DB.Create(&warehouse).Error; err != nil {
+	err := internal.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&warehouse).Error; err != nil {
+			return err
+		}
+		return outbox.Enqueue(tx, websocket.Event{Payload: websocket.WarehouseUpdated{
+			WarehouseID:   warehouse.ID,
+			WarehouseName: warehouse.Name,
+			Location:      warehouse.Location,
+			Capacity:      warehouse.Capacity,
+			Action:        "created",
+		}})
+	})
+	if err != nil {
 		http.Error(w, "Failed to create warehouse", http.StatusInternalServerError)
 		return
 	}
 
 	internal.LogAudit("CREATE", "Warehouse", warehouse.ID, "system", "Created new warehouse")
 
-	// Broadcast warehouse creation via WebSocket
-	if hub := websocket.GetHub(); hub != nil {
-		hub.BroadcastWarehouseUpdate(warehouse.ID, warehouse.Name, warehouse.Location, warehouse.Capacity, "created")
-	}
-
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -106,19 +116,35 @@ func UpdateWarehouse(w http.ResponseWriter, r *http.Request) {
 	if updates.Capacity != 0 {
 		warehouse.Capacity = updates.Capacity
 	}
+	if updates.Latitude != nil {
+		warehouse.Latitude = updates.Latitude
+	}
+	if updates.Longitude != nil {
+		warehouse.Longitude = updates.Longitude
+	}
+	if updates.Priority != 0 {
+		warehouse.Priority = updates.Priority
+	}
 
-	if err := internal.DB.Save(&warehouse).Error; err != nil {
+	err := internal.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&warehouse).Error; err != nil {
+			return err
+		}
+		return outbox.Enqueue(tx, websocket.Event{Payload: websocket.WarehouseUpdated{
+			WarehouseID:   warehouse.ID,
+			WarehouseName: warehouse.Name,
+			Location:      warehouse.Location,
+			Capacity:      warehouse.Capacity,
+			Action:        "updated",
+		}})
+	})
+	if err != nil {
 		http.Error(w, "Failed to update warehouse", http.StatusInternalServerError)
 		return
 	}
 
 	internal.LogAudit("UPDATE", "Warehouse", warehouse.ID, "system", "Updated warehouse")
 
-	// Broadcast warehouse update via WebSocket
-	if hub := websocket.GetHub(); hub != nil {
-		hub.BroadcastWarehouseUpdate(warehouse.ID, warehouse.Name, warehouse.Location, warehouse.Capacity, "updated")
-	}
-
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"status": "success",
@@ -139,23 +165,24 @@ func DeleteWarehouse(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Store warehouse info before deletion for WebSocket broadcast
-	warehouseName := warehouse.Name
-	warehouseLocation := warehouse.Location
-	warehouseCapacity := warehouse.Capacity
-	warehouseID := warehouse.ID
-
-	if err := internal.DB.Delete(&warehouse).Error; err != nil {
+	err := internal.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&warehouse).Error; err != nil {
+			return err
+		}
+		return outbox.Enqueue(tx, websocket.Event{Payload: websocket.WarehouseUpdated{
+			WarehouseID:   warehouse.ID,
+			WarehouseName: warehouse.Name,
+			Location:      warehouse.Location,
+			Capacity:      warehouse.Capacity,
+			Action:        "deleted",
+		}})
+	})
+	if err != nil {
 		http.Error(w, "Failed to delete warehouse", http.StatusInternalServerError)
 		return
 	}
 
-	internal.LogAudit("DELETE", "Warehouse", warehouseID, "system", "Deleted warehouse")
-
-	// Broadcast warehouse deletion via WebSocket
-	if hub := websocket.GetHub(); hub != nil {
-		hub.BroadcastWarehouseUpdate(warehouseID, warehouseName, warehouseLocation, warehouseCapacity, "deleted")
-	}
+	internal.LogAudit("DELETE", "Warehouse", warehouse.ID, "system", "Deleted warehouse")
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -0,0 +1,312 @@
+package webhooks
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"myapp/internal"
+	"myapp/internal/websocket"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type webhookRequest struct {
+	URL        *string   `json:"url"`
+	EventTypes *[]string `json:"event_types"`
+	Secret     *string   `json:"secret"`
+	Active     *bool     `json:"active"`
+}
+
+// validate checks the fields present in the request.
+func (req webhookRequest) validate() string {
+	if req.URL != nil {
+		u, err := url.Parse(*req.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return "URL must be an absolute http or https URL"
+		}
+	}
+	if req.EventTypes != nil {
+		if len(*req.EventTypes) == 0 {
+			return "At least one event type required"
+		}
+		for _, t := range *req.EventTypes {
+			if t != "*" && !websocket.IsEventType(t) {
+				return "Unknown event type: " + t
+			}
+		}
+	}
+	if req.Secret != nil && len(*req.Secret) < 16 {
+		return "Secret must be at least 16 characters"
+	}
+	return ""
+}
+
+func CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	var req webhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	if req.URL == nil || req.EventTypes == nil {
+		http.Error(w, "URL and event types required", http.StatusBadRequest)
+		return
+	}
+	if msg := req.validate(); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	sub := internal.WebhookSubscription{
+		URL:        *req.URL,
+		EventTypes: *req.EventTypes,
+		Active:     true,
+	}
+	if req.Secret != nil {
+		sub.Secret = *req.Secret
+	} else {
+		sub.Secret = generateSecret()
+	}
+	if err := internal.DB.Create(&sub).Error; err != nil {
+		http.Error(w, "Failed to create webhook", http.StatusInternalServerError)
+		return
+	}
+
+	internal.LogAudit("CREATE", "WebhookSubscription", sub.ID, "system", "Created webhook for "+sub.URL)
+
+	// The secret is only ever returned here
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   sub,
+		"secret": sub.Secret,
+	})
+}
+
+func ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	var subs []internal.WebhookSubscription
+	if err := internal.DB.Order("id").Find(&subs).Error; err != nil {
+		http.Error(w, "Failed to fetch webhooks", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   subs,
+	})
+}
+
+func GetWebhook(w http.ResponseWriter, r *http.Request) {
+	id := extractID(r.URL.Path, "/webhooks/")
+	if id == 0 {
+		http.Error(w, "Invalid webhook ID", http.StatusBadRequest)
+		return
+	}
+
+	var sub internal.WebhookSubscription
+	if err := internal.DB.First(&sub, id).Error; err != nil {
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   sub,
+	})
+}
+
+// UpdateWebhook changes the fields given. Setting "active": true re-enables
+// a disabled webhook; its pending deliveries are then sent again.
+func UpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	id := extractID(r.URL.Path, "/webhooks/")
+	if id == 0 {
+		http.Error(w, "Invalid webhook ID", http.StatusBadRequest)
+		return
+	}
+
+	var sub internal.WebhookSubscription
+	if err := internal.DB.First(&sub, id).Error; err != nil {
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+		return
+	}
+
+	var req webhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	if msg := req.validate(); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	var columns []string
+	if req.URL != nil {
+		sub.URL = *req.URL
+		columns = append(columns, "url")
+	}
+	if req.EventTypes != nil {
+		sub.EventTypes = *req.EventTypes
+		columns = append(columns, "event_types")
+	}
+	if req.Secret != nil {
+		sub.Secret = *req.Secret
+		columns = append(columns, "secret")
+	}
+	if req.Active != nil && *req.Active != sub.Active {
+		sub.Active = *req.Active
+		if sub.Active {
+			sub.FailureCount = 0
+			sub.DisabledAt = nil
+			sub.DisabledReason = ""
+		} else {
+			now := time.Now()
+			sub.DisabledAt = &now
+			sub.DisabledReason = "Disabled by user"
+		}
+		columns = append(columns, "active", "failure_count", "disabled_at", "disabled_reason")
+	}
+	if len(columns) > 0 {
+		if err := internal.DB.Model(&sub).Select(columns).Updates(&sub).Error; err != nil {
+			http.Error(w, "Failed to update webhook", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	internal.LogAudit("UPDATE", "WebhookSubscription", sub.ID, "system", "Updated webhook")
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   sub,
+	})
+}
+
+func DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	id := extractID(r.URL.Path, "/webhooks/")
+	if id == 0 {
+		http.Error(w, "Invalid webhook ID", http.StatusBadRequest)
+		return
+	}
+
+	var sub internal.WebhookSubscription
+	if err := internal.DB.First(&sub, id).Error; err != nil {
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+		return
+	}
+	if err := internal.DB.Where("subscription_id = ?", sub.ID).Delete(&internal.WebhookDelivery{}).Error; err != nil {
+		http.Error(w, "Failed to delete webhook", http.StatusInternalServerError)
+		return
+	}
+	if err := internal.DB.Delete(&sub).Error; err != nil {
+		http.Error(w, "Failed to delete webhook", http.StatusInternalServerError)
+		return
+	}
+
+	internal.LogAudit("DELETE", "WebhookSubscription", sub.ID, "system", "Deleted webhook for "+sub.URL)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"message": "Webhook deleted successfully",
+	})
+}
+
+// ListDeliveries returns a webhook's delivery log, newest first, optionally
+// filtered by ?status=pending|delivered|failed.
+func ListDeliveries(w http.ResponseWriter, r *http.Request) {
+	id := extractID(r.URL.Path, "/webhooks/")
+	if id == 0 {
+		http.Error(w, "Invalid webhook ID", http.StatusBadRequest)
+		return
+	}
+
+	query := internal.DB.Where("subscription_id = ?", id)
+	if status := r.URL.Query().Get("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	limit := 100
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 && l <= 1000 {
+		limit = l
+	}
+
+	var deliveries []internal.WebhookDelivery
+	if err := query.Order("id DESC").Limit(limit).Find(&deliveries).Error; err != nil {
+		http.Error(w, "Failed to fetch deliveries", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   deliveries,
+	})
+}
+
+// ReplayDelivery queues a new delivery with the same payload as an earlier
+// one, whatever its outcome.
+func ReplayDelivery(w http.ResponseWriter, r *http.Request) {
+	id := extractID(r.URL.Path, "/webhooks/")
+	deliveryID := extractID(r.URL.Path, "/webhooks/"+strconv.Itoa(id)+"/deliveries/")
+	if id == 0 || deliveryID == 0 {
+		http.Error(w, "Invalid webhook or delivery ID", http.StatusBadRequest)
+		return
+	}
+
+	var sub internal.WebhookSubscription
+	if err := internal.DB.First(&sub, id).Error; err != nil {
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+		return
+	}
+	if !sub.Active {
+		http.Error(w, "Webhook is disabled", http.StatusConflict)
+		return
+	}
+	var original internal.WebhookDelivery
+	if err := internal.DB.Where("id = ? AND subscription_id = ?", deliveryID, sub.ID).
+		First(&original).Error; err != nil {
+		http.Error(w, "Delivery not found", http.StatusNotFound)
+		return
+	}
+
+	replay := internal.WebhookDelivery{
+		SubscriptionID: sub.ID,
+		EventID:        original.EventID,
+		EventType:      original.EventType,
+		Payload:        original.Payload,
+		Status:         "pending",
+		ReplayOf:       &original.ID,
+		NextAttemptAt:  time.Now(),
+	}
+	if err := internal.DB.Create(&replay).Error; err != nil {
+		http.Error(w, "Failed to replay delivery", http.StatusInternalServerError)
+		return
+	}
+
+	internal.LogAudit("REPLAY", "WebhookDelivery", original.ID, "system", "Replayed webhook delivery")
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   replay,
+	})
+}
+
+func generateSecret() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func extractID(path, prefix string) int {
+	idStr := strings.TrimPrefix(path, prefix)
+	parts := strings.Split(idStr, "/")
+	id, _ := strconv.Atoi(parts[0])
+	return id
+}
@@ -0,0 +1,312 @@
+// Package webhooks delivers domain events to HTTP endpoints registered by
+// external systems. Events arrive from the outbox, are recorded as one
+// delivery per matching subscription and are POSTed with an HMAC signature,
+// retrying with exponential backoff.
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"myapp/internal"
+	"myapp/internal/websocket"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	batchSize = 20
+	// maxAttempts is how often a delivery is tried before it is marked failed.
+	maxAttempts = 8
+	baseBackoff = 30 * time.Second
+	maxBackoff  = time.Hour
+	// leaseTimeout keeps other instances off a batch while its requests are
+	// in flight.
+	leaseTimeout = 2 * time.Minute
+	// signatureTolerance is how old a signature Verify accepts.
+	signatureTolerance = 5 * time.Minute
+)
+
+// Headers sent with every delivery.
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderEventID   = "X-Webhook-ID"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+var (
+	httpClient  = &http.Client{Timeout: 10 * time.Second}
+	maxFailures = 20
+)
+
+// envelope is the JSON body of a delivery. ID is the outbox event ID, so
+// receivers can discard retries and replays they have already processed.
+type envelope struct {
+	ID        uint              `json:"id"`
+	Type      string            `json:"type"`
+	Version   int               `json:"version"`
+	Timestamp string            `json:"timestamp"`
+	Actor     string            `json:"actor"`
+	Payload   websocket.Payload `json:"payload"`
+}
+
+// Sink records a pending delivery of event for every active subscription
+// that wants it. It is registered with outbox.AddSink; the HTTP requests
+// are made by Start so a slow receiver never holds up the outbox.
+func Sink(id uint, event websocket.Event) error {
+	if err := event.Validate(); err != nil {
+		return err
+	}
+	body, err := json.Marshal(envelope{
+		ID:        id,
+		Type:      event.Type,
+		Version:   event.Version,
+		Timestamp: event.Timestamp,
+		Actor:     event.Actor,
+		Payload:   event.Payload,
+	})
+	if err != nil {
+		return err
+	}
+
+	return internal.DB.Transaction(func(tx *gorm.DB) error {
+		var subs []internal.WebhookSubscription
+		if err := tx.Where("active = ?", true).Find(&subs).Error; err != nil {
+			return err
+		}
+		for _, sub := range subs {
+			if !wants(sub, event.Type) {
+				continue
+			}
+			// The outbox redelivers after a crash; record each event once.
+			var existing int64
+			if err := tx.Model(&internal.WebhookDelivery{}).
+				Where("subscription_id = ? AND event_id = ? AND replay_of IS NULL", sub.ID, id).
+				Count(&existing).Error; err != nil {
+				return err
+			}
+			if existing > 0 {
+				continue
+			}
+			if err := tx.Create(&internal.WebhookDelivery{
+				SubscriptionID: sub.ID,
+				EventID:        id,
+				EventType:      event.Type,
+				Payload:        string(body),
+				Status:         "pending",
+				NextAttemptAt:  time.Now(),
+			}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// wants reports whether sub is subscribed to eventType.
+func wants(sub internal.WebhookSubscription, eventType string) bool {
+	return slices.Contains(sub.EventTypes, "*") || slices.Contains(sub.EventTypes, eventType)
+}
+
+// Start sends due deliveries every interval. Each request times out after
+// timeout, and a subscription is disabled after failures consecutive
+// failed attempts.
+func Start(interval, timeout time.Duration, failures int) {
+	httpClient = &http.Client{Timeout: timeout}
+	maxFailures = failures
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			for {
+				n, err := dispatch()
+				if err != nil {
+					log.Printf("Webhook dispatch failed: %v", err)
+				}
+				if n < batchSize {
+					break
+				}
+			}
+		}
+	}()
+}
+
+// dispatch leases a batch of due deliveries to active subscriptions and
+// attempts each of them.
+func dispatch() (int, error) {
+	var due []internal.WebhookDelivery
+	now := time.Now()
+	err := internal.DB.Transaction(func(tx *gorm.DB) error {
+		active := tx.Model(&internal.WebhookSubscription{}).Select("id").Where("active = ?", true)
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ? AND subscription_id IN (?)", "pending", now, active).
+			Order("id").Limit(batchSize).Find(&due).Error; err != nil {
+			return err
+		}
+		if len(due) == 0 {
+			return nil
+		}
+		ids := make([]uint, len(due))
+		for i, d := range due {
+			ids[i] = d.ID
+		}
+		return tx.Model(&internal.WebhookDelivery{}).Where("id IN ?", ids).
+			Update("next_attempt_at", now.Add(leaseTimeout)).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, d := range due {
+		if err := attempt(d); err != nil {
+			log.Printf("Webhook delivery %d could not be recorded: %v", d.ID, err)
+		}
+	}
+	return len(due), nil
+}
+
+// attempt sends d once and records the outcome on the delivery and its
+// subscription.
+func attempt(d internal.WebhookDelivery) error {
+	var sub internal.WebhookSubscription
+	if err := internal.DB.First(&sub, d.SubscriptionID).Error; err != nil {
+		return err
+	}
+	if !sub.Active {
+		return nil
+	}
+
+	status, sendErr := send(httpClient, sub.URL, sub.Secret, d)
+	now := time.Now()
+	updates := map[string]interface{}{
+		"attempts":        d.Attempts + 1,
+		"response_status": status,
+	}
+	if sendErr == nil {
+		updates["status"] = "delivered"
+		updates["delivered_at"] = now
+		updates["last_error"] = ""
+		if err := internal.DB.Model(&d).Updates(updates).Error; err != nil {
+			return err
+		}
+		return internal.DB.Model(&sub).Update("failure_count", 0).Error
+	}
+
+	log.Printf("Webhook delivery %d to %s attempt %d failed: %v", d.ID, sub.URL, d.Attempts+1, sendErr)
+	updates["last_error"] = sendErr.Error()
+	if d.Attempts+1 >= maxAttempts {
+		updates["status"] = "failed"
+	} else {
+		updates["next_attempt_at"] = now.Add(backoff(d.Attempts))
+	}
+	if err := internal.DB.Model(&d).Updates(updates).Error; err != nil {
+		return err
+	}
+
+	if err := internal.DB.Model(&sub).
+		UpdateColumn("failure_count", gorm.Expr("failure_count + 1")).Error; err != nil {
+		return err
+	}
+	if err := internal.DB.First(&sub, sub.ID).Error; err != nil {
+		return err
+	}
+	if sub.Active && sub.FailureCount >= maxFailures {
+		reason := fmt.Sprintf("%d consecutive failed deliveries; last error: %v", sub.FailureCount, sendErr)
+		if err := internal.DB.Model(&sub).Updates(map[string]interface{}{
+			"active":          false,
+			"disabled_at":     now,
+			"disabled_reason": reason,
+		}).Error; err != nil {
+			return err
+		}
+		log.Printf("Webhook %d disabled: %s", sub.ID, reason)
+		internal.LogAudit("DISABLE", "WebhookSubscription", sub.ID, "system", reason)
+	}
+	return nil
+}
+
+// backoff is the wait before retrying a delivery that has failed attempts
+// times already.
+func backoff(attempts int) time.Duration {
+	return min(baseBackoff<<min(attempts, 16), maxBackoff)
+}
+
+// send POSTs the delivery payload to url and returns the response status.
+// Any status outside 2xx is an error.
+func send(client *http.Client, url, secret string, d internal.WebhookDelivery) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(d.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "IMS-Webhooks/1.0")
+	req.Header.Set(HeaderEvent, d.EventType)
+	req.Header.Set(HeaderEventID, strconv.FormatUint(uint64(d.EventID), 10))
+	req.Header.Set(HeaderDelivery, strconv.FormatUint(uint64(d.ID), 10))
+	req.Header.Set(HeaderSignature, Sign(secret, time.Now(), []byte(d.Payload)))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("receiver returned %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// Sign returns the X-Webhook-Signature header for body sent at t:
+// "t=<unix seconds>,v1=<hex HMAC-SHA256 of "<t>.<body>">".
+func Sign(secret string, t time.Time, body []byte) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	return "t=" + ts + ",v1=" + signature(secret, ts, body)
+}
+
+// Verify checks a signature header produced by Sign. Signatures older than
+// five minutes are rejected to prevent replay by third parties.
+func Verify(secret, header string, body []byte) error {
+	var ts, sig string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			ts = value
+		case "v1":
+			sig = value
+		}
+	}
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || sig == "" {
+		return errors.New("malformed signature header")
+	}
+	if time.Since(time.Unix(unix, 0)).Abs() > signatureTolerance {
+		return errors.New("signature timestamp outside tolerance")
+	}
+	if !hmac.Equal([]byte(sig), []byte(signature(secret, ts, body))) {
+		return errors.New("signature mismatch")
+	}
+	return nil
+}
+
+func signature(secret, ts string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,151 @@
+package webhooks
+
+import (
+	"io"
+	"myapp/internal"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+func testDelivery() internal.WebhookDelivery {
+	return internal.WebhookDelivery{
+		ID:        7,
+		EventID:   42,
+		EventType: "low_stock_alert",
+		Payload:   `{"id":42,"type":"low_stock_alert","version":1,"payload":{"product_id":1}}`,
+	}
+}
+
+func TestSendSignsPayload(t *testing.T) {
+	received := make(chan *http.Request, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if err := Verify(testSecret, r.Header.Get(HeaderSignature), body); err != nil {
+			t.Errorf("Verify: %v", err)
+		}
+		if string(body) != testDelivery().Payload {
+			t.Errorf("body = %s", body)
+		}
+		received <- r
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	status, err := send(receiver.Client(), receiver.URL, testSecret, testDelivery())
+	if err != nil || status != http.StatusNoContent {
+		t.Fatalf("send = %d, %v", status, err)
+	}
+	r := <-received
+	for header, want := range map[string]string{
+		HeaderEvent:    "low_stock_alert",
+		HeaderEventID:  "42",
+		HeaderDelivery: "7",
+		"Content-Type": "application/json",
+	} {
+		if got := r.Header.Get(header); got != want {
+			t.Errorf("%s = %q, want %q", header, got, want)
+		}
+	}
+}
+
+func TestSendReportsFailures(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "boom", http.StatusInternalServerError)
+	}))
+	status, err := send(receiver.Client(), receiver.URL, testSecret, testDelivery())
+	if err == nil || status != http.StatusInternalServerError {
+		t.Errorf("send to failing receiver = %d, %v", status, err)
+	}
+
+	receiver.Close()
+	if status, err := send(receiver.Client(), receiver.URL, testSecret, testDelivery()); err == nil || status != 0 {
+		t.Errorf("send to closed receiver = %d, %v", status, err)
+	}
+
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer slow.Close()
+	client := slow.Client()
+	client.Timeout = 50 * time.Millisecond
+	if _, err := send(client, slow.URL, testSecret, testDelivery()); err == nil {
+		t.Error("send to slow receiver succeeded, want timeout")
+	}
+}
+
+func TestVerifyRejectsBadSignatures(t *testing.T) {
+	body := []byte(`{"id":1}`)
+	now := time.Now()
+	tests := []struct {
+		name   string
+		secret string
+		header string
+		body   []byte
+	}{
+		{"wrong secret", "another-secret-value", Sign(testSecret, now, body), body},
+		{"tampered body", testSecret, Sign(testSecret, now, body), []byte(`{"id":2}`)},
+		{"stale", testSecret, Sign(testSecret, now.Add(-time.Hour), body), body},
+		{"malformed", testSecret, "sha256=abc", body},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Verify(tt.secret, tt.header, tt.body); err == nil {
+				t.Error("Verify succeeded")
+			}
+		})
+	}
+	if err := Verify(testSecret, Sign(testSecret, now, body), body); err != nil {
+		t.Errorf("Verify valid signature: %v", err)
+	}
+}
+
+func TestBackoffGrowsToCap(t *testing.T) {
+	prev := time.Duration(0)
+	for attempts := 0; attempts < maxAttempts; attempts++ {
+		d := backoff(attempts)
+		if d < prev || d > maxBackoff {
+			t.Fatalf("backoff(%d) = %s after %s", attempts, d, prev)
+		}
+		prev = d
+	}
+	if backoff(0) != baseBackoff || backoff(100) != maxBackoff {
+		t.Errorf("backoff bounds = %s, %s", backoff(0), backoff(100))
+	}
+}
+
+func TestWants(t *testing.T) {
+	sub := internal.WebhookSubscription{EventTypes: []string{"inventory_update", "order_status_changed"}}
+	if !wants(sub, "order_status_changed") || wants(sub, "low_stock_alert") {
+		t.Errorf("wants(%v) wrong", sub.EventTypes)
+	}
+	sub.EventTypes = []string{"*"}
+	if !wants(sub, "purchase_order_received") {
+		t.Error("wildcard did not match")
+	}
+}
+
+func TestValidateRequest(t *testing.T) {
+	ptr := func(s string) *string { return &s }
+	types := func(t ...string) *[]string { return &t }
+	tests := []struct {
+		req  webhookRequest
+		want string
+	}{
+		{webhookRequest{URL: ptr("https://erp.example.com/hook"), EventTypes: types("low_stock_alert")}, ""},
+		{webhookRequest{URL: ptr("ftp://erp.example.com")}, "URL must"},
+		{webhookRequest{URL: ptr("/relative")}, "URL must"},
+		{webhookRequest{EventTypes: types("nope")}, "Unknown event type"},
+		{webhookRequest{EventTypes: types()}, "At least one"},
+		{webhookRequest{Secret: ptr("short")}, "Secret must"},
+	}
+	for _, tt := range tests {
+		if got := tt.req.validate(); !strings.HasPrefix(got, tt.want) || (tt.want == "") != (got == "") {
+			t.Errorf("validate(%+v) = %q, want %q", tt.req, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package websocket
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// authTimeout is how long a connection may stay open without
+// authenticating when no token was given on upgrade.
+const authTimeout = 10 * time.Second
+
+var (
+	errMalformedToken = errors.New("malformed token")
+	errBadSignature   = errors.New("invalid token signature")
+	errTokenExpired   = errors.New("token expired")
+)
+
+// Claims are the fields read from a WebSocket access token. Topics limits
+// which topics the holder may subscribe to; empty means all of them.
+type Claims struct {
+	Subject   string   `json:"sub"`
+	ExpiresAt int64    `json:"exp"`
+	Topics    []string `json:"topics,omitempty"`
+}
+
+func (c *Claims) Expiry() time.Time {
+	return time.Unix(c.ExpiresAt, 0)
+}
+
+// AllowedTopics returns the subset of topics the claims grant. The alerts
+// pseudo-topic is always granted; it only carries alerts from granted topics.
+func (c *Claims) AllowedTopics(topics []string) []string {
+	if len(c.Topics) == 0 {
+		return topics
+	}
+	var allowed []string
+	for _, t := range topics {
+		if t == TopicAlerts || slices.Contains(c.Topics, t) {
+			allowed = append(allowed, t)
+		}
+	}
+	return allowed
+}
+
+// security holds the origin allow-list and token secret, read from the
+// environment by ConfigureSecurity.
+var security struct {
+	origins []string // "*" allows any origin; empty allows same host only
+	secret  []byte   // empty disables token authentication
+}
+
+// ConfigureSecurity reads WS_ALLOWED_ORIGINS and WS_AUTH_SECRET. InitHub
+// calls it; commands that issue tokens without starting the hub call it
+// directly.
+func ConfigureSecurity() {
+	security.origins = nil
+	for _, o := range strings.Split(os.Getenv("WS_ALLOWED_ORIGINS"), ",") {
+		if o = strings.TrimSpace(o); o != "" {
+			security.origins = append(security.origins, strings.ToLower(strings.TrimSuffix(o, "/")))
+		}
+	}
+	security.secret = []byte(os.Getenv("WS_AUTH_SECRET"))
+	if len(security.secret) == 0 {
+		log.Println("⚠️  WS_AUTH_SECRET is not set: WebSocket connections are not authenticated")
+	}
+}
+
+func authEnabled() bool {
+	return len(security.secret) > 0
+}
+
+// checkOrigin allows requests without an Origin header (non-browser
+// clients), origins on the allow-list, and otherwise only the server's own
+// host.
+func checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if slices.Contains(security.origins, "*") || slices.Contains(security.origins, strings.ToLower(origin)) {
+		return true
+	}
+	if len(security.origins) > 0 {
+		return false
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+// tokenFromRequest reads a bearer token from the Authorization header or
+// the token query parameter (browsers cannot set headers on upgrade).
+func tokenFromRequest(r *http.Request) string {
+	if h := r.Header.Get("Authorization"); strings.HasPrefix(h, "Bearer ") {
+		return strings.TrimPrefix(h, "Bearer ")
+	}
+	return r.URL.Query().Get("token")
+}
+
+// IssueToken signs an HS256 JWT for the given subject and topics.
+func IssueToken(subject string, topics []string, ttl time.Duration) (string, error) {
+	if !authEnabled() {
+		return "", errors.New("WS_AUTH_SECRET is not set")
+	}
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+	claims, err := json.Marshal(Claims{
+		Subject:   subject,
+		ExpiresAt: time.Now().Add(ttl).Unix(),
+		Topics:    topics,
+	})
+	if err != nil {
+		return "", err
+	}
+	unsigned := header + "." + base64.RawURLEncoding.EncodeToString(claims)
+	return unsigned + "." + sign(unsigned), nil
+}
+
+// VerifyToken checks an HS256 JWT signed with WS_AUTH_SECRET and returns its
+// claims. Tokens must carry an expiry.
+func VerifyToken(token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errMalformedToken
+	}
+
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, errMalformedToken
+	}
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := json.Unmarshal(headerJSON, &header); err != nil || header.Alg != "HS256" {
+		return nil, errMalformedToken
+	}
+
+	expected := sign(parts[0] + "." + parts[1])
+	if !hmac.Equal([]byte(expected), []byte(parts[2])) {
+		return nil, errBadSignature
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, errMalformedToken
+	}
+	var claims Claims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, errMalformedToken
+	}
+	if claims.ExpiresAt == 0 || !time.Now().Before(claims.Expiry()) {
+		return nil, errTokenExpired
+	}
+	return &claims, nil
+}
+
+func sign(unsigned string) string {
+	mac := hmac.New(sha256.New, security.secret)
+	mac.Write([]byte(unsigned))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
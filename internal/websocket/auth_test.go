@@ -0,0 +1,241 @@
+package websocket
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// withSecurity sets the token secret and origin allow-list for one test.
+func withSecurity(t *testing.T, secret string, origins ...string) {
+	t.Helper()
+	saved := security
+	security.secret = []byte(secret)
+	security.origins = origins
+	t.Cleanup(func() { security = saved })
+}
+
+// rawToken assembles a JWT from header and claims JSON signed with secret.
+func rawToken(header, claims, secret string) string {
+	unsigned := base64.RawURLEncoding.EncodeToString([]byte(header)) + "." +
+		base64.RawURLEncoding.EncodeToString([]byte(claims))
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(unsigned))
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestIssuedTokenVerifies(t *testing.T) {
+	withSecurity(t, "s3cret")
+	token, err := IssueToken("alice", []string{TopicInventory}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := VerifyToken(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Subject != "alice" || !slices.Equal(claims.Topics, []string{TopicInventory}) {
+		t.Errorf("claims = %+v", claims)
+	}
+	if d := time.Until(claims.Expiry()); d < 59*time.Minute || d > time.Hour {
+		t.Errorf("expires in %v, want an hour", d)
+	}
+}
+
+func TestIssueTokenRequiresSecret(t *testing.T) {
+	withSecurity(t, "")
+	if _, err := IssueToken("alice", nil, time.Hour); err == nil {
+		t.Fatal("expected an error without WS_AUTH_SECRET")
+	}
+}
+
+func TestVerifyTokenRejects(t *testing.T) {
+	withSecurity(t, "s3cret")
+	hs256 := `{"alg":"HS256","typ":"JWT"}`
+	future := `{"sub":"alice","exp":4102444800}`
+	valid := rawToken(hs256, future, "s3cret")
+	parts := strings.Split(valid, ".")
+	tampered := parts[0] + "." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"mallory","exp":4102444800}`)) + "." + parts[2]
+
+	tests := []struct {
+		name  string
+		token string
+		want  error
+	}{
+		{"empty", "", errMalformedToken},
+		{"two segments", "a.b", errMalformedToken},
+		{"header not base64", "!!!." + parts[1] + "." + parts[2], errMalformedToken},
+		{"other algorithm", rawToken(`{"alg":"none"}`, future, "s3cret"), errMalformedToken},
+		{"wrong secret", rawToken(hs256, future, "other"), errBadSignature},
+		{"tampered claims", tampered, errBadSignature},
+		{"claims not JSON", rawToken(hs256, `not json`, "s3cret"), errMalformedToken},
+		{"expired", rawToken(hs256, `{"sub":"alice","exp":946684800}`, "s3cret"), errTokenExpired},
+		{"no expiry", rawToken(hs256, `{"sub":"alice"}`, "s3cret"), errTokenExpired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := VerifyToken(tt.token); err != tt.want {
+				t.Errorf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+	if _, err := VerifyToken(valid); err != nil {
+		t.Errorf("valid token rejected: %v", err)
+	}
+}
+
+func TestCheckOrigin(t *testing.T) {
+	tests := []struct {
+		name    string
+		origins []string
+		origin  string
+		want    bool
+	}{
+		{"no origin header", nil, "", true},
+		{"same host", nil, "https://ims.example.com", true},
+		{"other host", nil, "https://evil.example.com", false},
+		{"malformed origin", nil, "://", false},
+		{"allow-listed", []string{"https://dash.example.com"}, "https://DASH.example.com", true},
+		{"not allow-listed", []string{"https://dash.example.com"}, "https://evil.example.com", false},
+		{"allow-list replaces same host", []string{"https://dash.example.com"}, "https://ims.example.com", false},
+		{"wildcard", []string{"*"}, "https://evil.example.com", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withSecurity(t, "", tt.origins...)
+			r := httptest.NewRequest(http.MethodGet, "http://ims.example.com/ws/inventory", nil)
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			if got := checkOrigin(r); got != tt.want {
+				t.Errorf("checkOrigin = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigureSecurityNormalisesOrigins(t *testing.T) {
+	withSecurity(t, "")
+	t.Setenv("WS_ALLOWED_ORIGINS", " https://Dash.example.com/ ,,https://ops.example.com")
+	t.Setenv("WS_AUTH_SECRET", "s3cret")
+	ConfigureSecurity()
+	if !slices.Equal(security.origins, []string{"https://dash.example.com", "https://ops.example.com"}) {
+		t.Errorf("origins = %v", security.origins)
+	}
+	if !authEnabled() {
+		t.Error("auth not enabled with a secret")
+	}
+}
+
+func TestHandleWebSocketRejects(t *testing.T) {
+	withSecurity(t, "s3cret")
+	suppliersOnly, _ := IssueToken("bob", []string{TopicSuppliers}, time.Hour)
+	inventory, _ := IssueToken("bob", []string{TopicInventory}, time.Hour)
+
+	tests := []struct {
+		name   string
+		path   string
+		token  string
+		origin string
+		want   int
+	}{
+		{"invalid token", "/ws/inventory", "not-a-token", "", http.StatusUnauthorized},
+		{"expired token", "/ws/inventory", rawToken(`{"alg":"HS256"}`, `{"exp":946684800}`, "s3cret"), "", http.StatusUnauthorized},
+		{"topic not granted", "/ws/inventory", suppliersOnly, "", http.StatusForbidden},
+		{"foreign origin", "/ws/inventory", inventory, "https://evil.example.com", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "http://ims.example.com"+tt.path+"?token="+tt.token, nil)
+			r.Header.Set("Connection", "Upgrade")
+			r.Header.Set("Upgrade", "websocket")
+			r.Header.Set("Sec-WebSocket-Version", "13")
+			r.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			w := httptest.NewRecorder()
+			HandleWebSocket(w, r)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestTokenFromRequest(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/ws/inventory?token=from-query", nil)
+	if got := tokenFromRequest(r); got != "from-query" {
+		t.Errorf("query token = %q", got)
+	}
+	r.Header.Set("Authorization", "Bearer from-header")
+	if got := tokenFromRequest(r); got != "from-header" {
+		t.Errorf("header token = %q", got)
+	}
+}
+
+func TestAllowedTopics(t *testing.T) {
+	all := (&Claims{}).AllowedTopics([]string{TopicInventory, TopicOrders})
+	if !slices.Equal(all, []string{TopicInventory, TopicOrders}) {
+		t.Errorf("unrestricted claims allowed %v", all)
+	}
+	limited := (&Claims{Topics: []string{TopicOrders}}).AllowedTopics([]string{TopicInventory, TopicOrders, TopicAlerts})
+	if !slices.Equal(limited, []string{TopicOrders, TopicAlerts}) {
+		t.Errorf("restricted claims allowed %v", limited)
+	}
+}
+
+func TestAuthMessage(t *testing.T) {
+	withSecurity(t, "s3cret")
+	h := NewHub()
+
+	t.Run("other actions need authentication", func(t *testing.T) {
+		c := NewClient(h, nil, TopicInventory)
+		c.handleMessage([]byte(`{"action":"subscribe","topics":["orders"],"request_id":"r1"}`))
+		if frame := lastFrame(t, c); frame["type"] != "error" || frame["status"] != float64(http.StatusUnauthorized) {
+			t.Fatalf("frame = %v", frame)
+		}
+	})
+
+	t.Run("invalid token closes the connection", func(t *testing.T) {
+		c := NewClient(h, nil, TopicInventory)
+		c.handleMessage([]byte(`{"action":"auth","token":"not-a-token"}`))
+		if !c.outbox.isClosed() || c.authenticated.Load() {
+			t.Fatal("client still open after a bad token")
+		}
+	})
+
+	t.Run("token without the endpoint topic closes the connection", func(t *testing.T) {
+		token, _ := IssueToken("bob", []string{TopicSuppliers}, time.Hour)
+		c := NewClient(h, nil, TopicInventory)
+		c.handleMessage([]byte(`{"action":"auth","token":"` + token + `"}`))
+		if !c.outbox.isClosed() {
+			t.Fatal("client still open without a granted topic")
+		}
+	})
+
+	t.Run("valid token subscribes within the granted topics", func(t *testing.T) {
+		token, _ := IssueToken("bob", []string{TopicInventory}, time.Hour)
+		c := NewClient(h, nil, TopicInventory, TopicOrders)
+		c.handleMessage([]byte(`{"action":"auth","token":"` + token + `"}`))
+		defer c.expiry.Stop()
+		frame := lastFrame(t, c)
+		if frame["type"] != "authenticated" || frame["user"] != "bob" {
+			t.Fatalf("frame = %v", frame)
+		}
+		if got := c.subscription.Topics(); !slices.Equal(got, []string{TopicInventory}) {
+			t.Errorf("topics = %v", got)
+		}
+
+		c.handleMessage([]byte(`{"action":"auth","token":"` + token + `"}`))
+		if frame := lastFrame(t, c); frame["type"] != "error" || frame["status"] != float64(http.StatusBadRequest) {
+			t.Errorf("second auth frame = %v", frame)
+		}
+	})
+}
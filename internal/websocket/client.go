@@ -1,7 +1,11 @@
 package websocket
 
 import (
+	"encoding/json"
+	"errors"
 	"log"
+	"net/http"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -15,24 +19,101 @@ const (
 )
 
 type Client struct {
-	hub  *Hub
-	conn *websocket.Conn
-	send chan []byte
+	hub          *Hub
+	conn         *websocket.Conn
+	outbox       *queue // messages waiting for WritePump
+	subscription *Subscription
+
+	// Set once the client has presented a valid token (or auth is off).
+	authenticated atomic.Bool
+	user          string
+	// Topics implied by the endpoint, applied once authenticated
+	pathTopics []string
+	expiry     *time.Timer
+}
+
+// clientMessage is a request sent by a dashboard over the socket, e.g.
+// {"action":"filter","filter":{"warehouse_ids":[3],"alerts_only":true}}.
+type clientMessage struct {
+	Action       string          `json:"action"` // auth, subscribe, unsubscribe, filter, resume, command
+	RequestID    string          `json:"request_id,omitempty"`
+	Command      string          `json:"command,omitempty"`
+	Params       json.RawMessage `json:"params,omitempty"`
+	Token        string          `json:"token,omitempty"`
+	Topics       []string        `json:"topics,omitempty"`
+	Filter       *Filter         `json:"filter,omitempty"`
+	LastSequence uint64          `json:"last_sequence,omitempty"`
+	StreamID     string          `json:"stream_id,omitempty"`
 }
 
-func NewClient(hub *Hub, conn *websocket.Conn) *Client {
+// NewClient creates a client for an upgraded connection, or for a
+// Server-Sent Events stream when conn is nil. It receives nothing until
+// authenticate is called with the topics it may see.
+func NewClient(hub *Hub, conn *websocket.Conn, topics ...string) *Client {
 	return &Client{
-		hub:  hub,
-		conn: conn,
-		send: make(chan []byte, 256),
+		hub:          hub,
+		conn:         conn,
+		outbox:       newQueue(clientQueueSize),
+		subscription: NewSubscription(),
+		pathTopics:   topics,
+	}
+}
+
+// authenticate subscribes the client to its endpoint topics within what the
+// claims allow, and schedules the connection to close when the token
+// expires. nil claims mean authentication is disabled.
+func (c *Client) authenticate(claims *Claims) error {
+	if claims == nil {
+		c.subscription.Subscribe(c.pathTopics...)
+		c.authenticated.Store(true)
+		return nil
+	}
+
+	topics := claims.AllowedTopics(c.pathTopics)
+	if len(topics) == 0 {
+		return errors.New("not authorized for this endpoint")
+	}
+	c.subscription.Restrict(claims.AllowedTopics(allTopics))
+	c.subscription.Subscribe(topics...)
+	c.user = claims.Subject
+	c.authenticated.Store(true)
+	c.expiry = time.AfterFunc(time.Until(claims.Expiry()), func() {
+		c.closeWith(websocket.ClosePolicyViolation, "token expired")
+	})
+	return nil
+}
+
+// closeWith sends a close frame and closes the connection. It is safe to
+// call from any goroutine.
+func (c *Client) closeWith(code int, reason string) {
+	if c.conn == nil {
+		// Event stream clients end their response once removed
+		log.Printf("Closing event stream: %s", reason)
+		c.hub.remove(c)
+		return
 	}
+	c.conn.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(code, reason), time.Now().Add(writeWait))
+	c.conn.Close()
 }
+
 func (c *Client) ReadPump() {
 	defer func() {
-		c.hub.unregister <- c
+		if c.expiry != nil {
+			c.expiry.Stop()
+		}
+		c.hub.remove(c)
 		c.conn.Close()
 	}()
 
+	if !c.authenticated.Load() {
+		time.AfterFunc(authTimeout, func() {
+			if !c.authenticated.Load() {
+				c.closeWith(websocket.ClosePolicyViolation, "authentication required")
+			}
+		})
+	}
+
 	c.conn.SetReadLimit(maxMessageSize)
 	c.conn.SetReadDeadline(time.Now().Add(pongWait))
 	c.conn.SetPongHandler(func(string) error {
@@ -48,9 +129,100 @@ func (c *Client) ReadPump() {
 			}
 			break
 		}
-		log.Printf("Received message from client: %s", message)
+		c.handleMessage(message)
 	}
 }
+
+// handleMessage applies a subscription change or runs a command. Every
+// reply echoes the request_id the client sent, if any.
+func (c *Client) handleMessage(data []byte) {
+	var msg clientMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		c.replyError(msg, http.StatusBadRequest, "invalid message")
+		return
+	}
+
+	if msg.Action == "auth" {
+		if c.authenticated.Load() {
+			c.replyError(msg, http.StatusBadRequest, "already authenticated")
+			return
+		}
+		claims, err := VerifyToken(msg.Token)
+		if err == nil {
+			err = c.authenticate(claims)
+		}
+		if err != nil {
+			c.closeWith(websocket.ClosePolicyViolation, err.Error())
+			return
+		}
+		c.reply(msg, map[string]interface{}{
+			"type":       "authenticated",
+			"user":       c.user,
+			"topics":     c.subscription.Topics(),
+			"expires_at": claims.Expiry().Format(time.RFC3339),
+		})
+		return
+	}
+	if !c.authenticated.Load() {
+		c.replyError(msg, http.StatusUnauthorized, "authentication required")
+		return
+	}
+
+	switch msg.Action {
+	case "subscribe":
+		c.subscription.Subscribe(msg.Topics...)
+	case "unsubscribe":
+		c.subscription.Unsubscribe(msg.Topics...)
+	case "filter":
+		if msg.Filter == nil {
+			msg.Filter = &Filter{}
+		}
+		c.subscription.SetFilter(*msg.Filter)
+	case "resume":
+		c.hub.requestReplay(c, msg.LastSequence, msg.StreamID)
+		return
+	case "command":
+		c.runCommand(msg)
+		return
+	default:
+		c.replyError(msg, http.StatusBadRequest, "unknown action: "+msg.Action)
+		return
+	}
+
+	c.reply(msg, map[string]interface{}{
+		"type":      "subscription",
+		"topics":    c.subscription.Topics(),
+		"filter":    c.subscription.Filter(),
+		"timestamp": getCurrentTimestamp(),
+	})
+}
+
+// reply sends message to the client, tagged with the request's request_id.
+func (c *Client) reply(req clientMessage, message map[string]interface{}) {
+	if req.RequestID != "" {
+		message["request_id"] = req.RequestID
+	}
+	data, err := json.Marshal(message)
+	if err != nil {
+		log.Printf("Error marshaling client reply: %v", err)
+		return
+	}
+	c.hub.sendTo(c, data)
+}
+
+func (c *Client) replyError(req clientMessage, status int, message string) {
+	frame := map[string]interface{}{
+		"type":      "error",
+		"status":    status,
+		"message":   message,
+		"timestamp": getCurrentTimestamp(),
+	}
+	if req.Command != "" {
+		frame["command"] = req.Command
+	}
+	c.reply(req, frame)
+}
+
 func (c *Client) WritePump() {
 	ticker := time.NewTicker(pingPeriod)
 	defer func() {
@@ -60,22 +232,26 @@ func (c *Client) WritePump() {
 
 	for {
 		select {
-		case message, ok := <-c.send:
+		case <-c.outbox.ready:
+			messages, closed := c.outbox.drain()
 			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
-			if !ok {
+			if closed {
 				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
 				return
 			}
+			if len(messages) == 0 {
+				continue
+			}
 
 			w, err := c.conn.NextWriter(websocket.TextMessage)
 			if err != nil {
 				return
 			}
-			w.Write(message)
-			n := len(c.send)
-			for i := 0; i < n; i++ {
-				w.Write([]byte{'\n'})
-				w.Write(<-c.send)
+			for i, message := range messages {
+				if i > 0 {
+					w.Write([]byte{'\n'})
+				}
+				w.Write(message.Data)
 			}
 
 			if err := w.Close(); err != nil {
@@ -0,0 +1,92 @@
+package websocket
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+)
+
+// CommandHandler runs a command sent over the socket on behalf of actor and
+// returns the data for the command_result frame.
+type CommandHandler func(actor string, params json.RawMessage) (interface{}, error)
+
+// Command is a request dashboards can send with {"action":"command"}.
+// Callers must be authorized for Topic.
+type Command struct {
+	Topic   string
+	Handler CommandHandler
+}
+
+// CommandError is a failure reported back to the client with an HTTP-style
+// status. Any other error is reported as a 500.
+type CommandError struct {
+	Status  int
+	Message string
+}
+
+func (e *CommandError) Error() string {
+	return e.Message
+}
+
+var (
+	commandsMu sync.RWMutex
+	commands   = make(map[string]Command)
+)
+
+// RegisterCommand makes a command available to WebSocket clients. Packages
+// that own the data register their commands from main, which keeps this
+// package free of imports on them.
+func RegisterCommand(name string, cmd Command) {
+	commandsMu.Lock()
+	defer commandsMu.Unlock()
+	commands[name] = cmd
+}
+
+func lookupCommand(name string) (Command, bool) {
+	commandsMu.RLock()
+	defer commandsMu.RUnlock()
+	cmd, ok := commands[name]
+	return cmd, ok
+}
+
+// runCommand executes msg.Command and replies with a command_result frame
+// or an error frame carrying the same request_id.
+func (c *Client) runCommand(msg clientMessage) {
+	cmd, ok := lookupCommand(msg.Command)
+	if !ok {
+		c.replyError(msg, http.StatusNotFound, "unknown command: "+msg.Command)
+		return
+	}
+	if !c.subscription.Authorized(cmd.Topic) {
+		c.replyError(msg, http.StatusForbidden, "not authorized for "+cmd.Topic)
+		return
+	}
+
+	actor := c.user
+	if actor == "" {
+		actor = "system"
+	}
+	params := msg.Params
+	if len(params) == 0 {
+		params = json.RawMessage("{}")
+	}
+
+	data, err := cmd.Handler(actor, params)
+	if err != nil {
+		if ce, ok := err.(*CommandError); ok {
+			c.replyError(msg, ce.Status, ce.Message)
+			return
+		}
+		log.Printf("WebSocket command %s failed: %v", msg.Command, err)
+		c.replyError(msg, http.StatusInternalServerError, "command failed")
+		return
+	}
+
+	c.reply(msg, map[string]interface{}{
+		"type":      "command_result",
+		"command":   msg.Command,
+		"data":      data,
+		"timestamp": getCurrentTimestamp(),
+	})
+}
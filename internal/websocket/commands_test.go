@@ -0,0 +1,85 @@
+package websocket
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+// lastFrame decodes the most recent frame queued for c.
+func lastFrame(t *testing.T, c *Client) map[string]interface{} {
+	t.Helper()
+	items, _ := c.outbox.drain()
+	if len(items) == 0 {
+		t.Fatal("no frame queued")
+	}
+	var frame map[string]interface{}
+	if err := json.Unmarshal(items[len(items)-1].Data, &frame); err != nil {
+		t.Fatal(err)
+	}
+	return frame
+}
+
+func TestCommandRepliesCarryRequestID(t *testing.T) {
+	RegisterCommand("test_echo", Command{Topic: TopicProducts, Handler: func(actor string, params json.RawMessage) (interface{}, error) {
+		var p struct {
+			Fail string `json:"fail"`
+		}
+		json.Unmarshal(params, &p)
+		switch p.Fail {
+		case "validation":
+			return nil, &CommandError{Status: http.StatusBadRequest, Message: "bad input"}
+		case "internal":
+			return nil, errors.New("database down")
+		}
+		return map[string]string{"actor": actor}, nil
+	}})
+
+	h := NewHub()
+	c := newTestClient(h)
+	c.user = "alice"
+
+	tests := []struct {
+		name    string
+		message string
+		typ     string
+		status  float64
+	}{
+		{"success", `{"action":"command","command":"test_echo","request_id":"r1"}`, "command_result", 0},
+		{"validation error", `{"action":"command","command":"test_echo","request_id":"r1","params":{"fail":"validation"}}`, "error", 400},
+		{"internal error", `{"action":"command","command":"test_echo","request_id":"r1","params":{"fail":"internal"}}`, "error", 500},
+		{"unknown command", `{"action":"command","command":"nope","request_id":"r1"}`, "error", 404},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c.handleMessage([]byte(tt.message))
+			frame := lastFrame(t, c)
+			if frame["type"] != tt.typ || frame["request_id"] != "r1" {
+				t.Fatalf("frame = %v", frame)
+			}
+			if tt.status != 0 && frame["status"] != tt.status {
+				t.Errorf("status = %v, want %v", frame["status"], tt.status)
+			}
+		})
+	}
+
+	c.handleMessage([]byte(`{"action":"command","command":"test_echo"}`))
+	data := lastFrame(t, c)["data"].(map[string]interface{})
+	if data["actor"] != "alice" {
+		t.Errorf("actor = %v, want alice", data["actor"])
+	}
+}
+
+func TestCommandRequiresTopicAuthorization(t *testing.T) {
+	RegisterCommand("test_noop", Command{Topic: TopicSuppliers, Handler: func(string, json.RawMessage) (interface{}, error) {
+		return nil, nil
+	}})
+
+	c := newTestClient(NewHub(), TopicInventory)
+	c.subscription.Restrict([]string{TopicInventory})
+	c.handleMessage([]byte(`{"action":"command","command":"test_noop","request_id":"r2"}`))
+	if frame := lastFrame(t, c); frame["status"] != float64(http.StatusForbidden) {
+		t.Errorf("frame = %v, want 403 error", frame)
+	}
+}
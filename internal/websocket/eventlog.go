@@ -0,0 +1,132 @@
+package websocket
+
+import (
+	"myapp/internal"
+	"sync"
+	"time"
+)
+
+const (
+	defaultEventLogSize = 1000
+	// maxReplay caps how many events a resuming client is sent before it is
+	// told to resync from the REST API instead.
+	maxReplay = 1000
+)
+
+// eventLog keeps the most recent messages in a ring buffer so reconnecting
+// clients can catch up. Publishers append and Hub.Run reads, so it is
+// guarded by mu.
+type eventLog struct {
+	mu     sync.Mutex
+	events []*Message
+	next   int
+	full   bool
+}
+
+func newEventLog(size int) *eventLog {
+	if size <= 0 {
+		size = defaultEventLogSize
+	}
+	return &eventLog{events: make([]*Message, size)}
+}
+
+func (l *eventLog) append(msg *Message) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events[l.next] = msg
+	l.next = (l.next + 1) % len(l.events)
+	if l.next == 0 {
+		l.full = true
+	}
+}
+
+// oldest returns the sequence of the oldest buffered message, or 0 when empty.
+func (l *eventLog) oldest() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.full {
+		return l.events[l.next].Sequence
+	}
+	if l.next == 0 {
+		return 0
+	}
+	return l.events[0].Sequence
+}
+
+// between returns buffered messages with a sequence greater than after and
+// no greater than through, oldest first.
+func (l *eventLog) between(after, through uint64) []*Message {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var out []*Message
+	n := len(l.events)
+	start := 0
+	count := l.next
+	if l.full {
+		start = l.next
+		count = n
+	}
+	for i := 0; i < count; i++ {
+		msg := l.events[(start+i)%n]
+		if msg.Sequence > after && msg.Sequence <= through {
+			out = append(out, msg)
+		}
+	}
+	return out
+}
+
+// pgEventStore persists every message so clients can resume across restarts
+// and beyond the in-memory buffer.
+type pgEventStore struct{}
+
+func (pgEventStore) latest() (uint64, error) {
+	var seq uint64
+	err := internal.DB.Model(&internal.WSEvent{}).
+		Select("COALESCE(MAX(sequence), 0)").Scan(&seq).Error
+	return seq, err
+}
+
+func (pgEventStore) save(msg *Message) error {
+	return internal.DB.Create(newWSEvent(msg)).Error
+}
+
+func newWSEvent(msg *Message) *internal.WSEvent {
+	return &internal.WSEvent{
+		Sequence:    msg.Sequence,
+		Topic:       msg.Topic,
+		Alert:       msg.Alert,
+		WarehouseID: msg.WarehouseID,
+		ProductID:   msg.ProductID,
+		SupplierID:  msg.SupplierID,
+		Data:        string(msg.Data),
+		CreatedAt:   time.Now(),
+	}
+}
+
+// between returns stored messages with after < sequence < before.
+func (pgEventStore) between(after, before uint64, limit int) ([]*Message, error) {
+	var rows []internal.WSEvent
+	if err := internal.DB.Where("sequence > ? AND sequence < ?", after, before).
+		Order("sequence").Limit(limit).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	out := make([]*Message, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, &Message{
+			Sequence:    row.Sequence,
+			Topic:       row.Topic,
+			Alert:       row.Alert,
+			WarehouseID: row.WarehouseID,
+			ProductID:   row.ProductID,
+			SupplierID:  row.SupplierID,
+			Data:        []byte(row.Data),
+		})
+	}
+	return out, nil
+}
+
+// prune drops stored events older than the retention period.
+func (pgEventStore) prune(retention time.Duration) error {
+	return internal.DB.Where("created_at < ?", time.Now().Add(-retention)).
+		Delete(&internal.WSEvent{}).Error
+}
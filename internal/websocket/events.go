@@ -0,0 +1,280 @@
+package websocket
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// Event is the envelope every hub message is sent in. Type and Version
+// identify the payload schema; Publish fills them in together with
+// Sequence, Timestamp and a default Actor.
+type Event struct {
+	Type      string  `json:"type"`
+	Version   int     `json:"version"`
+	Sequence  uint64  `json:"sequence"`
+	Timestamp string  `json:"timestamp"`
+	Actor     string  `json:"actor"`
+	Payload   Payload `json:"payload"`
+}
+
+// Payload is the body of an event. Adding an event type means adding a
+// payload struct below and an entry in eventTypes; the hub routes it from
+// its Route.
+type Payload interface {
+	EventType() string
+	Route() Route
+}
+
+// Route holds the attributes the hub filters and coalesces messages on.
+type Route struct {
+	Alert       bool
+	WarehouseID uint
+	ProductID   uint
+	SupplierID  uint
+	CoalesceKey string
+}
+
+// eventType registers a payload with its topic and schema version. Bump
+// Version whenever a payload field is renamed or removed.
+type eventType struct {
+	Topic       string
+	Version     int
+	Description string
+	Payload     Payload
+}
+
+var eventTypes = []eventType{
+	{TopicInventory, 1, "Inventory quantity changed", InventoryUpdated{}},
+	{TopicInventory, 1, "Inventory at or below its minimum stock", LowStockAlert{}},
+	{TopicInventory, 1, "A dashboard user acknowledged an alert", AlertAcknowledged{}},
+	{TopicWarehouses, 1, "Warehouse created, updated or deleted", WarehouseUpdated{}},
+	{TopicWarehouses, 1, "Warehouse utilization crossed its threshold", WarehouseCapacityAlert{}},
+	{TopicProducts, 1, "Product created, updated or deleted", ProductUpdated{}},
+	{TopicProducts, 1, "Product price changed by more than 10%", ProductPriceAlert{}},
+	{TopicSuppliers, 1, "Supplier created, updated or deleted", SupplierUpdated{}},
+	{TopicSuppliers, 1, "Supplier status changed or rating fell below the threshold", SupplierStatusAlert{}},
+	{TopicOrders, 1, "Customer order status changed", OrderStatusChanged{}},
+	{TopicOrders, 1, "Purchase order received into a warehouse", PurchaseOrderReceived{}},
+}
+
+func lookupEventType(name string) (eventType, bool) {
+	for _, t := range eventTypes {
+		if t.Payload.EventType() == name {
+			return t, true
+		}
+	}
+	return eventType{}, false
+}
+
+// IsEventType reports whether name is a registered event type.
+func IsEventType(name string) bool {
+	_, ok := lookupEventType(name)
+	return ok
+}
+
+// DecodePayload rebuilds a typed payload from its event type and JSON.
+func DecodePayload(name string, data []byte) (Payload, error) {
+	t, ok := lookupEventType(name)
+	if !ok {
+		return nil, fmt.Errorf("unregistered event type %q", name)
+	}
+	ptr := reflect.New(reflect.TypeOf(t.Payload))
+	if err := json.Unmarshal(data, ptr.Interface()); err != nil {
+		return nil, err
+	}
+	return ptr.Elem().Interface().(Payload), nil
+}
+
+// Validate reports whether the event can be published and fills in the
+// envelope fields derived from the payload.
+func (e *Event) Validate() error {
+	_, err := e.prepare()
+	return err
+}
+
+// prepare fills in the envelope fields derived from the payload.
+func (e *Event) prepare() (eventType, error) {
+	if e.Payload == nil {
+		return eventType{}, fmt.Errorf("event has no payload")
+	}
+	t, ok := lookupEventType(e.Payload.EventType())
+	if !ok {
+		return eventType{}, fmt.Errorf("unregistered event type %q", e.Payload.EventType())
+	}
+	e.Type = e.Payload.EventType()
+	e.Version = t.Version
+	if e.Timestamp == "" {
+		e.Timestamp = time.Now().Format(time.RFC3339)
+	}
+	if e.Actor == "" {
+		e.Actor = "system"
+	}
+	return t, nil
+}
+
+// InventoryUpdated is sent when an inventory record is created, adjusted,
+// updated or deleted.
+type InventoryUpdated struct {
+	InventoryID uint   `json:"inventory_id"`
+	ProductID   uint   `json:"product_id"`
+	WarehouseID uint   `json:"warehouse_id"`
+	Quantity    int    `json:"quantity"`
+	Action      string `json:"action" enum:"created,updated,deleted,adjusted,allocated"`
+}
+
+func (InventoryUpdated) EventType() string { return "inventory_update" }
+func (p InventoryUpdated) Route() Route {
+	return Route{
+		WarehouseID: p.WarehouseID,
+		ProductID:   p.ProductID,
+		CoalesceKey: fmt.Sprintf("inventory:%d:%d", p.ProductID, p.WarehouseID),
+	}
+}
+
+type LowStockAlert struct {
+	ProductID       uint   `json:"product_id"`
+	ProductName     string `json:"product_name"`
+	WarehouseID     uint   `json:"warehouse_id"`
+	CurrentQuantity int    `json:"current_quantity"`
+	MinStock        int    `json:"min_stock"`
+}
+
+func (LowStockAlert) EventType() string { return "low_stock_alert" }
+func (p LowStockAlert) Route() Route {
+	return Route{Alert: true, WarehouseID: p.WarehouseID, ProductID: p.ProductID}
+}
+
+type AlertAcknowledged struct {
+	AlertType      string `json:"alert_type" enum:"low_stock_alert"`
+	ProductID      uint   `json:"product_id"`
+	WarehouseID    uint   `json:"warehouse_id"`
+	AcknowledgedBy string `json:"acknowledged_by"`
+	Note           string `json:"note"`
+}
+
+func (AlertAcknowledged) EventType() string { return "alert_acknowledged" }
+func (p AlertAcknowledged) Route() Route {
+	return Route{Alert: true, WarehouseID: p.WarehouseID, ProductID: p.ProductID}
+}
+
+type WarehouseUpdated struct {
+	WarehouseID   uint   `json:"warehouse_id"`
+	WarehouseName string `json:"warehouse_name"`
+	Location      string `json:"location"`
+	Capacity      int    `json:"capacity"`
+	Action        string `json:"action" enum:"created,updated,deleted"`
+}
+
+func (WarehouseUpdated) EventType() string { return "warehouse_update" }
+func (p WarehouseUpdated) Route() Route {
+	return Route{
+		WarehouseID: p.WarehouseID,
+		CoalesceKey: fmt.Sprintf("warehouse:%d", p.WarehouseID),
+	}
+}
+
+type WarehouseCapacityAlert struct {
+	WarehouseID        uint    `json:"warehouse_id"`
+	WarehouseName      string  `json:"warehouse_name"`
+	CurrentStock       int     `json:"current_stock"`
+	Capacity           int     `json:"capacity"`
+	UtilizationPercent float64 `json:"utilization_percent"`
+}
+
+func (WarehouseCapacityAlert) EventType() string { return "warehouse_capacity_alert" }
+func (p WarehouseCapacityAlert) Route() Route {
+	return Route{Alert: true, WarehouseID: p.WarehouseID}
+}
+
+type ProductUpdated struct {
+	ProductID   uint    `json:"product_id"`
+	ProductName string  `json:"product_name"`
+	SKU         string  `json:"sku"`
+	Category    string  `json:"category"`
+	Price       float64 `json:"price"`
+	Action      string  `json:"action" enum:"created,updated,deleted"`
+}
+
+func (ProductUpdated) EventType() string { return "product_update" }
+func (p ProductUpdated) Route() Route {
+	return Route{
+		ProductID:   p.ProductID,
+		CoalesceKey: fmt.Sprintf("product:%d", p.ProductID),
+	}
+}
+
+type ProductPriceAlert struct {
+	ProductID     uint    `json:"product_id"`
+	ProductName   string  `json:"product_name"`
+	OldPrice      float64 `json:"old_price"`
+	NewPrice      float64 `json:"new_price"`
+	ChangePercent float64 `json:"change_percent"`
+}
+
+func (ProductPriceAlert) EventType() string { return "product_price_alert" }
+func (p ProductPriceAlert) Route() Route {
+	return Route{Alert: true, ProductID: p.ProductID}
+}
+
+type SupplierUpdated struct {
+	SupplierID   uint   `json:"supplier_id"`
+	SupplierName string `json:"supplier_name"`
+	Email        string `json:"email"`
+	Phone        string `json:"phone"`
+	Address      string `json:"address"`
+	Action       string `json:"action" enum:"created,updated,deleted"`
+}
+
+func (SupplierUpdated) EventType() string { return "supplier_update" }
+func (p SupplierUpdated) Route() Route {
+	return Route{
+		SupplierID:  p.SupplierID,
+		CoalesceKey: fmt.Sprintf("supplier:%d", p.SupplierID),
+	}
+}
+
+type SupplierStatusAlert struct {
+	SupplierID   uint   `json:"supplier_id"`
+	SupplierName string `json:"supplier_name"`
+	Status       string `json:"status" enum:"active,inactive,suspended,warning"`
+	Message      string `json:"message"`
+}
+
+func (SupplierStatusAlert) EventType() string { return "supplier_status_alert" }
+func (p SupplierStatusAlert) Route() Route {
+	return Route{Alert: true, SupplierID: p.SupplierID}
+}
+
+type OrderStatusChanged struct {
+	OrderID     uint   `json:"order_id"`
+	OrderNumber string `json:"order_number"`
+	OldStatus   string `json:"old_status"`
+	Status      string `json:"status"`
+}
+
+func (OrderStatusChanged) EventType() string { return "order_status_changed" }
+func (p OrderStatusChanged) Route() Route {
+	return Route{CoalesceKey: fmt.Sprintf("order:%d", p.OrderID)}
+}
+
+type PurchaseOrderReceived struct {
+	PurchaseOrderID uint                `json:"purchase_order_id"`
+	PONumber        string              `json:"po_number"`
+	SupplierID      uint                `json:"supplier_id"`
+	WarehouseID     uint                `json:"warehouse_id"`
+	Items           []ReceivedOrderLine `json:"items"`
+}
+
+// ReceivedOrderLine is one purchase order line as received.
+type ReceivedOrderLine struct {
+	ProductID        uint `json:"product_id"`
+	ReceivedQuantity int  `json:"received_quantity"`
+	RejectedQuantity int  `json:"rejected_quantity"`
+}
+
+func (PurchaseOrderReceived) EventType() string { return "purchase_order_received" }
+func (p PurchaseOrderReceived) Route() Route {
+	return Route{SupplierID: p.SupplierID, WarehouseID: p.WarehouseID}
+}
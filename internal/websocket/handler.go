@@ -1,8 +1,13 @@
 package websocket
 
 import (
+	"encoding/json"
 	"log"
 	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/gorilla/websocket"
 )
@@ -10,29 +15,98 @@ import (
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
-	CheckOrigin: func(r *http.Request) bool {
-		return true
-	},
+	CheckOrigin:     checkOrigin,
 }
 var GlobalHub *Hub
 
 func InitHub() {
+	ConfigureSecurity()
 	GlobalHub = NewHub()
+	// e.g. WS_TOPIC_POLICIES=inventory=coalesce,suppliers=drop
+	for _, entry := range strings.Split(os.Getenv("WS_TOPIC_POLICIES"), ",") {
+		topic, value, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok {
+			continue
+		}
+		policy, err := ParsePolicy(value)
+		if err != nil || !slices.Contains(allTopics, topic) {
+			log.Printf("Ignoring WebSocket topic policy %q", entry)
+			continue
+		}
+		GlobalHub.SetPolicy(topic, policy)
+	}
+	if os.Getenv("WS_EVENT_LOG_PERSIST") == "true" {
+		retention := 24 * time.Hour
+		if v := os.Getenv("WS_EVENT_LOG_RETENTION"); v != "" {
+			if d, err := time.ParseDuration(v); err == nil {
+				retention = d
+			}
+		}
+		if err := GlobalHub.UsePostgresEventLog(retention); err != nil {
+			log.Printf("Postgres event log disabled: %v", err)
+		}
+	}
+	if os.Getenv("WS_RELAY") == "postgres" {
+		if err := GlobalHub.UsePostgresRelay(); err != nil {
+			log.Fatalf("Failed to start Postgres WebSocket relay: %v", err)
+		}
+	}
 	go GlobalHub.Run()
 	log.Println("WebSocket hub initialized and running")
 }
+
+// HandleWebSocket upgrades the request and subscribes the connection to the
+// topic implied by its path. When authentication is enabled the token may
+// be given on upgrade or in a first {"action":"auth"} message.
 func HandleWebSocket(w http.ResponseWriter, r *http.Request) {
+	topics := topicsForPath(r.URL.Path)
+
+	var claims *Claims
+	if authEnabled() {
+		if token := tokenFromRequest(r); token != "" {
+			c, err := VerifyToken(token)
+			if err != nil {
+				http.Error(w, "Invalid token", http.StatusUnauthorized)
+				return
+			}
+			if len(c.AllowedTopics(topics)) == 0 {
+				http.Error(w, "Not authorized for this endpoint", http.StatusForbidden)
+				return
+			}
+			claims = c
+		}
+	}
+
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Printf("WebSocket upgrade error: %v", err)
 		return
 	}
 
-	client := NewClient(GlobalHub, conn)
+	client := NewClient(GlobalHub, conn, topics...)
+	if !authEnabled() || claims != nil {
+		client.authenticate(claims)
+	}
 	GlobalHub.register <- client
 	go client.WritePump()
 	go client.ReadPump()
 }
+
+// GetStats reports connected clients and per-topic delivery counters,
+// including messages dropped or coalesced because a consumer fell behind.
+func GetStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   GlobalHub.Stats(),
+	})
+}
+
 func GetHub() *Hub {
 	return GlobalHub
 }
@@ -1,237 +1,347 @@
 package websocket
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"log"
+	"os"
+	"strconv"
 	"sync"
 	"time"
 )
 
 type Hub struct {
-	clients    map[*Client]bool
-	broadcast  chan []byte
-	register   chan *Client
-	unregister chan *Client
-	mu         sync.RWMutex
+	// clients is only written by Run and remove; mu lets other goroutines
+	// read it while Run routes messages.
+	clients  map[*Client]bool
+	mu       sync.RWMutex
+	incoming *queue // published messages waiting for Run
+	resume   chan resumeRequest
+	register chan *Client
+	policies map[string]Policy // per topic; set before Run
+	metrics  *metrics
+
+	// streamID identifies the sequence space so clients can tell when the
+	// numbering restarted.
+	streamID string
+	seqMu    sync.Mutex
+	sequence uint64        // last sequence handed out by publish
+	lastSeen uint64        // last sequence delivered by Run
+	events   *eventLog     // every sequenced message, including dropped ones
+	store    *pgEventStore // optional persistent event log
+	relay    *pgRelay      // optional multi-instance fan-out
+}
+
+// resumeRequest asks for the events a client missed after lastSequence.
+type resumeRequest struct {
+	client       *Client
+	lastSequence uint64
+	streamID     string
 }
 
 func NewHub() *Hub {
+	size, _ := strconv.Atoi(os.Getenv("WS_EVENT_LOG_SIZE"))
 	return &Hub{
-		broadcast:  make(chan []byte, 256),
-		register:   make(chan *Client),
-		unregister: make(chan *Client),
-		clients:    make(map[*Client]bool),
+		incoming: newQueue(hubBacklogSize),
+		resume:   make(chan resumeRequest),
+		register: make(chan *Client),
+		clients:  make(map[*Client]bool),
+		policies: map[string]Policy{TopicInventory: PolicyCoalesce},
+		metrics:  newMetrics(),
+		streamID: newStreamID(),
+		events:   newEventLog(size),
+	}
+}
+
+// SetPolicy sets how a topic's messages are handled when the hub or a
+// client falls behind. It must be called before Run.
+func (h *Hub) SetPolicy(topic string, policy Policy) {
+	h.policies[topic] = policy
+}
+
+func (h *Hub) policy(topic string) Policy {
+	return h.policies[topic]
+}
+
+// UsePostgresEventLog persists published events so sequence numbers survive
+// restarts and clients can resume past the in-memory buffer. It must be
+// called before Run.
+func (h *Hub) UsePostgresEventLog(retention time.Duration) error {
+	store := &pgEventStore{}
+	latest, err := store.latest()
+	if err != nil {
+		return err
 	}
+	h.store = store
+	h.streamID = "postgres"
+	h.sequence = latest
+	h.lastSeen = latest
+
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := store.prune(retention); err != nil {
+				log.Printf("Failed to prune WebSocket event log: %v", err)
+			}
+		}
+	}()
+	return nil
 }
+
+// Run registers clients and routes published messages to their queues. It
+// never blocks on a client: a full client queue drops or coalesces
+// according to the topic's policy.
 func (h *Hub) Run() {
 	for {
 		select {
 		case client := <-h.register:
 			h.mu.Lock()
-			h.clients[client] = true
-			h.mu.Unlock()
-			log.Printf("Client connected. Total clients: %d", len(h.clients))
-
-		case client := <-h.unregister:
-			h.mu.Lock()
-			if _, ok := h.clients[client]; ok {
-				delete(h.clients, client)
-				close(client.send)
-				log.Printf("Client disconnected. Total clients: %d", len(h.clients))
+			if client.outbox.isClosed() {
+				// Removed before Run got to it
+				h.mu.Unlock()
+				continue
 			}
+			h.clients[client] = true
+			count := len(h.clients)
 			h.mu.Unlock()
+			log.Printf("Client connected. Total clients: %d", count)
+			h.deliver(client, h.frame(map[string]interface{}{
+				"type":      "welcome",
+				"stream_id": h.streamID,
+				"sequence":  h.lastSeen,
+				"timestamp": getCurrentTimestamp(),
+			}))
 
-		case message := <-h.broadcast:
+		case <-h.incoming.ready:
+			messages, _ := h.incoming.drain()
 			h.mu.RLock()
-			for client := range h.clients {
-				select {
-				case client.send <- message:
-				default:
-					close(client.send)
-					delete(h.clients, client)
+			for _, message := range messages {
+				h.lastSeen = message.Sequence
+				for client := range h.clients {
+					if client.subscription.Matches(message) {
+						h.deliver(client, message)
+					}
 				}
 			}
 			h.mu.RUnlock()
+
+		case req := <-h.resume:
+			h.mu.RLock()
+			_, ok := h.clients[req.client]
+			h.mu.RUnlock()
+			if ok {
+				h.replay(req)
+			}
 		}
 	}
 }
-func (h *Hub) BroadcastInventoryUpdate(inventoryID uint, productID uint, warehouseID uint, quantity int, action string) {
-	message := map[string]interface{}{
-		"type":         "inventory_update",
-		"inventory_id": inventoryID,
-		"product_id":   productID,
-		"warehouse_id": warehouseID,
-		"quantity":     quantity,
-		"action":       action, // "created", "updated", "deleted", "adjusted"
-		"timestamp":    getCurrentTimestamp(),
-	}
 
-	jsonMessage, err := json.Marshal(message)
-	if err != nil {
-		log.Printf("Error marshaling inventory update: %v", err)
-		return
+// remove disconnects client. It may be called from any goroutine and more
+// than once.
+func (h *Hub) remove(client *Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.clients[client]; ok {
+		delete(h.clients, client)
+		log.Printf("Client disconnected. Total clients: %d", len(h.clients))
 	}
-
-	h.broadcast <- jsonMessage
-	log.Printf("Broadcasting inventory update: Product %d in Warehouse %d, Quantity: %d", productID, warehouseID, quantity)
+	// Closed under mu so a pending registration sees it
+	client.outbox.close()
 }
-func (h *Hub) BroadcastLowStockAlert(productID uint, warehouseID uint, currentQuantity int, minStock int, productName string) {
-	message := map[string]interface{}{
-		"type":             "low_stock_alert",
-		"product_id":       productID,
-		"warehouse_id":     warehouseID,
-		"current_quantity": currentQuantity,
-		"min_stock":        minStock,
-		"product_name":     productName,
-		"timestamp":        getCurrentTimestamp(),
-	}
 
-	jsonMessage, err := json.Marshal(message)
+// Publish validates event, assigns it the next sequence number and queues
+// it for every subscribed client. It never blocks on the hub or on clients.
+func (h *Hub) Publish(event Event) error {
+	t, err := event.prepare()
 	if err != nil {
-		log.Printf("Error marshaling low stock alert: %v", err)
-		return
+		return err
 	}
-
-	h.broadcast <- jsonMessage
-	log.Printf("Broadcasting low stock alert: Product %s (%d), Quantity: %d/%d", productName, productID, currentQuantity, minStock)
-}
-func (h *Hub) GetClientCount() int {
-	h.mu.RLock()
-	defer h.mu.RUnlock()
-	return len(h.clients)
+	route := event.Payload.Route()
+	msg := &Message{
+		Topic:       t.Topic,
+		Alert:       route.Alert,
+		WarehouseID: route.WarehouseID,
+		ProductID:   route.ProductID,
+		SupplierID:  route.SupplierID,
+		CoalesceKey: route.CoalesceKey,
+	}
+	if err := h.publish(msg, &event); err != nil {
+		log.Printf("Failed to publish %s event: %v", event.Type, err)
+		return err
+	}
+	log.Printf("Broadcasting %s event %d", event.Type, event.Sequence)
+	return nil
 }
 
-// BroadcastWarehouseUpdate sends warehouse updates to all connected clients
-func (h *Hub) BroadcastWarehouseUpdate(warehouseID uint, name string, location string, capacity int, action string) {
-	message := map[string]interface{}{
-		"type":         "warehouse_update",
-		"warehouse_id": warehouseID,
-		"name":         name,
-		"location":     location,
-		"capacity":     capacity,
-		"action":       action, // "created", "updated", "deleted"
-		"timestamp":    getCurrentTimestamp(),
+// publish stamps the event with the next sequence number and queues it for
+// Run. Holding seqMu while queueing keeps the backlog in sequence order.
+func (h *Hub) publish(msg *Message, event *Event) error {
+	if h.relay != nil {
+		return h.relay.publish(msg, event)
 	}
 
-	jsonMessage, err := json.Marshal(message)
+	h.seqMu.Lock()
+	defer h.seqMu.Unlock()
+
+	event.Sequence = h.sequence + 1
+	data, err := json.Marshal(event)
 	if err != nil {
-		log.Printf("Error marshaling warehouse update: %v", err)
-		return
+		return err
 	}
+	h.sequence++
+	msg.Sequence = h.sequence
+	msg.Data = data
 
-	h.broadcast <- jsonMessage
-	log.Printf("Broadcasting warehouse update: %s (%d) - %s", name, warehouseID, action)
+	if h.store != nil {
+		if err := h.store.save(msg); err != nil {
+			log.Printf("Failed to persist WebSocket event %d: %v", msg.Sequence, err)
+		}
+	}
+	h.enqueue(msg)
+	return nil
 }
 
-// BroadcastWarehouseCapacityAlert sends alerts when warehouse capacity threshold is reached
-func (h *Hub) BroadcastWarehouseCapacityAlert(warehouseID uint, name string, currentStock int, capacity int, utilizationPercent float64) {
-	message := map[string]interface{}{
-		"type":                "warehouse_capacity_alert",
-		"warehouse_id":        warehouseID,
-		"warehouse_name":      name,
-		"current_stock":       currentStock,
-		"capacity":            capacity,
-		"utilization_percent": utilizationPercent,
-		"timestamp":           getCurrentTimestamp(),
+// enqueue adds a sequenced message to the backlog Run routes from. The
+// message goes into the event log first, so one the backlog drops or
+// coalesces away still leaves no hole for resuming clients.
+func (h *Hub) enqueue(msg *Message) {
+	h.events.append(msg)
+	h.metrics.published(msg.Topic, h.incoming.push(msg, h.policy(msg.Topic)))
+}
+
+// replay sends a resuming client the events it missed in a single frame,
+// or a resync frame when the gap can no longer be filled.
+func (h *Hub) replay(req resumeRequest) {
+	// Replay and resync frames carry the sequence they bring the client up
+	// to, so event streams advance their Last-Event-ID past them.
+	resync := func(reason string) {
+		frame := h.frame(map[string]interface{}{
+			"type":      "resync",
+			"reason":    reason,
+			"stream_id": h.streamID,
+			"sequence":  h.lastSeen,
+			"timestamp": getCurrentTimestamp(),
+		})
+		if frame != nil {
+			frame.Sequence = h.lastSeen
+		}
+		h.deliver(req.client, frame)
 	}
 
-	jsonMessage, err := json.Marshal(message)
-	if err != nil {
-		log.Printf("Error marshaling warehouse capacity alert: %v", err)
+	if req.streamID != "" && req.streamID != h.streamID {
+		resync("stream changed")
 		return
 	}
-
-	h.broadcast <- jsonMessage
-	log.Printf("Broadcasting warehouse capacity alert: %s (%d), Utilization: %.2f%%", name, warehouseID, utilizationPercent)
-}
-
-// BroadcastProductUpdate sends product updates to all connected clients
-func (h *Hub) BroadcastProductUpdate(productID uint, name string, sku string, category string, price float64, action string) {
-	message := map[string]interface{}{
-		"type":       "product_update",
-		"product_id": productID,
-		"name":       name,
-		"sku":        sku,
-		"category":   category,
-		"price":      price,
-		"action":     action, // "created", "updated", "deleted"
-		"timestamp":  getCurrentTimestamp(),
+	if req.lastSequence > h.lastSeen {
+		resync("sequence ahead of server")
+		return
 	}
 
-	jsonMessage, err := json.Marshal(message)
-	if err != nil {
-		log.Printf("Error marshaling product update: %v", err)
+	var missed []*Message
+	oldest := h.events.oldest()
+	if req.lastSequence == h.lastSeen || (oldest != 0 && req.lastSequence+1 >= oldest) {
+		missed = h.events.between(req.lastSequence, h.lastSeen)
+	} else if h.store != nil {
+		before := oldest
+		if before == 0 || before > h.lastSeen+1 {
+			before = h.lastSeen + 1
+		}
+		older, err := h.store.between(req.lastSequence, before, maxReplay+1)
+		if err != nil {
+			log.Printf("Failed to read WebSocket event log: %v", err)
+			resync("event log unavailable")
+			return
+		}
+		if len(older) == 0 || older[0].Sequence != req.lastSequence+1 {
+			resync("events no longer available")
+			return
+		}
+		missed = append(older, h.events.between(req.lastSequence, h.lastSeen)...)
+	} else {
+		resync("events no longer available")
+		return
+	}
+	if len(missed) > maxReplay {
+		resync("too many missed events")
 		return
 	}
 
-	h.broadcast <- jsonMessage
-	log.Printf("Broadcasting product update: %s (ID: %d) - %s", name, productID, action)
-}
-
-// BroadcastProductPriceAlert sends alerts when product price changes significantly
-func (h *Hub) BroadcastProductPriceAlert(productID uint, name string, oldPrice float64, newPrice float64, changePercent float64) {
-	message := map[string]interface{}{
-		"type":           "product_price_alert",
-		"product_id":     productID,
-		"product_name":   name,
-		"old_price":      oldPrice,
-		"new_price":      newPrice,
-		"change_percent": changePercent,
-		"timestamp":      getCurrentTimestamp(),
+	events := make([]json.RawMessage, 0, len(missed))
+	for _, msg := range missed {
+		if req.client.subscription.Matches(msg) {
+			events = append(events, msg.Data)
+		}
+	}
+	frame := h.frame(map[string]interface{}{
+		"type":          "replay",
+		"stream_id":     h.streamID,
+		"from_sequence": req.lastSequence,
+		"sequence":      h.lastSeen,
+		"events":        events,
+		"timestamp":     getCurrentTimestamp(),
+	})
+	if frame != nil {
+		frame.Sequence = h.lastSeen
 	}
+	h.deliver(req.client, frame)
+}
 
-	jsonMessage, err := json.Marshal(message)
-	if err != nil {
-		log.Printf("Error marshaling product price alert: %v", err)
+// deliver queues msg for client without blocking the hub.
+func (h *Hub) deliver(client *Client, msg *Message) {
+	if msg == nil {
 		return
 	}
-
-	h.broadcast <- jsonMessage
-	log.Printf("Broadcasting product price alert: %s (ID: %d), Change: %.2f%%", name, productID, changePercent)
+	h.metrics.delivered(msg.Topic, client.outbox.push(msg, h.policy(msg.Topic)))
 }
 
-// BroadcastSupplierUpdate sends supplier updates to all connected clients
-func (h *Hub) BroadcastSupplierUpdate(supplierID uint, name string, email string, phone string, address string, action string) {
-	message := map[string]interface{}{
-		"type":        "supplier_update",
-		"supplier_id": supplierID,
-		"name":        name,
-		"email":       email,
-		"phone":       phone,
-		"address":     address,
-		"action":      action, // "created", "updated", "deleted"
-		"timestamp":   getCurrentTimestamp(),
+// Stats returns the hub's client count, backlog and per-topic counters.
+func (h *Hub) Stats() Stats {
+	policies := make(map[string]string, len(allTopics))
+	for _, topic := range allTopics {
+		policies[topic] = h.policy(topic).String()
+	}
+	return Stats{
+		Clients:  h.GetClientCount(),
+		Backlog:  h.incoming.len(),
+		Policies: policies,
+		Topics:   h.metrics.snapshot(),
 	}
+}
 
-	jsonMessage, err := json.Marshal(message)
+// frame encodes a control frame addressed to a single client.
+func (h *Hub) frame(message map[string]interface{}) *Message {
+	data, err := json.Marshal(message)
 	if err != nil {
-		log.Printf("Error marshaling supplier update: %v", err)
-		return
+		log.Printf("Error marshaling hub frame: %v", err)
+		return nil
 	}
+	return &Message{Data: data}
+}
+
+func (h *Hub) GetClientCount() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.clients)
+}
 
-	h.broadcast <- jsonMessage
-	log.Printf("Broadcasting supplier update: %s (ID: %d) - %s", name, supplierID, action)
+// sendTo queues data for a single client if it is still connected.
+func (h *Hub) sendTo(client *Client, data []byte) {
+	h.deliver(client, &Message{Data: data})
 }
 
-// BroadcastSupplierStatusAlert sends alerts for supplier status changes
-func (h *Hub) BroadcastSupplierStatusAlert(supplierID uint, name string, status string, message string) {
-	alertMsg := map[string]interface{}{
-		"type":          "supplier_status_alert",
-		"supplier_id":   supplierID,
-		"supplier_name": name,
-		"status":        status, // "active", "inactive", "suspended", "warning"
-		"message":       message,
-		"timestamp":     getCurrentTimestamp(),
-	}
+// requestReplay asks Run to send client the events after lastSequence.
+func (h *Hub) requestReplay(client *Client, lastSequence uint64, streamID string) {
+	h.resume <- resumeRequest{client: client, lastSequence: lastSequence, streamID: streamID}
+}
 
-	jsonMessage, err := json.Marshal(alertMsg)
-	if err != nil {
-		log.Printf("Error marshaling supplier status alert: %v", err)
-		return
+func newStreamID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
 	}
-
-	h.broadcast <- jsonMessage
-	log.Printf("Broadcasting supplier status alert: %s (ID: %d), Status: %s", name, supplierID, status)
+	return hex.EncodeToString(b)
 }
 
 func getCurrentTimestamp() string {
@@ -0,0 +1,414 @@
+package websocket
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// newTestClient returns an authenticated client with no connection; tests
+// read its outbox directly instead of running WritePump.
+func newTestClient(h *Hub, topics ...string) *Client {
+	c := &Client{
+		hub:          h,
+		outbox:       newQueue(clientQueueSize),
+		subscription: NewSubscription(topics...),
+	}
+	c.authenticated.Store(true)
+	return c
+}
+
+func inventoryMessage(productID, warehouseID uint) *Message {
+	return &Message{
+		Topic:       TopicInventory,
+		ProductID:   productID,
+		WarehouseID: warehouseID,
+		CoalesceKey: fmt.Sprintf("inventory:%d:%d", productID, warehouseID),
+	}
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestQueueCoalescesByKey(t *testing.T) {
+	q := newQueue(10)
+	first := inventoryMessage(1, 1)
+	other := inventoryMessage(2, 1)
+	latest := inventoryMessage(1, 1)
+
+	if got := q.push(first, PolicyCoalesce); got != queued {
+		t.Fatalf("first push = %v, want queued", got)
+	}
+	q.push(other, PolicyCoalesce)
+	if got := q.push(latest, PolicyCoalesce); got != coalesced {
+		t.Fatalf("repeat push = %v, want coalesced", got)
+	}
+
+	items, closed := q.drain()
+	if closed {
+		t.Fatal("queue reported closed")
+	}
+	if len(items) != 2 || items[0] != other || items[1] != latest {
+		t.Fatalf("drained %v, want [other latest]", items)
+	}
+}
+
+func TestQueueDropsWhenFull(t *testing.T) {
+	q := newQueue(2)
+	q.push(inventoryMessage(1, 1), PolicyDrop)
+	q.push(inventoryMessage(2, 1), PolicyDrop)
+	if got := q.push(inventoryMessage(3, 1), PolicyDrop); got != dropped {
+		t.Fatalf("push to full queue = %v, want dropped", got)
+	}
+	// Coalescing still makes room for a newer state of a queued entity
+	if got := q.push(inventoryMessage(1, 1), PolicyCoalesce); got != coalesced {
+		t.Fatalf("coalescing push to full queue = %v, want coalesced", got)
+	}
+	if got := q.push(&Message{Topic: TopicInventory}, PolicyCoalesce); got != dropped {
+		t.Fatalf("unkeyed push to full queue = %v, want dropped", got)
+	}
+}
+
+func TestQueueCloseIsIdempotent(t *testing.T) {
+	q := newQueue(2)
+	q.push(inventoryMessage(1, 1), PolicyDrop)
+	q.close()
+	q.close()
+	if got := q.push(inventoryMessage(2, 1), PolicyDrop); got != dropped {
+		t.Fatalf("push after close = %v, want dropped", got)
+	}
+	items, closed := q.drain()
+	if !closed || len(items) != 0 {
+		t.Fatalf("drain after close = %d items, closed %v", len(items), closed)
+	}
+}
+
+func TestPublishDoesNotBlockWhenHubStalled(t *testing.T) {
+	h := NewHub() // Run is never started
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < hubBacklogSize+500; i++ {
+			h.Publish(Event{Payload: SupplierStatusAlert{SupplierID: 1, Status: "warning"}})
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("publish blocked on a stalled hub")
+	}
+
+	stats := h.Stats()
+	if stats.Backlog != hubBacklogSize {
+		t.Errorf("backlog = %d, want %d", stats.Backlog, hubBacklogSize)
+	}
+	if got := stats.Topics[TopicSuppliers].Dropped; got != 500 {
+		t.Errorf("dropped = %d, want 500", got)
+	}
+}
+
+func TestReplayCoversEventsDroppedFromBacklog(t *testing.T) {
+	h := NewHub()
+	h.incoming = newQueue(2)
+	alert := Event{Payload: SupplierStatusAlert{SupplierID: 1, Status: "warning"}}
+	for i := 0; i < 4; i++ {
+		h.Publish(alert) // 3 and 4 are dropped from the backlog
+	}
+	go h.Run()
+	waitFor(t, "backlog routed", func() bool { return h.Stats().Backlog == 0 })
+
+	c := newTestClient(h, TopicSuppliers)
+	h.register <- c
+	h.Publish(alert)
+	waitFor(t, "event 5 routed", func() bool {
+		return c.outbox.len() == 2 // welcome and event 5
+	})
+
+	h.requestReplay(c, 2, "")
+	waitFor(t, "replay frame", func() bool { return c.outbox.len() == 3 })
+	items, _ := c.outbox.drain()
+	var replay struct {
+		Type   string `json:"type"`
+		Events []struct {
+			Sequence uint64 `json:"sequence"`
+		} `json:"events"`
+	}
+	if err := json.Unmarshal(items[2].Data, &replay); err != nil {
+		t.Fatal(err)
+	}
+	if replay.Type != "replay" {
+		t.Fatalf("frame type = %q, want replay", replay.Type)
+	}
+	var got []uint64
+	for _, e := range replay.Events {
+		got = append(got, e.Sequence)
+	}
+	if fmt.Sprint(got) != "[3 4 5]" {
+		t.Errorf("replayed sequences %v, want [3 4 5]", got)
+	}
+}
+
+func TestSlowClientReceivesLatestInventory(t *testing.T) {
+	h := NewHub()
+	go h.Run()
+
+	slow := newTestClient(h, TopicInventory)
+	h.register <- slow
+
+	const updates = 1000
+	for i := 1; i <= updates; i++ {
+		h.Publish(Event{Payload: InventoryUpdated{ProductID: uint(i%3 + 1), WarehouseID: 1, Quantity: i}})
+	}
+	// Messages are routed in order, so once the marker is queued every
+	// update before it has been handled
+	marker := InventoryUpdated{ProductID: 99, WarehouseID: 1}
+	h.Publish(Event{Payload: marker})
+	waitFor(t, "all updates routed", func() bool {
+		slow.outbox.mu.Lock()
+		defer slow.outbox.mu.Unlock()
+		return slow.outbox.pending[marker.Route().CoalesceKey] > 0
+	})
+
+	items, _ := slow.outbox.drain()
+	latest := make(map[uint]int)
+	for _, msg := range items {
+		if msg.Topic != TopicInventory || msg.ProductID == marker.ProductID {
+			continue // welcome frame
+		}
+		var event struct {
+			Type    string           `json:"type"`
+			Version int              `json:"version"`
+			Payload InventoryUpdated `json:"payload"`
+		}
+		if err := json.Unmarshal(msg.Data, &event); err != nil {
+			t.Fatal(err)
+		}
+		if event.Type != "inventory_update" || event.Version != 1 {
+			t.Fatalf("envelope type %q version %d", event.Type, event.Version)
+		}
+		payload := event.Payload
+		if _, dup := latest[payload.ProductID]; dup {
+			t.Errorf("product %d queued twice", payload.ProductID)
+		}
+		latest[payload.ProductID] = payload.Quantity
+	}
+	want := map[uint]int{1: 999, 2: 1000, 3: 998}
+	for productID, quantity := range want {
+		if latest[productID] != quantity {
+			t.Errorf("product %d quantity = %d, want %d", productID, latest[productID], quantity)
+		}
+	}
+	if h.Stats().Topics[TopicInventory].Coalesced == 0 {
+		t.Error("expected coalesced messages to be counted")
+	}
+}
+
+func TestConcurrentRegisterPublishRemove(t *testing.T) {
+	h := NewHub()
+	go h.Run()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c := newTestClient(h, allTopics...)
+			h.register <- c
+			for j := 0; j < 50; j++ {
+				h.Publish(Event{Payload: InventoryUpdated{ProductID: uint(j), WarehouseID: 1}})
+				h.sendTo(c, []byte(`{"type":"subscription"}`))
+				c.outbox.drain()
+			}
+			h.remove(c)
+			h.remove(c) // a second removal must be harmless
+		}()
+	}
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				h.Stats()
+			}
+		}()
+	}
+	wg.Wait()
+
+	waitFor(t, "clients removed", func() bool { return h.GetClientCount() == 0 })
+}
+
+func TestPublishRejectsUnregisteredPayload(t *testing.T) {
+	h := NewHub()
+	if err := h.Publish(Event{}); err == nil {
+		t.Error("expected an error for an event without payload")
+	}
+	if err := h.Publish(Event{Payload: unregisteredPayload{}}); err == nil {
+		t.Error("expected an error for an unregistered event type")
+	}
+}
+
+type unregisteredPayload struct{}
+
+func (unregisteredPayload) EventType() string { return "unregistered" }
+func (unregisteredPayload) Route() Route      { return Route{} }
+
+func TestEventSchemaCoversEventTypes(t *testing.T) {
+	schema := EventSchema()
+	defs := schema["$defs"].(map[string]interface{})
+	if len(defs) != len(eventTypes) {
+		t.Fatalf("schema has %d definitions, want %d", len(defs), len(eventTypes))
+	}
+	def := defs["low_stock_alert"].(map[string]interface{})
+	payload := def["properties"].(map[string]interface{})["payload"].(map[string]interface{})
+	props := payload["properties"].(map[string]interface{})
+	for _, field := range []string{"product_id", "product_name", "warehouse_id", "current_quantity", "min_stock"} {
+		if _, ok := props[field]; !ok {
+			t.Errorf("low_stock_alert payload schema missing %s", field)
+		}
+	}
+	if _, err := json.Marshal(schema); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// publishRouted publishes n supplier alerts, alternating suppliers 1 and 2,
+// and marks them routed as Run would.
+func publishRouted(h *Hub, n int) {
+	for i := 0; i < n; i++ {
+		h.Publish(Event{Payload: SupplierStatusAlert{SupplierID: uint(i%2 + 1), Status: "warning"}})
+	}
+	messages, _ := h.incoming.drain()
+	h.lastSeen = messages[len(messages)-1].Sequence
+}
+
+func TestEventLogKeepsMostRecent(t *testing.T) {
+	l := newEventLog(3)
+	if l.oldest() != 0 {
+		t.Fatalf("empty log oldest = %d", l.oldest())
+	}
+	for seq := uint64(1); seq <= 5; seq++ {
+		l.append(&Message{Sequence: seq})
+	}
+	if l.oldest() != 3 {
+		t.Errorf("oldest = %d, want 3", l.oldest())
+	}
+	var got []uint64
+	for _, msg := range l.between(3, 5) {
+		got = append(got, msg.Sequence)
+	}
+	if fmt.Sprint(got) != "[4 5]" {
+		t.Errorf("between(3, 5) = %v, want [4 5]", got)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	h := NewHub()
+	h.events = newEventLog(5)
+	publishRouted(h, 8) // the log holds 4-8
+
+	tests := []struct {
+		name     string
+		last     uint64
+		streamID string
+		filter   Filter
+		typ      string
+		reason   string
+		want     string // replayed sequences
+	}{
+		{name: "up to date", last: 8, typ: "replay", want: "[]"},
+		{name: "gap inside the log", last: 5, typ: "replay", want: "[6 7 8]"},
+		{name: "gap starting at the oldest event", last: 3, typ: "replay", want: "[4 5 6 7 8]"},
+		{name: "same stream", last: 6, streamID: h.streamID, typ: "replay", want: "[7 8]"},
+		{name: "filter applies to replayed events", last: 3, filter: Filter{SupplierIDs: []uint{2}}, typ: "replay", want: "[4 6 8]"},
+		{name: "gap older than the log", last: 2, typ: "resync", reason: "events no longer available"},
+		{name: "ahead of the server", last: 9, typ: "resync", reason: "sequence ahead of server"},
+		{name: "other stream", last: 5, streamID: "restarted", typ: "resync", reason: "stream changed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestClient(h, TopicSuppliers)
+			c.subscription.SetFilter(tt.filter)
+			h.replay(resumeRequest{client: c, lastSequence: tt.last, streamID: tt.streamID})
+
+			items, _ := c.outbox.drain()
+			if len(items) != 1 {
+				t.Fatalf("queued %d frames, want 1", len(items))
+			}
+			if items[0].Sequence != 8 {
+				t.Errorf("frame sequence = %d, want 8", items[0].Sequence)
+			}
+			var frame struct {
+				Type     string `json:"type"`
+				Reason   string `json:"reason"`
+				Sequence uint64 `json:"sequence"`
+				Events   []struct {
+					Sequence uint64 `json:"sequence"`
+				} `json:"events"`
+			}
+			if err := json.Unmarshal(items[0].Data, &frame); err != nil {
+				t.Fatal(err)
+			}
+			if frame.Type != tt.typ || frame.Reason != tt.reason || frame.Sequence != 8 {
+				t.Fatalf("frame = %+v", frame)
+			}
+			if tt.typ != "replay" {
+				return
+			}
+			got := []uint64{}
+			for _, e := range frame.Events {
+				got = append(got, e.Sequence)
+			}
+			if fmt.Sprint(got) != tt.want {
+				t.Errorf("replayed %v, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReplayResyncsAfterTooManyMissedEvents(t *testing.T) {
+	h := NewHub()
+	h.events = newEventLog(maxReplay + 10)
+	publishRouted(h, maxReplay+5)
+
+	c := newTestClient(h, TopicSuppliers)
+	h.replay(resumeRequest{client: c, lastSequence: 1})
+	if frame := lastFrame(t, c); frame["type"] != "resync" || frame["reason"] != "too many missed events" {
+		t.Fatalf("frame = %v", frame)
+	}
+}
+
+func TestResumeActionRepliesWithReplay(t *testing.T) {
+	h := NewHub()
+	go h.Run()
+	c := newTestClient(h, TopicSuppliers)
+	h.register <- c
+	h.Publish(Event{Payload: SupplierStatusAlert{SupplierID: 1, Status: "warning"}})
+	waitFor(t, "event routed", func() bool { return c.outbox.len() == 2 })
+	c.outbox.drain()
+
+	c.handleMessage([]byte(`{"action":"resume","last_sequence":0}`))
+	waitFor(t, "replay frame", func() bool { return c.outbox.len() == 1 })
+	if frame := lastFrame(t, c); frame["type"] != "replay" || len(frame["events"].([]interface{})) != 1 {
+		t.Fatalf("frame = %v", frame)
+	}
+}
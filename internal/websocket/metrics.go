@@ -0,0 +1,82 @@
+package websocket
+
+import "sync"
+
+// TopicStats counts what happened to a topic's messages. Coalesced and
+// Dropped cover both the hub backlog and client queues.
+type TopicStats struct {
+	Published uint64 `json:"published"`
+	Delivered uint64 `json:"delivered"`
+	Coalesced uint64 `json:"coalesced"`
+	Dropped   uint64 `json:"dropped"`
+}
+
+// Stats is a snapshot of the hub's state and counters.
+type Stats struct {
+	Clients  int                   `json:"clients"`
+	Backlog  int                   `json:"backlog"`
+	Policies map[string]string     `json:"policies"`
+	Topics   map[string]TopicStats `json:"topics"`
+}
+
+// controlTopic groups frames sent to a single client (welcome, replies,
+// replays) in the metrics.
+const controlTopic = "control"
+
+type metrics struct {
+	mu     sync.Mutex
+	topics map[string]*TopicStats
+}
+
+func newMetrics() *metrics {
+	return &metrics{topics: make(map[string]*TopicStats)}
+}
+
+func (m *metrics) published(topic string, result pushResult) {
+	m.record(topic, func(s *TopicStats) {
+		s.Published++
+		m.count(s, result)
+	})
+}
+
+func (m *metrics) delivered(topic string, result pushResult) {
+	m.record(topic, func(s *TopicStats) {
+		if result != dropped {
+			s.Delivered++
+		}
+		m.count(s, result)
+	})
+}
+
+func (m *metrics) count(s *TopicStats, result pushResult) {
+	switch result {
+	case coalesced:
+		s.Coalesced++
+	case dropped:
+		s.Dropped++
+	}
+}
+
+func (m *metrics) record(topic string, update func(*TopicStats)) {
+	if topic == "" {
+		topic = controlTopic
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.topics[topic]
+	if !ok {
+		s = &TopicStats{}
+		m.topics[topic] = s
+	}
+	update(s)
+}
+
+func (m *metrics) snapshot() map[string]TopicStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make(map[string]TopicStats, len(m.topics))
+	for topic, s := range m.topics {
+		out[topic] = *s
+	}
+	return out
+}
@@ -0,0 +1,146 @@
+package websocket
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+const (
+	// hubBacklogSize bounds messages published but not yet routed by Run.
+	hubBacklogSize = 4096
+	// clientQueueSize bounds messages waiting to be written to one client.
+	clientQueueSize = 256
+)
+
+// Policy decides what happens to a message when a queue is backed up.
+type Policy int
+
+const (
+	// PolicyDrop discards the new message when the queue is full.
+	PolicyDrop Policy = iota
+	// PolicyCoalesce replaces a queued message with the same coalesce key,
+	// so a slow consumer only sees the latest state of each entity.
+	// Messages without a key are dropped when the queue is full.
+	PolicyCoalesce
+)
+
+func (p Policy) String() string {
+	if p == PolicyCoalesce {
+		return "coalesce"
+	}
+	return "drop"
+}
+
+func ParsePolicy(s string) (Policy, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "drop":
+		return PolicyDrop, nil
+	case "coalesce":
+		return PolicyCoalesce, nil
+	}
+	return PolicyDrop, fmt.Errorf("unknown policy %q", s)
+}
+
+// pushResult reports what a queue did with a pushed message.
+type pushResult int
+
+const (
+	queued pushResult = iota
+	coalesced
+	dropped
+)
+
+// queue is a bounded FIFO of messages that never blocks the producer. The
+// consumer waits on ready and then drains everything queued.
+type queue struct {
+	mu      sync.Mutex
+	items   []*Message
+	pending map[string]int // queued messages per coalesce key
+	limit   int
+	closed  bool
+	ready   chan struct{}
+}
+
+func newQueue(limit int) *queue {
+	return &queue{
+		pending: make(map[string]int),
+		limit:   limit,
+		ready:   make(chan struct{}, 1),
+	}
+}
+
+func (q *queue) push(msg *Message, policy Policy) pushResult {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return dropped
+	}
+
+	result := queued
+	if policy == PolicyCoalesce && q.pending[msg.CoalesceKey] > 0 && msg.CoalesceKey != "" {
+		for i, old := range q.items {
+			if old.CoalesceKey == msg.CoalesceKey {
+				// Re-queue at the back so delivery stays in sequence order
+				q.items = append(q.items[:i], q.items[i+1:]...)
+				q.pending[msg.CoalesceKey]--
+				result = coalesced
+				break
+			}
+		}
+	}
+	if result == queued && len(q.items) >= q.limit {
+		return dropped
+	}
+
+	q.items = append(q.items, msg)
+	if msg.CoalesceKey != "" {
+		q.pending[msg.CoalesceKey]++
+	}
+	q.signal()
+	return result
+}
+
+// drain removes and returns everything queued, and whether the queue has
+// been closed.
+func (q *queue) drain() ([]*Message, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	items := q.items
+	q.items = nil
+	clear(q.pending)
+	return items, q.closed
+}
+
+func (q *queue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.items)
+}
+
+// close discards anything queued and wakes the consumer. It is safe to call
+// more than once.
+func (q *queue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return
+	}
+	q.closed = true
+	q.items = nil
+	clear(q.pending)
+	q.signal()
+}
+
+func (q *queue) isClosed() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.closed
+}
+
+func (q *queue) signal() {
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
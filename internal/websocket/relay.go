@@ -0,0 +1,255 @@
+package websocket
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"myapp/internal"
+	"time"
+
+	"github.com/jackc/pgx/v5/stdlib"
+	"gorm.io/gorm"
+)
+
+const (
+	notifyChannel = "ims_events"
+	// relayLockKey serialises publishers across instances so sequence order
+	// matches notification order.
+	relayLockKey = 7234001
+	// Postgres rejects NOTIFY payloads of 8000 bytes or more.
+	maxNotifyPayload = 7900
+	maxRelayBackoff  = 30 * time.Second
+)
+
+// relayEnvelope is the NOTIFY payload. When the event is too large to fit,
+// Data is omitted and listeners read it from the ws_events table.
+type relayEnvelope struct {
+	Sequence    uint64          `json:"sequence"`
+	Topic       string          `json:"topic"`
+	Alert       bool            `json:"alert,omitempty"`
+	WarehouseID uint            `json:"warehouse_id,omitempty"`
+	ProductID   uint            `json:"product_id,omitempty"`
+	SupplierID  uint            `json:"supplier_id,omitempty"`
+	CoalesceKey string          `json:"coalesce_key,omitempty"`
+	Data        json.RawMessage `json:"data,omitempty"`
+	Stored      bool            `json:"stored,omitempty"`
+}
+
+// pgRelay fans events out to every API instance through Postgres
+// LISTEN/NOTIFY. Events are only delivered to local clients once they come
+// back from Postgres, so every instance sees the same order.
+type pgRelay struct {
+	hub         *Hub
+	lastRelayed uint64 // only touched by the listener goroutine
+}
+
+// UsePostgresRelay switches the hub to multi-instance mode. Sequence numbers
+// come from a shared Postgres sequence. Call it after UsePostgresEventLog
+// (if used) and before Run.
+func (h *Hub) UsePostgresRelay() error {
+	if err := internal.DB.Exec("CREATE SEQUENCE IF NOT EXISTS ws_event_seq").Error; err != nil {
+		return err
+	}
+	if h.store != nil && h.sequence > 0 {
+		// Keep numbering ahead of anything already in the event log
+		if err := internal.DB.Exec("SELECT setval('ws_event_seq', GREATEST(?, (SELECT last_value FROM ws_event_seq)))",
+			h.sequence).Error; err != nil {
+			return err
+		}
+	}
+	var current uint64
+	if err := internal.DB.Raw("SELECT CASE WHEN is_called THEN last_value ELSE 0 END FROM ws_event_seq").
+		Scan(&current).Error; err != nil {
+		return err
+	}
+
+	h.relay = &pgRelay{hub: h, lastRelayed: current}
+	h.streamID = "postgres"
+	h.sequence = current
+	h.lastSeen = current
+	go h.relay.listen()
+	return nil
+}
+
+// publish assigns the next global sequence number and sends the event with
+// NOTIFY, all in one transaction.
+func (r *pgRelay) publish(msg *Message, event *Event) error {
+	return internal.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", relayLockKey).Error; err != nil {
+			return err
+		}
+		var seq uint64
+		if err := tx.Raw("SELECT nextval('ws_event_seq')").Scan(&seq).Error; err != nil {
+			return err
+		}
+		event.Sequence = seq
+		data, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		msg.Sequence = seq
+		msg.Data = data
+
+		if r.hub.store != nil {
+			if err := tx.Create(newWSEvent(msg)).Error; err != nil {
+				return err
+			}
+		}
+
+		env := relayEnvelope{
+			Sequence:    seq,
+			Topic:       msg.Topic,
+			Alert:       msg.Alert,
+			WarehouseID: msg.WarehouseID,
+			ProductID:   msg.ProductID,
+			SupplierID:  msg.SupplierID,
+			CoalesceKey: msg.CoalesceKey,
+			Data:        data,
+		}
+		body, err := json.Marshal(env)
+		if err != nil {
+			return err
+		}
+		if len(body) > maxNotifyPayload {
+			if r.hub.store == nil {
+				return fmt.Errorf("event %d is too large to relay without WS_EVENT_LOG_PERSIST", seq)
+			}
+			env.Data = nil
+			env.Stored = true
+			if body, err = json.Marshal(env); err != nil {
+				return err
+			}
+		}
+		return tx.Exec("SELECT pg_notify(?, ?)", notifyChannel, string(body)).Error
+	})
+}
+
+// listen keeps a LISTEN connection open, reconnecting with backoff when it
+// drops.
+func (r *pgRelay) listen() {
+	backoff := time.Second
+	for {
+		started := time.Now()
+		err := r.listenOnce()
+		log.Printf("WebSocket relay listener stopped: %v", err)
+		if time.Since(started) > maxRelayBackoff {
+			backoff = time.Second
+		}
+		time.Sleep(backoff)
+		backoff = min(backoff*2, maxRelayBackoff)
+	}
+}
+
+func (r *pgRelay) listenOnce() error {
+	ctx := context.Background()
+	sqlDB, err := internal.DB.DB()
+	if err != nil {
+		return err
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var listenErr error
+	conn.Raw(func(driverConn any) error {
+		pgConn := driverConn.(*stdlib.Conn).Conn()
+		if _, listenErr = pgConn.Exec(ctx, "LISTEN "+notifyChannel); listenErr != nil {
+			return driver.ErrBadConn
+		}
+		log.Println("WebSocket relay listening for Postgres notifications")
+
+		// Anything published while we were not listening is only
+		// recoverable from the persistent event log
+		r.catchUp()
+
+		for {
+			n, err := pgConn.WaitForNotification(ctx)
+			if err != nil {
+				listenErr = err
+				// Discard the connection rather than return it to the pool
+				return driver.ErrBadConn
+			}
+			r.handle(n.Payload)
+		}
+	})
+	if listenErr == nil {
+		listenErr = errors.New("listener connection closed")
+	}
+	return listenErr
+}
+
+func (r *pgRelay) handle(payload string) {
+	var env relayEnvelope
+	if err := json.Unmarshal([]byte(payload), &env); err != nil {
+		log.Printf("Ignoring malformed relay notification: %v", err)
+		return
+	}
+	if env.Sequence <= r.lastRelayed {
+		return // duplicate
+	}
+	if env.Sequence > r.lastRelayed+1 {
+		r.catchUp()
+		if env.Sequence <= r.lastRelayed {
+			return
+		}
+	}
+
+	msg := &Message{
+		Sequence:    env.Sequence,
+		Topic:       env.Topic,
+		Alert:       env.Alert,
+		WarehouseID: env.WarehouseID,
+		ProductID:   env.ProductID,
+		SupplierID:  env.SupplierID,
+		CoalesceKey: env.CoalesceKey,
+		Data:        env.Data,
+	}
+	if env.Stored {
+		if r.hub.store == nil {
+			log.Printf("Relay event %d needs WS_EVENT_LOG_PERSIST to be loaded", env.Sequence)
+			return
+		}
+		stored, err := r.hub.store.between(env.Sequence-1, env.Sequence+1, 1)
+		if err != nil || len(stored) == 0 {
+			log.Printf("Relay event %d missing from event log", env.Sequence)
+			return
+		}
+		msg = stored[0]
+	}
+	r.deliver(msg)
+}
+
+// catchUp delivers events missed since lastRelayed from the persistent
+// event log. Without one, clients detect the gap and resync.
+func (r *pgRelay) catchUp() {
+	if r.hub.store == nil {
+		return
+	}
+	for {
+		missed, err := r.hub.store.between(r.lastRelayed, math.MaxInt64, maxReplay)
+		if err != nil {
+			log.Printf("Relay catch-up failed: %v", err)
+			return
+		}
+		if len(missed) == 0 {
+			return
+		}
+		for _, msg := range missed {
+			r.deliver(msg)
+		}
+	}
+}
+
+func (r *pgRelay) deliver(msg *Message) {
+	if msg.Sequence > r.lastRelayed+1 {
+		log.Printf("Relay skipped events %d-%d", r.lastRelayed+1, msg.Sequence-1)
+	}
+	r.lastRelayed = msg.Sequence
+	r.hub.enqueue(msg)
+}
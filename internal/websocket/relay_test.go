@@ -0,0 +1,95 @@
+package websocket
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func notification(t *testing.T, env relayEnvelope) string {
+	t.Helper()
+	body, err := json.Marshal(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
+
+// relayed returns the sequences the relay handed to the hub so far.
+func relayed(h *Hub) string {
+	messages, _ := h.incoming.drain()
+	var seqs []uint64
+	for _, msg := range messages {
+		seqs = append(seqs, msg.Sequence)
+	}
+	return fmt.Sprint(seqs)
+}
+
+func TestRelayHandleDeliversInOrder(t *testing.T) {
+	h := NewHub()
+	r := &pgRelay{hub: h, lastRelayed: 10}
+
+	r.handle(notification(t, relayEnvelope{
+		Sequence:    11,
+		Topic:       TopicInventory,
+		WarehouseID: 2,
+		ProductID:   5,
+		CoalesceKey: "inventory:5:2",
+		Data:        json.RawMessage(`{"sequence":11}`),
+	}))
+	messages, _ := h.incoming.drain()
+	if len(messages) != 1 {
+		t.Fatalf("relayed %d messages, want 1", len(messages))
+	}
+	msg := messages[0]
+	if msg.Sequence != 11 || msg.Topic != TopicInventory || msg.WarehouseID != 2 || msg.ProductID != 5 ||
+		msg.CoalesceKey != "inventory:5:2" || string(msg.Data) != `{"sequence":11}` {
+		t.Errorf("message = %+v", msg)
+	}
+	if r.lastRelayed != 11 {
+		t.Errorf("lastRelayed = %d, want 11", r.lastRelayed)
+	}
+	if got := h.events.between(10, 11); len(got) != 1 {
+		t.Error("relayed message missing from the event log")
+	}
+}
+
+func TestRelayHandleSkipsDuplicatesAndBadPayloads(t *testing.T) {
+	h := NewHub()
+	r := &pgRelay{hub: h, lastRelayed: 10}
+
+	r.handle(notification(t, relayEnvelope{Sequence: 9, Topic: TopicInventory}))
+	r.handle(notification(t, relayEnvelope{Sequence: 10, Topic: TopicInventory}))
+	r.handle("not json")
+	if got := relayed(h); got != "[]" {
+		t.Errorf("relayed %s, want nothing", got)
+	}
+	if r.lastRelayed != 10 {
+		t.Errorf("lastRelayed = %d, want 10", r.lastRelayed)
+	}
+}
+
+func TestRelayHandleGapWithoutEventLog(t *testing.T) {
+	h := NewHub()
+	r := &pgRelay{hub: h, lastRelayed: 10}
+
+	// Without a persistent log the gap stays and clients resync
+	r.handle(notification(t, relayEnvelope{Sequence: 14, Topic: TopicOrders}))
+	r.handle(notification(t, relayEnvelope{Sequence: 12, Topic: TopicOrders}))
+	if got := relayed(h); got != "[14]" {
+		t.Errorf("relayed %s, want [14]", got)
+	}
+	if r.lastRelayed != 14 {
+		t.Errorf("lastRelayed = %d, want 14", r.lastRelayed)
+	}
+}
+
+func TestRelayHandleStoredEventWithoutEventLog(t *testing.T) {
+	h := NewHub()
+	r := &pgRelay{hub: h, lastRelayed: 10}
+
+	r.handle(notification(t, relayEnvelope{Sequence: 11, Topic: TopicProducts, Stored: true}))
+	if got := relayed(h); got != "[]" {
+		t.Errorf("relayed %s, want nothing", got)
+	}
+}
@@ -0,0 +1,105 @@
+package websocket
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// EventSchema generates a JSON Schema (draft 2020-12) describing every
+// registered event type, derived from the payload structs.
+func EventSchema() map[string]interface{} {
+	defs := make(map[string]interface{}, len(eventTypes))
+	variants := make([]interface{}, 0, len(eventTypes))
+	for _, t := range eventTypes {
+		name := t.Payload.EventType()
+		defs[name] = map[string]interface{}{
+			"type":        "object",
+			"description": t.Description,
+			"x-topic":     t.Topic,
+			"properties": map[string]interface{}{
+				"type":      map[string]interface{}{"const": name},
+				"version":   map[string]interface{}{"const": t.Version},
+				"sequence":  map[string]interface{}{"type": "integer", "minimum": 0},
+				"timestamp": map[string]interface{}{"type": "string", "format": "date-time"},
+				"actor":     map[string]interface{}{"type": "string"},
+				"payload":   typeSchema(reflect.TypeOf(t.Payload)),
+			},
+			"required":             []string{"type", "version", "sequence", "timestamp", "actor", "payload"},
+			"additionalProperties": false,
+		}
+		variants = append(variants, map[string]interface{}{"$ref": "#/$defs/" + name})
+	}
+
+	return map[string]interface{}{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"$id":     "/ws/schema",
+		"title":   "IMS WebSocket event",
+		"oneOf":   variants,
+		"$defs":   defs,
+	}
+}
+
+func typeSchema(t reflect.Type) map[string]interface{} {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return typeSchema(t.Elem())
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": typeSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": typeSchema(t.Elem())}
+	case reflect.Struct:
+		properties := make(map[string]interface{})
+		required := []string{}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			schema := typeSchema(field.Type)
+			if enum := field.Tag.Get("enum"); enum != "" {
+				schema["enum"] = strings.Split(enum, ",")
+			}
+			properties[name] = schema
+			if !strings.Contains(opts, "omitempty") {
+				required = append(required, name)
+			}
+		}
+		return map[string]interface{}{
+			"type":                 "object",
+			"properties":           properties,
+			"required":             required,
+			"additionalProperties": false,
+		}
+	}
+	return map[string]interface{}{}
+}
+
+// GetEventSchema serves the generated event schema.
+func GetEventSchema(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/schema+json")
+	json.NewEncoder(w).Encode(EventSchema())
+}
@@ -0,0 +1,199 @@
+package websocket
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sseHeartbeat is how often an idle event stream is sent a comment line so
+// proxies do not close it.
+const sseHeartbeat = 15 * time.Second
+
+// HandleEvents streams hub messages as Server-Sent Events for clients
+// behind proxies that block WebSocket upgrades, e.g.
+// GET /events?topics=inventory,alerts&warehouse_ids=3.
+//
+// Frames are the same JSON sent over /ws/*. Events and replay frames carry
+// an id of "<stream_id>:<sequence>"; a reconnecting EventSource sends it
+// back as Last-Event-ID and receives the events it missed.
+func HandleEvents(w http.ResponseWriter, r *http.Request) {
+	GlobalHub.serveEvents(w, r)
+}
+
+func (h *Hub) serveEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	if !checkOrigin(r) {
+		http.Error(w, "Origin not allowed", http.StatusForbidden)
+		return
+	}
+
+	topics, filter, err := parseStreamQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var claims *Claims
+	if authEnabled() {
+		token := tokenFromRequest(r)
+		if token == "" {
+			http.Error(w, "Token required", http.StatusUnauthorized)
+			return
+		}
+		claims, err = VerifyToken(token)
+		if err != nil {
+			http.Error(w, "Invalid token", http.StatusUnauthorized)
+			return
+		}
+	}
+
+	client := NewClient(h, nil, topics...)
+	if err := client.authenticate(claims); err != nil {
+		http.Error(w, "Not authorized for these topics", http.StatusForbidden)
+		return
+	}
+	client.subscription.SetFilter(filter)
+	defer func() {
+		if client.expiry != nil {
+			client.expiry.Stop()
+		}
+		h.remove(client)
+	}()
+
+	if origin := r.Header.Get("Origin"); origin != "" {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Set("Vary", "Origin")
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	// Browsers reconnect after this many milliseconds
+	fmt.Fprint(w, "retry: 3000\n\n")
+	flusher.Flush()
+
+	h.register <- client
+	if streamID, seq, ok := lastEventID(r); ok {
+		h.requestReplay(client, seq, streamID)
+	}
+
+	heartbeat := time.NewTicker(sseHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-client.outbox.ready:
+			messages, closed := client.outbox.drain()
+			for _, msg := range messages {
+				if err := writeEvent(w, h.streamID, msg); err != nil {
+					log.Printf("Event stream write error: %v", err)
+					return
+				}
+			}
+			flusher.Flush()
+			if closed {
+				return
+			}
+		}
+	}
+}
+
+// writeEvent writes msg as one SSE event. Frames are single-line JSON, so a
+// single data field suffices.
+func writeEvent(w http.ResponseWriter, streamID string, msg *Message) error {
+	if msg.Sequence > 0 {
+		if _, err := fmt.Fprintf(w, "id: %s:%d\n", streamID, msg.Sequence); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "data: %s\n\n", msg.Data)
+	return err
+}
+
+// lastEventID reads the Last-Event-ID header, or the last_event_id query
+// parameter for clients that cannot set headers.
+func lastEventID(r *http.Request) (string, uint64, bool) {
+	id := r.Header.Get("Last-Event-ID")
+	if id == "" {
+		id = r.URL.Query().Get("last_event_id")
+	}
+	if id == "" {
+		return "", 0, false
+	}
+	streamID, seqStr, found := strings.Cut(id, ":")
+	if !found {
+		streamID, seqStr = "", id
+	}
+	seq, err := strconv.ParseUint(seqStr, 10, 64)
+	if err != nil {
+		return "", 0, false
+	}
+	return streamID, seq, true
+}
+
+// parseStreamQuery reads the topics and filter of an event stream request.
+// No topics means every topic.
+func parseStreamQuery(r *http.Request) ([]string, Filter, error) {
+	q := r.URL.Query()
+	topics := allTopics
+	if v := q.Get("topics"); v != "" {
+		topics = nil
+		for _, t := range strings.Split(v, ",") {
+			t = strings.TrimSpace(t)
+			if t != TopicAlerts && !slices.Contains(allTopics, t) {
+				return nil, Filter{}, fmt.Errorf("Unknown topic: %s", t)
+			}
+			topics = append(topics, t)
+		}
+	}
+
+	var filter Filter
+	var err error
+	if filter.WarehouseIDs, err = parseIDs(q.Get("warehouse_ids")); err != nil {
+		return nil, Filter{}, errors.New("Invalid warehouse_ids")
+	}
+	if filter.ProductIDs, err = parseIDs(q.Get("product_ids")); err != nil {
+		return nil, Filter{}, errors.New("Invalid product_ids")
+	}
+	if filter.SupplierIDs, err = parseIDs(q.Get("supplier_ids")); err != nil {
+		return nil, Filter{}, errors.New("Invalid supplier_ids")
+	}
+	filter.AlertsOnly = q.Get("alerts_only") == "true"
+	return topics, filter, nil
+}
+
+func parseIDs(v string) ([]uint, error) {
+	if v == "" {
+		return nil, nil
+	}
+	var ids []uint
+	for _, s := range strings.Split(v, ",") {
+		id, err := strconv.ParseUint(strings.TrimSpace(s), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, uint(id))
+	}
+	return ids, nil
+}
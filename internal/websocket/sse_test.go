@@ -0,0 +1,147 @@
+package websocket
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type sseEvent struct {
+	id   string
+	data map[string]interface{}
+}
+
+// readEvent returns the next event on the stream, skipping comments and
+// the retry hint.
+func readEvent(t *testing.T, r *bufio.Reader) sseEvent {
+	t.Helper()
+	var ev sseEvent
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("reading stream: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "":
+			if ev.data != nil {
+				return ev
+			}
+		case strings.HasPrefix(line, "id: "):
+			ev.id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "data: "):
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &ev.data); err != nil {
+				t.Fatalf("bad data line %q: %v", line, err)
+			}
+		}
+	}
+}
+
+func openStream(t *testing.T, url, lastEventID string) *bufio.Reader {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("status %d, content type %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	return bufio.NewReader(resp.Body)
+}
+
+func TestEventStreamResumesFromLastEventID(t *testing.T) {
+	h := NewHub()
+	go h.Run()
+	srv := httptest.NewServer(http.HandlerFunc(h.serveEvents))
+	// Registered first so it runs after the streams are closed
+	t.Cleanup(srv.Close)
+
+	live := openStream(t, srv.URL+"/events?topics=inventory", "")
+	if ev := readEvent(t, live); ev.data["type"] != "welcome" || ev.id != "" {
+		t.Fatalf("first event = %+v, want welcome without id", ev)
+	}
+
+	var ids []string
+	for i := uint(1); i <= 3; i++ {
+		if err := h.Publish(Event{Payload: InventoryUpdated{ProductID: i, WarehouseID: 1, Action: "adjusted"}}); err != nil {
+			t.Fatal(err)
+		}
+		ev := readEvent(t, live)
+		if ev.data["type"] != "inventory_update" {
+			t.Fatalf("event = %+v", ev)
+		}
+		ids = append(ids, ev.id)
+	}
+	if want := h.streamID + ":3"; ids[2] != want {
+		t.Fatalf("id = %q, want %q", ids[2], want)
+	}
+
+	resumed := openStream(t, srv.URL+"/events?topics=inventory", ids[0])
+	readEvent(t, resumed) // welcome
+	replay := readEvent(t, resumed)
+	if replay.data["type"] != "replay" || replay.id != ids[2] {
+		t.Fatalf("replay = %+v", replay)
+	}
+	if events := replay.data["events"].([]interface{}); len(events) != 2 {
+		t.Errorf("replayed %d events, want 2", len(events))
+	}
+}
+
+func TestEventStreamRejectsUnknownTopic(t *testing.T) {
+	rec := httptest.NewRecorder()
+	NewHub().serveEvents(rec, httptest.NewRequest(http.MethodGet, "/events?topics=inventory,nope", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", rec.Code)
+	}
+}
+
+func TestAlertsTopicMatchesAuthorizedAlerts(t *testing.T) {
+	s := NewSubscription(TopicProducts, TopicAlerts)
+	alert := &Message{Topic: TopicInventory, Alert: true}
+	update := &Message{Topic: TopicInventory}
+	if !s.Matches(alert) || s.Matches(update) {
+		t.Fatal("alerts topic should match alerts from other topics only")
+	}
+	if !s.Matches(&Message{Topic: TopicProducts}) {
+		t.Fatal("products update not matched")
+	}
+
+	s.Restrict([]string{TopicProducts})
+	if s.Matches(alert) {
+		t.Error("matched alert from an unauthorized topic")
+	}
+	if got := s.Topics(); len(got) != 2 || got[1] != TopicAlerts {
+		t.Errorf("topics = %v", got)
+	}
+}
+
+func TestLastEventID(t *testing.T) {
+	tests := []struct {
+		header string
+		stream string
+		seq    uint64
+		ok     bool
+	}{
+		{"abc123:42", "abc123", 42, true},
+		{"postgres:7", "postgres", 7, true},
+		{"42", "", 42, true},
+		{"abc:x", "", 0, false},
+		{"", "", 0, false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/events", nil)
+		r.Header.Set("Last-Event-ID", tt.header)
+		stream, seq, ok := lastEventID(r)
+		if stream != tt.stream || seq != tt.seq || ok != tt.ok {
+			t.Errorf("lastEventID(%q) = %q, %d, %v", tt.header, stream, seq, ok)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package websocket
+
+import (
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Topics a client can subscribe to. Each /ws/* endpoint subscribes to the
+// topic matching its path.
+const (
+	TopicInventory  = "inventory"
+	TopicWarehouses = "warehouses"
+	TopicProducts   = "products"
+	TopicSuppliers  = "suppliers"
+	TopicOrders     = "orders"
+)
+
+var allTopics = []string{TopicInventory, TopicWarehouses, TopicProducts, TopicSuppliers, TopicOrders}
+
+// Topics lists the topics events are published to.
+func Topics() []string {
+	return slices.Clone(allTopics)
+}
+
+// TopicAlerts is not a topic events are published to. Subscribing to it
+// receives the alerts of every topic the client is authorized for.
+const TopicAlerts = "alerts"
+
+// Message is an encoded event together with the attributes used to route it.
+// Zero IDs mean the event does not concern that kind of entity. Messages
+// sharing a CoalesceKey describe the same entity, so under PolicyCoalesce a
+// newer one replaces an older one still queued.
+type Message struct {
+	Sequence    uint64
+	Topic       string
+	Alert       bool
+	WarehouseID uint
+	ProductID   uint
+	SupplierID  uint
+	CoalesceKey string
+	Data        []byte
+}
+
+// Filter narrows a subscription. Empty lists match everything, and a list
+// only applies to messages that carry that kind of ID.
+type Filter struct {
+	WarehouseIDs []uint `json:"warehouse_ids,omitempty"`
+	ProductIDs   []uint `json:"product_ids,omitempty"`
+	SupplierIDs  []uint `json:"supplier_ids,omitempty"`
+	AlertsOnly   bool   `json:"alerts_only,omitempty"`
+}
+
+// Subscription is the set of topics and filter a client receives.
+type Subscription struct {
+	mu      sync.RWMutex
+	topics  map[string]bool
+	allowed []string // topics the client is authorized for; nil means all
+	filter  Filter
+}
+
+func NewSubscription(topics ...string) *Subscription {
+	s := &Subscription{topics: make(map[string]bool)}
+	s.Subscribe(topics...)
+	return s
+}
+
+// Subscribe adds known, authorized topics and ignores the rest.
+func (s *Subscription) Subscribe(topics ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range topics {
+		if t == TopicAlerts {
+			s.topics[t] = true
+			continue
+		}
+		if !slices.Contains(allTopics, t) {
+			continue
+		}
+		if s.allowed != nil && !slices.Contains(s.allowed, t) {
+			continue
+		}
+		s.topics[t] = true
+	}
+}
+
+// Restrict limits the subscription to the given topics, dropping any
+// current topics outside them.
+func (s *Subscription) Restrict(allowed []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.allowed = append([]string{}, allowed...)
+	for t := range s.topics {
+		if t != TopicAlerts && !slices.Contains(s.allowed, t) {
+			delete(s.topics, t)
+		}
+	}
+}
+
+// Authorized reports whether the client may use topic.
+func (s *Subscription) Authorized(topic string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.allowed == nil || slices.Contains(s.allowed, topic)
+}
+
+func (s *Subscription) Unsubscribe(topics ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range topics {
+		delete(s.topics, t)
+	}
+}
+
+// SetFilter replaces the subscription's filter.
+func (s *Subscription) SetFilter(f Filter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.filter = f
+}
+
+// Topics returns the subscribed topics in a stable order.
+func (s *Subscription) Topics() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var topics []string
+	for _, t := range append(allTopics, TopicAlerts) {
+		if s.topics[t] {
+			topics = append(topics, t)
+		}
+	}
+	return topics
+}
+
+func (s *Subscription) Filter() Filter {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.filter
+}
+
+// Matches reports whether msg should be delivered to this subscription.
+func (s *Subscription) Matches(msg *Message) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if !s.topics[msg.Topic] && !(msg.Alert && s.topics[TopicAlerts] &&
+		(s.allowed == nil || slices.Contains(s.allowed, msg.Topic))) {
+		return false
+	}
+	f := s.filter
+	if f.AlertsOnly && !msg.Alert {
+		return false
+	}
+	return matchID(f.WarehouseIDs, msg.WarehouseID) &&
+		matchID(f.ProductIDs, msg.ProductID) &&
+		matchID(f.SupplierIDs, msg.SupplierID)
+}
+
+func matchID(ids []uint, id uint) bool {
+	return len(ids) == 0 || id == 0 || slices.Contains(ids, id)
+}
+
+// topicsForPath maps /ws/inventory to the inventory topic and so on. Unknown
+// paths subscribe to every topic.
+func topicsForPath(path string) []string {
+	name := strings.TrimPrefix(strings.TrimSuffix(path, "/"), "/ws/")
+	if slices.Contains(allTopics, name) {
+		return []string{name}
+	}
+	return allTopics
+}
@@ -0,0 +1,86 @@
+package websocket
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSubscriptionMatches(t *testing.T) {
+	stockUpdate := &Message{Topic: TopicInventory, WarehouseID: 3, ProductID: 7}
+	lowStock := &Message{Topic: TopicInventory, Alert: true, WarehouseID: 3, ProductID: 7}
+	supplierAlert := &Message{Topic: TopicSuppliers, Alert: true, SupplierID: 2}
+	productUpdate := &Message{Topic: TopicProducts, ProductID: 7} // no warehouse
+
+	tests := []struct {
+		name    string
+		topics  []string
+		allowed []string // nil leaves the subscription unrestricted
+		filter  Filter
+		msg     *Message
+		want    bool
+	}{
+		{"subscribed topic", []string{TopicInventory}, nil, Filter{}, stockUpdate, true},
+		{"other topic", []string{TopicProducts}, nil, Filter{}, stockUpdate, false},
+		{"no topics", nil, nil, Filter{}, stockUpdate, false},
+		{"alerts topic receives alerts", []string{TopicAlerts}, nil, Filter{}, supplierAlert, true},
+		{"alerts topic skips updates", []string{TopicAlerts}, nil, Filter{}, stockUpdate, false},
+		{"alerts topic limited to allowed topics", []string{TopicAlerts}, []string{TopicInventory}, Filter{}, supplierAlert, false},
+		{"alerts topic with allowed topic", []string{TopicAlerts}, []string{TopicInventory}, Filter{}, lowStock, true},
+		{"warehouse filter matches", []string{TopicInventory}, nil, Filter{WarehouseIDs: []uint{1, 3}}, stockUpdate, true},
+		{"warehouse filter excludes", []string{TopicInventory}, nil, Filter{WarehouseIDs: []uint{1}}, stockUpdate, false},
+		{"product filter excludes", []string{TopicInventory}, nil, Filter{ProductIDs: []uint{8}}, stockUpdate, false},
+		{"filter ignores IDs the message lacks", []string{TopicProducts}, nil, Filter{WarehouseIDs: []uint{1}}, productUpdate, true},
+		{"filters combine", []string{TopicInventory}, nil, Filter{WarehouseIDs: []uint{3}, ProductIDs: []uint{8}}, stockUpdate, false},
+		{"alerts only skips updates", []string{TopicInventory}, nil, Filter{AlertsOnly: true}, stockUpdate, false},
+		{"alerts only keeps alerts", []string{TopicInventory}, nil, Filter{AlertsOnly: true}, lowStock, true},
+		{"supplier filter on alerts topic", []string{TopicAlerts}, nil, Filter{SupplierIDs: []uint{5}}, supplierAlert, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewSubscription()
+			if tt.allowed != nil {
+				s.Restrict(tt.allowed)
+			}
+			s.Subscribe(tt.topics...)
+			s.SetFilter(tt.filter)
+			if got := s.Matches(tt.msg); got != tt.want {
+				t.Errorf("Matches = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSubscribeIgnoresUnknownAndUnauthorizedTopics(t *testing.T) {
+	s := NewSubscription(TopicInventory, TopicSuppliers, "payroll")
+	s.Restrict([]string{TopicInventory})
+	if got := s.Topics(); !slices.Equal(got, []string{TopicInventory}) {
+		t.Fatalf("after restrict: topics = %v", got)
+	}
+
+	s.Subscribe(TopicProducts, TopicAlerts)
+	if got := s.Topics(); !slices.Equal(got, []string{TopicInventory, TopicAlerts}) {
+		t.Errorf("after subscribe: topics = %v", got)
+	}
+	if s.Authorized(TopicProducts) || !s.Authorized(TopicInventory) {
+		t.Error("Authorized does not follow Restrict")
+	}
+
+	s.Unsubscribe(TopicInventory)
+	if got := s.Topics(); !slices.Equal(got, []string{TopicAlerts}) {
+		t.Errorf("after unsubscribe: topics = %v", got)
+	}
+}
+
+func TestTopicsForPath(t *testing.T) {
+	tests := map[string][]string{
+		"/ws/inventory":  {TopicInventory},
+		"/ws/suppliers/": {TopicSuppliers},
+		"/ws":            allTopics,
+		"/ws/unknown":    allTopics,
+	}
+	for path, want := range tests {
+		if got := topicsForPath(path); !slices.Equal(got, want) {
+			t.Errorf("topicsForPath(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
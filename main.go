@@ -5,19 +5,28 @@ package main
 
 import (
 	"encoding/json"
-	"fmt"
 	"log"
+	"myapp/cmd"
 	"myapp/internal"
+	"myapp/internal/classification"
+	"myapp/internal/documents"
+	"myapp/internal/forecast"
+	"myapp/internal/imports"
 	"myapp/internal/inventory"
 	"myapp/internal/orders"
+	"myapp/internal/outbox"
 	"myapp/internal/products"
+	"myapp/internal/replenishment"
 	"myapp/internal/reports"
 	"myapp/internal/suppliers"
 	"myapp/internal/warehouses"
+	"myapp/internal/webhooks"
 	"myapp/internal/websocket"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -27,19 +36,31 @@ func main() {
 		log.Println("No .env file found, using environment variables.")
 	}
 
-	host := os.Getenv("DB_HOST")
-	port := os.Getenv("DB_PORT")
-	name := os.Getenv("DB_NAME")
-	user := os.Getenv("DB_USER")
-	password := os.Getenv("DB_PASSWORD")
+	// Subcommands run and exit; those that need the database open it themselves
+	if len(os.Args) > 1 {
+		cmd.Execute()
+		return
+	}
 
-	connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable", host, port, user, password, name)
-	internal.InitDB(connStr)
+	internal.InitDB(internal.ConnStringFromEnv())
 
 	// Initialize WebSocket hub for real-time updates
 	websocket.InitHub()
+	inventory.RegisterCommands()
 	log.Println("🔌 WebSocket hub initialized for real-time inventory updates")
 
+	// Dispatch domain events committed to the outbox
+	outbox.AddSink("websocket", outbox.HubSink)
+	outbox.AddSink("webhooks", webhooks.Sink)
+	outbox.Start(durationEnv("OUTBOX_POLL_INTERVAL", 500*time.Millisecond), durationEnv("OUTBOX_RETENTION", 24*time.Hour))
+
+	webhooks.Start(durationEnv("WEBHOOK_POLL_INTERVAL", time.Second), durationEnv("WEBHOOK_TIMEOUT", 10*time.Second), intEnv("WEBHOOK_MAX_FAILURES", 20))
+
+	// Daily stock snapshots keep /inventory/as-of fast
+	if period := durationEnv("STOCK_SNAPSHOT_PERIOD", 24*time.Hour); period > 0 {
+		inventory.StartSnapshots(period)
+	}
+
 	http.HandleFunc("/products", handleProducts)
 	http.HandleFunc("/products/", handleProductsWithID)
 	http.HandleFunc("/products/search", products.SearchProducts)
@@ -50,12 +71,39 @@ func main() {
 	http.HandleFunc("/inventory/adjust", inventory.AdjustInventory)
 	http.HandleFunc("/inventory/low-stock", inventory.GetLowStock)
 	http.HandleFunc("/inventory/movements", inventory.GetStockMovements)
+	http.HandleFunc("/inventory/as-of", inventory.GetInventoryAsOf)
+	http.HandleFunc("/inventory/snapshots", handleInventorySnapshots)
 
 	// WebSocket endpoints for real-time updates
 	http.HandleFunc("/ws/inventory", websocket.HandleWebSocket)
 	http.HandleFunc("/ws/warehouses", websocket.HandleWebSocket)
 	http.HandleFunc("/ws/products", websocket.HandleWebSocket)
 	http.HandleFunc("/ws/suppliers", websocket.HandleWebSocket)
+	http.HandleFunc("/ws/orders", websocket.HandleWebSocket)
+	http.HandleFunc("/ws/stats", websocket.GetStats)
+	http.HandleFunc("/ws/schema", websocket.GetEventSchema)
+
+	// Server-Sent Events alternative for clients behind proxies that block upgrades
+	http.HandleFunc("/events", websocket.HandleEvents)
+
+	// Outbound webhooks for systems that cannot hold a WebSocket
+	http.HandleFunc("/webhooks", handleWebhooks)
+	http.HandleFunc("/webhooks/", handleWebhooksWithID)
+
+	http.HandleFunc("/replenishment/run", handleReplenishment)
+	http.HandleFunc("/import/", handleImport)
+	http.HandleFunc("/classification", classification.GetClassification)
+	http.HandleFunc("/classification/run", handleClassificationRun)
+	http.HandleFunc("/classification/shifts", classification.GetClassShifts)
+
+	if d := durationEnv("REPLENISHMENT_INTERVAL", 0); d > 0 {
+		replenishment.StartScheduler(d)
+		log.Printf("🔁 Replenishment scheduled every %s", d)
+	}
+	if d := durationEnv("CLASSIFICATION_INTERVAL", 0); d > 0 {
+		classification.StartScheduler(d, intEnv("CLASSIFICATION_DAYS", 365), os.Getenv("CLASSIFICATION_PER_WAREHOUSE") == "true")
+		log.Printf("🔤 ABC/XYZ classification scheduled every %s", d)
+	}
 
 	http.HandleFunc("/suppliers", handleSuppliers)
 	http.HandleFunc("/suppliers/", handleSuppliersWithID)
@@ -64,6 +112,12 @@ func main() {
 	http.HandleFunc("/orders", handleOrders)
 	http.HandleFunc("/orders/", handleOrdersWithID)
 	http.HandleFunc("/reports/stock-summary", reports.GetStockSummary)
+	http.HandleFunc("/reports/backorders", reports.GetBackorderReport)
+	http.HandleFunc("/reports/valuation", reports.GetValuationReport)
+	http.HandleFunc("/reports/sales", reports.GetSalesReport)
+	http.HandleFunc("/reports/sales/top-products", reports.GetTopProducts)
+	http.HandleFunc("/reports/turnover", reports.GetTurnoverReport)
+	http.HandleFunc("/reports/dead-stock", reports.GetDeadStockReport)
 	http.HandleFunc("/audit-logs", reports.GetAuditLogs)
 	http.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
@@ -102,6 +156,25 @@ func handleProductsWithID(w http.ResponseWriter, r *http.Request) {
 		products.SearchProducts(w, r)
 		return
 	}
+	if strings.HasSuffix(r.URL.Path, "/forecast") {
+		switch r.Method {
+		case http.MethodGet:
+			forecast.GetProductForecast(w, r)
+		case http.MethodPost:
+			forecast.ApplyProductForecast(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+		return
+	}
+	if strings.HasSuffix(r.URL.Path, "/suppliers") {
+		if r.Method == http.MethodGet {
+			suppliers.ListProductSuppliers(w, r)
+		} else {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+		return
+	}
 	switch r.Method {
 	case http.MethodGet:
 		products.GetProduct(w, r)
@@ -146,6 +219,17 @@ func handleInventory(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func handleInventorySnapshots(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		inventory.CreateSnapshot(w, r)
+	case http.MethodGet:
+		inventory.ListSnapshots(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
 func handleInventoryWithID(w http.ResponseWriter, r *http.Request) {
 	if r.Method == http.MethodGet {
 		inventory.GetProductInventory(w, r)
@@ -166,8 +250,145 @@ func handleSuppliers(w http.ResponseWriter, r *http.Request) {
 }
 
 func handleSuppliersWithID(w http.ResponseWriter, r *http.Request) {
-	if r.Method == http.MethodPut {
+	if strings.Contains(r.URL.Path, "/products") {
+		handleSupplierProducts(w, r)
+		return
+	}
+	if strings.Contains(r.URL.Path, "/contacts") {
+		handleSupplierContacts(w, r)
+		return
+	}
+	if strings.HasSuffix(r.URL.Path, "/status") {
+		if r.Method == http.MethodPut {
+			suppliers.UpdateSupplierStatus(w, r)
+		} else {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+		return
+	}
+	if strings.HasSuffix(r.URL.Path, "/scorecard") {
+		if r.Method == http.MethodGet {
+			suppliers.GetSupplierScorecard(w, r)
+		} else {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		suppliers.GetSupplier(w, r)
+	case http.MethodPut:
 		suppliers.UpdateSupplier(w, r)
+	case http.MethodDelete:
+		suppliers.ArchiveSupplier(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func handleWebhooks(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		webhooks.CreateWebhook(w, r)
+	case http.MethodGet:
+		webhooks.ListWebhooks(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func handleWebhooksWithID(w http.ResponseWriter, r *http.Request) {
+	if strings.HasSuffix(r.URL.Path, "/replay") {
+		if r.Method == http.MethodPost {
+			webhooks.ReplayDelivery(w, r)
+		} else {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+		return
+	}
+	if strings.HasSuffix(r.URL.Path, "/deliveries") {
+		if r.Method == http.MethodGet {
+			webhooks.ListDeliveries(w, r)
+		} else {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		webhooks.GetWebhook(w, r)
+	case http.MethodPut:
+		webhooks.UpdateWebhook(w, r)
+	case http.MethodDelete:
+		webhooks.DeleteWebhook(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func handleSupplierContacts(w http.ResponseWriter, r *http.Request) {
+	if strings.HasSuffix(strings.TrimSuffix(r.URL.Path, "/"), "/contacts") {
+		switch r.Method {
+		case http.MethodGet:
+			suppliers.ListSupplierContacts(w, r)
+		case http.MethodPost:
+			suppliers.AddSupplierContact(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+		return
+	}
+	switch r.Method {
+	case http.MethodPut:
+		suppliers.UpdateSupplierContact(w, r)
+	case http.MethodDelete:
+		suppliers.DeleteSupplierContact(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func handleSupplierProducts(w http.ResponseWriter, r *http.Request) {
+	if strings.HasSuffix(strings.TrimSuffix(r.URL.Path, "/"), "/products") {
+		switch r.Method {
+		case http.MethodGet:
+			suppliers.ListSupplierProducts(w, r)
+		case http.MethodPost:
+			suppliers.AddSupplierProduct(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+		return
+	}
+	switch r.Method {
+	case http.MethodPut:
+		suppliers.UpdateSupplierProduct(w, r)
+	case http.MethodDelete:
+		suppliers.RemoveSupplierProduct(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func handleReplenishment(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		replenishment.RunReplenishment(w, r)
+	} else {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func handleImport(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		imports.Import(w, r)
+	} else {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func handleClassificationRun(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		classification.RunClassification(w, r)
 	} else {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
@@ -185,8 +406,12 @@ func handlePurchaseOrders(w http.ResponseWriter, r *http.Request) {
 }
 
 func handlePurchaseOrdersWithID(w http.ResponseWriter, r *http.Request) {
-	if strings.Contains(r.URL.Path, "/receive") && r.Method == http.MethodPut {
+	if strings.HasSuffix(r.URL.Path, "/pdf") && r.Method == http.MethodGet {
+		documents.GetPurchaseOrderPDF(w, r)
+	} else if strings.Contains(r.URL.Path, "/receive") && r.Method == http.MethodPut {
 		orders.ReceivePurchaseOrder(w, r)
+	} else if strings.Contains(r.URL.Path, "/approve") && r.Method == http.MethodPut {
+		orders.ApprovePurchaseOrder(w, r)
 	} else {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
@@ -204,9 +429,41 @@ func handleOrders(w http.ResponseWriter, r *http.Request) {
 }
 
 func handleOrdersWithID(w http.ResponseWriter, r *http.Request) {
-	if strings.Contains(r.URL.Path, "/status") && r.Method == http.MethodPut {
+	if strings.HasSuffix(r.URL.Path, "/invoice.pdf") && r.Method == http.MethodGet {
+		documents.GetInvoicePDF(w, r)
+	} else if strings.HasSuffix(r.URL.Path, "/packing-slip.pdf") && r.Method == http.MethodGet {
+		documents.GetPackingSlipPDF(w, r)
+	} else if strings.Contains(r.URL.Path, "/status") && r.Method == http.MethodPut {
 		orders.UpdateOrderStatus(w, r)
 	} else {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
+
+// durationEnv parses the duration in the named variable, falling back to def
+// when it is unset.
+func durationEnv(name string, def time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Fatalf("Invalid %s: %v", name, err)
+	}
+	return d
+}
+
+// intEnv reads a positive integer from the environment, or returns def
+// when the variable is unset.
+func intEnv(name string, def int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		log.Fatalf("Invalid %s: %q", name, value)
+	}
+	return n
+}